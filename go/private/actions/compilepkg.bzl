@@ -99,10 +99,10 @@ def emit_compilepkg(
     inputs_transitive = [sdk.headers, sdk.tools, go.stdlib.libs, headers]
     outputs = [out_lib, out_export]
 
-    shared_args = go.builder_args(go)
+    shared_args = go.builder_args(go, worker = True)
     shared_args.add_all(sources, before_each = "-src")
 
-    compile_args = go.tool_args(go)
+    compile_args = go.tool_args(go, worker = True)
     compile_args.add("-pack", go.toolchain._pack)
     compile_args.add_all(embedsrcs, before_each = "-embedsrc", expand_directories = False)
     compile_args.add_all(
@@ -208,7 +208,7 @@ def emit_compilepkg(
         arguments = arguments,
         env = env,
         toolchain = GO_TOOLCHAIN_LABEL,
-        execution_requirements = execution_requirements,
+        execution_requirements = go.builder_execution_requirements(go, execution_requirements),
     )
 
     if have_nogo:
@@ -246,7 +246,7 @@ def _run_nogo(
     inputs_transitive = [sdk.tools, sdk.headers, go.stdlib.libs]
     outputs = [out_diagnostics, out_facts]
 
-    nogo_args = go.tool_args(go)
+    nogo_args = go.tool_args(go, worker = True)
     if cgo_go_srcs:
         inputs_direct.append(cgo_go_srcs)
         nogo_args.add_all([cgo_go_srcs], before_each = "-ignore_src")
@@ -280,7 +280,7 @@ def _run_nogo(
         arguments = ["nogo", shared_args, nogo_args],
         env = go.env_for_path_mapping,
         toolchain = GO_TOOLCHAIN_LABEL,
-        execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT,
+        execution_requirements = go.builder_execution_requirements(go, SUPPORTS_PATH_MAPPING_REQUIREMENT),
         progress_message = "Running nogo on %{label}",
     )
 
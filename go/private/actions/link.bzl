@@ -62,8 +62,8 @@ def emit_link(
         extldflags.append("--coverage")
     gc_linkopts = gc_linkopts + go.mode.gc_linkopts
     gc_linkopts, extldflags = _extract_extldflags(gc_linkopts, extldflags)
-    builder_args = go.builder_args(go, "link")
-    tool_args = go.tool_args(go)
+    builder_args = go.builder_args(go, "link", worker = True)
+    tool_args = go.tool_args(go, worker = True)
 
     # use ar tool from cc toolchain if cc toolchain provides it
     if go.cgo_tools and go.cgo_tools.ar_path and go.cgo_tools.ar_path.endswith("ar"):
@@ -168,6 +168,10 @@ def emit_link(
     builder_args.add("-o", executable)
     builder_args.add("-main", archive.data.file)
     builder_args.add("-p", archive.data.importmap)
+
+    # The separator is part of builder_args rather than a separate argument
+    # since persistent workers receive the contents of the flag files only.
+    builder_args.add("--")
     tool_args.add_all(gc_linkopts)
     tool_args.add_all(go.toolchain.flags.link)
 
@@ -196,9 +200,10 @@ def emit_link(
         outputs = [executable],
         mnemonic = "GoLink",
         executable = go.toolchain._builder,
-        arguments = [builder_args, "--", tool_args],
+        arguments = [builder_args, tool_args],
         env = go.env,
         toolchain = GO_TOOLCHAIN_LABEL,
+        execution_requirements = go.builder_execution_requirements(go),
         exec_group = exec_group,
     )
 
@@ -68,7 +68,7 @@ def _build_stdlib_list_json(go):
 
     out = go.declare_file(go, "stdlib.pkg.json")
     cache_dir = go.declare_directory(go, "gocache")
-    args = go.builder_args(go, "stdliblist")
+    args = go.builder_args(go, "stdliblist", worker = True)
     args.add("-out", out)
     args.add_all("-cache", [cache_dir], expand_directories = False)
     if go.export_stdlib:
@@ -87,7 +87,7 @@ def _build_stdlib_list_json(go):
         arguments = [args],
         env = _build_env(go),
         toolchain = GO_TOOLCHAIN_LABEL,
-        execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT,
+        execution_requirements = go.builder_execution_requirements(go, SUPPORTS_PATH_MAPPING_REQUIREMENT),
     )
     return out, cache_dir
 
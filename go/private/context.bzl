@@ -218,9 +218,8 @@ def _declare_directory(go, path = "", ext = "", name = ""):
 def _dirname(file):
     return file.dirname
 
-def _builder_args(go, command = None):
-    args = go.actions.args()
-    args.use_param_file("-param=%s")
+def _builder_args(go, command = None, worker = False):
+    args = _tool_args(go, worker = worker)
     if command:
         args.add(command)
     sdk_root_file = go.sdk.root_file
@@ -245,11 +244,28 @@ def _builder_args(go, command = None):
     args.add_joined("-tags", mode.tags, join_with = ",")
     return args
 
-def _tool_args(go):
+def _tool_args(go, worker = False):
     args = go.actions.args()
-    args.use_param_file("-param=%s")
+    if worker and go.builder_worker:
+        # Bazel passes the contents of flag files to persistent workers as the
+        # arguments of a work request.
+        args.use_param_file("--flagfile=%s", use_always = True)
+    else:
+        args.use_param_file("-param=%s")
     return args
 
+def _builder_execution_requirements(go, execution_requirements = {}):
+    """Returns execution_requirements extended to run the builder as a persistent worker if enabled."""
+    if not go.builder_worker:
+        return execution_requirements
+    execution_requirements = dict(execution_requirements)
+    execution_requirements.update({
+        "requires-worker-protocol": go.builder_worker,
+        "supports-multiplex-workers": "1",
+        "supports-workers": "1",
+    })
+    return execution_requirements
+
 def _merge_embed(source, embed):
     s = get_source(embed)
     source["srcs"] = s.srcs + source["srcs"]
@@ -529,6 +545,8 @@ default_go_config_info = GoConfigInfo(
     arm = None,
     pgoprofile = None,
     export_stdlib = False,
+    builder_worker = False,
+    builder_worker_protocol = "proto",
 )
 
 def _cc_runtime_libs_for_mode(mode, cgo_tools):
@@ -722,6 +740,9 @@ def go_context(
         coverage_enabled = ctx.configuration.coverage_enabled,
         coverage_instrumented = ctx.coverage_instrumented(),
         export_stdlib = go_config_info.export_stdlib,
+        # The worker protocol to use if the builder runs as a persistent
+        # worker, None otherwise.
+        builder_worker = go_config_info.builder_worker_protocol if go_config_info.builder_worker else None,
         env = env,
         # Path mapping can't map the values of environment variables, so we pass GOROOT to the action
         # via an argument instead in builder_args. We need to drop it from the environment to get cache
@@ -738,6 +759,7 @@ def go_context(
         # Helpers
         builder_args = _builder_args,
         tool_args = _tool_args,
+        builder_execution_requirements = _builder_execution_requirements,
         new_library = _deprecated_new_library,
         library_to_source = _deprecated_library_to_source,
         declare_file = _declare_file,
@@ -1058,6 +1080,8 @@ def _go_config_impl(ctx):
         arm = ctx.attr.arm,
         pgoprofile = pgoprofile,
         export_stdlib = ctx.attr.export_stdlib[BuildSettingInfo].value,
+        builder_worker = ctx.attr.builder_worker[BuildSettingInfo].value,
+        builder_worker_protocol = ctx.attr.builder_worker_protocol[BuildSettingInfo].value,
     )
     validate_mode(go_config_info)
 
@@ -1118,6 +1142,14 @@ go_config = rule(
             mandatory = False,
             providers = [BuildSettingInfo],
         ),
+        "builder_worker": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "builder_worker_protocol": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
         "force_pic": attr.bool(mandatory = True),
     },
     provides = [GoConfigInfo],
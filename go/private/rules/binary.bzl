@@ -184,6 +184,8 @@ def _go_binary_impl(ctx):
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
             nogo_fix = [nogo_diagnostics] if nogo_diagnostics else [],
+            nogo_report = [nogo_diagnostics] if nogo_diagnostics else [],
+            nogo_profile = [nogo_diagnostics] if nogo_diagnostics else [],
             _validation = [validation_output] if validation_output else [],
         ),
     ]
@@ -511,6 +513,16 @@ def _go_binary_kwargs(go_cc_aspects = []):
 
         ***Note:*** `name` should be the same as the desired name of the generated binary.
 
+        When built with `--collect_code_coverage` and run outside of `bazel coverage`,
+        for example as a server started by an integration test, the binary writes its
+        coverage to the directory in the `GO_BINARY_COVERAGE_DIR` environment variable
+        when it exits. Binaries that don't handle termination signals themselves can set
+        `GO_BINARY_COVERAGE_SIGNALS=SIGINT,SIGTERM` to also write their coverage before
+        these signals terminate them, and `GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL=SIGUSR1`
+        to write a snapshot without exiting on a signal. Each process writes an lcov report (`go_coverage.<id>.dat`) and a go
+        cover profile (`go_coverage.<id>.out`), which can be merged with
+        `bazel run @io_bazel_rules_go//go/tools/covmerge -- [-format=cover] <dir>`.
+
         **Providers:**
         - [GoArchive]
         """,
@@ -201,8 +201,7 @@ def cgo_configure(go, srcs, cdeps, cppopts, copts, cxxopts, clinkopts):
             if option not in ("-lstdc++", "-lc++")
         ]
 
-    ldflags = go.actions.args()
-    ldflags.use_param_file("-param=%s")
+    ldflags = go.tool_args(go, worker = True)
     _add_ldflags(ldflags, pre_runtime_clinkopts)
     if needs_cxx_runtime:
         ldflags.add_all(runtime_libs, before_each = "-ldflags")
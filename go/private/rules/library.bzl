@@ -72,6 +72,8 @@ def _go_library_impl(ctx):
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
             nogo_fix = [nogo_diagnostics] if nogo_diagnostics else [],
+            nogo_report = [nogo_diagnostics] if nogo_diagnostics else [],
+            nogo_profile = [nogo_diagnostics] if nogo_diagnostics else [],
             _validation = [validation_output] if validation_output else [],
         ),
     ]
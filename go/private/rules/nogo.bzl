@@ -54,12 +54,21 @@ def _nogo_impl(ctx):
     nogo_args.add("-output", nogo_main)
     if ctx.attr.debug:
         nogo_args.add("-debug")
+    if ctx.attr.strict_nolint:
+        nogo_args.add("-strict_nolint")
+    if ctx.attr.require_nolint_justification:
+        nogo_args.add("-require_nolint_justification")
+    if ctx.attr.profile:
+        nogo_args.add("-profile")
     nogo_inputs = []
     analyzer_importpaths = [archive.data.importpath for archive in analyzer_archives]
     nogo_args.add_all(analyzer_importpaths, before_each = "-analyzer_importpath")
     if ctx.file.config:
         nogo_args.add("-config", ctx.file.config)
         nogo_inputs.append(ctx.file.config)
+    if ctx.file.baseline:
+        nogo_args.add("-baseline", ctx.file.baseline)
+        nogo_inputs.append(ctx.file.baseline)
     ctx.actions.run(
         inputs = nogo_inputs,
         outputs = [nogo_main],
@@ -103,9 +112,21 @@ _nogo = rule(
         "config": attr.label(
             allow_single_file = True,
         ),
+        "baseline": attr.label(
+            allow_single_file = True,
+        ),
         "debug": attr.bool(
             default = False,
         ),
+        "strict_nolint": attr.bool(
+            default = False,
+        ),
+        "require_nolint_justification": attr.bool(
+            default = False,
+        ),
+        "profile": attr.bool(
+            default = False,
+        ),
         "_nogo_srcs": attr.label(
             default = "//go/tools/builders:nogo_srcs",
         ),
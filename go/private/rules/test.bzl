@@ -61,6 +61,9 @@ def _go_test_impl(ctx):
     It emits an action to run the test generator, and then compiles the
     test into a binary."""
 
+    if (ctx.attr.shard_strategy == "duration") != (ctx.file.shard_timings != None):
+        fail('shard_timings must be set if and only if shard_strategy = "duration" is set')
+
     go = go_context(
         ctx,
         importpath = ctx.attr.importpath,
@@ -138,10 +141,15 @@ def _go_test_impl(ctx):
         "l_test=" + external_go_info.importpath,
     )
     arguments.add("-pkgname", internal_go_info.importpath)
+    arguments.add("-shard_strategy", ctx.attr.shard_strategy)
+    gentestmain_inputs = go_srcs
+    if ctx.file.shard_timings:
+        arguments.add("-shard_timings", ctx.file.shard_timings)
+        gentestmain_inputs = go_srcs + [ctx.file.shard_timings]
     arguments.add_all(go_srcs, before_each = "-src", format_each = "l=%s")
 
     ctx.actions.run(
-        inputs = go_srcs,
+        inputs = gentestmain_inputs,
         outputs = [main_go],
         mnemonic = "GoTestGenTest",
         executable = go.toolchain._builder,
@@ -172,6 +180,9 @@ def _go_test_impl(ctx):
     test_deps = external_archive.direct + [external_archive] + ctx.attr._testmain_additional_deps
     if go.coverage_enabled:
         test_deps.append(go.coverdata)
+
+        # Used by the generated test main to record the coverage of each test.
+        test_deps.append(ctx.attr._bincov)
     test_go_info = new_go_info(
         go,
         struct(
@@ -225,6 +236,8 @@ def _go_test_impl(ctx):
                 external_archive.data.file,
             ],
             nogo_fix = nogo_diagnosticss,
+            nogo_report = nogo_diagnosticss,
+            nogo_profile = nogo_diagnosticss,
             _validation = validation_outputs,
         ),
         coverage_common.instrumented_files_info(
@@ -336,6 +349,33 @@ _go_test_kwargs = {
             the main repository.
             """,
         ),
+        "shard_strategy": attr.string(
+            default = "round_robin",
+            values = ["round_robin", "hash", "duration"],
+            doc = """How tests, fuzz targets, examples and benchmarks are assigned to
+            shards when the test is sharded with `shard_count`.
+
+            <ul>
+            <li>`round_robin` (default): The i-th test runs in shard i modulo the number of shards.</li>
+            <li>`hash`: Tests are assigned by a stable hash of their names, so adding or removing a
+            test doesn't move the other tests to different shards.</li>
+            <li>`duration`: The expected durations of tests, read from `shard_timings`, are balanced
+            over the shards.</li>
+            </ul>
+
+            The strategy, shard index and shard count are recorded as properties of the test
+            suites in the test XML output.
+            """,
+        ),
+        "shard_timings": attr.label(
+            allow_single_file = [".json"],
+            cfg = non_go_transition,
+            doc = """A JSON file mapping names of tests, fuzz targets, examples and benchmarks to
+            their expected durations in seconds, for example `{"TestSlow": 42.5}`.
+            Required by the `duration` shard strategy. Tests that aren't listed are assumed to take
+            the average duration of the listed ones.
+            """,
+        ),
         "x_defs": attr.string_dict(
             doc = """Map of defines to add to the go link command.
             See [Defines and stamping] for examples of how to use these.
@@ -472,6 +512,10 @@ _go_test_kwargs = {
             providers = [GoInfo],
             default = ["//go/tools/bzltestutil"],
         ),
+        "_bincov": attr.label(
+            providers = [GoInfo],
+            default = "//go/tools/bzltestutil/bincov",
+        ),
         # Required for Bazel to collect coverage of instrumented C/C++ binaries
         # executed by go_test.
         # This is just a shell script and thus cheap enough to depend on
@@ -531,6 +575,74 @@ _go_test_kwargs = {
     `GO_TEST_WRAP_TESTV=1` in the test environment; this will result in the
     `XML_OUTPUT_FILE` containing more granular data.
 
+    In the `XML_OUTPUT_FILE`, subtests are reported with the name of their parent
+    test as their `classname`, and subtests that have subtests of their own are
+    nested test suites. The output of passed tests is included in `system-out`,
+    and test cases have `file` and `line` attributes with the location of the first
+    message logged by the test, or for failed tests of the last message logged
+    before the failure was reported. Files are reported relative to the root of
+    the repository, assuming they are in the package of the test. Pass
+    `--test_arg=-test.fullpath` to report full paths instead.
+
+    The wrapper can also rerun failed tests by setting `GO_TEST_WRAP_RETRIES=N`
+    in the test environment. Top-level tests, examples and fuzz targets that
+    failed are rerun up to N times with `-test.run`, without rerunning the tests
+    that passed, and the test passes if they pass when retried. Every failed run
+    is recorded in the `XML_OUTPUT_FILE`: tests that passed when retried have
+    `flakyFailure` elements, and tests that failed every time have
+    `rerunFailure` elements for the failed reruns. Tests are not retried if the
+    test binary panicked or timed out, or with `--test_runner_fail_fast`.
+    When coverage is collected, the coverage of reruns is added to that of the
+    first run.
+
+    The wrapper also writes the test events in the format of `go test -json` to
+    `test2json.json` in the undeclared outputs of the test, from where tools like
+    gotestsum or tparse can read them. Set `GO_TEST_WRAP_JSON_OUTPUT_FILE` in the
+    test environment to write them to a different path instead, or to an empty
+    value to not write them. The events of retried tests follow the events of the
+    first run.
+
+    When benchmarks run, for example with `--test_arg=-test.bench=.`, the wrapper
+    also writes their results to `benchmarks.txt` in the undeclared outputs of the
+    test, in the format read by benchstat, and the values, mean and median of each
+    metric of each benchmark to `benchmarks.json`. Set
+    `GO_TEST_WRAP_BENCHMARK_OUTPUT_DIR` in the test environment to write them to a
+    different directory, or to an empty value to not write them; with `bazel run`,
+    set it together with `GO_TEST_WRAP=1`. Two `benchmarks.txt` files can be
+    compared with `bazel run @io_bazel_rules_go//go/tools/benchcompare -- old.txt new.txt`,
+    which fails with `-max_regression=P` if the time, bytes or allocations per
+    operation of a benchmark increased significantly by more than P percent.
+
+    When a test times out, the wrapper writes the goroutine dump printed by the
+    test binary to `goroutines.txt` in the undeclared outputs of the test, with the
+    goroutines that have the same state and stack grouped together. In the
+    `XML_OUTPUT_FILE`, the tests that were running report a summary of the dump and
+    the stacks of the goroutines running tests instead of the whole dump.
+
+    Set `GO_TEST_LEAK_CHECK` in the test environment to check for goroutines and
+    child processes that are still running after the tests, like goleak does. With
+    `warn`, they are printed after the test results and reported by a passing
+    `LeakCheck` test case in the `XML_OUTPUT_FILE`; with `fail`, the `LeakCheck`
+    test case fails and so does the test. Goroutines and child processes are given
+    a second to exit, and those started by the standard library are ignored. Child
+    processes are only checked on Linux, where the descendants of the test and the
+    processes in its process group are listed. When the test defines `TestMain`
+    and is built with Go 1.22 or earlier, the check only runs if `TestMain` returns
+    instead of calling `os.Exit`.
+
+    When running `bazel coverage`, tests report the line and function coverage
+    of Go code. Set `GO_LCOV_BRANCH_COVERAGE=1` in the test environment to also
+    report branch coverage: where several coverage blocks start on the same line,
+    like the statement and the bodies of `if ok { a() } else { b() }`, each is reported
+    as a branch of that line in `BRDA` records.
+
+    Set `GO_TEST_PER_TEST_COVERAGE=1` to also record the coverage of each top-level
+    test separately. The counters are written and reset around each test, and the
+    coverage of the tests is appended to `per_test_coverage.dat` in the undeclared
+    test outputs as an lcov report with a `TN` record naming the test before each
+    source file it covers. Top-level tests that call `t.Parallel` run alongside each
+    other, so their coverage can't be told apart.
+
     ***Note:*** To interoperate cleanly with old targets generated by [Gazelle], `name`
     should be `go_default_test` for internal tests and
     `go_default_xtest` for external tests. Gazelle now generates
@@ -0,0 +1,245 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bundleManifest is the name of the manifest written to the root of bundles.
+const bundleManifest = "MANIFEST"
+
+// bundleModTime is the modification time of all files in archives, so that
+// they only depend on the runfiles.  It is the earliest time zip supports.
+var bundleModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// bundleEntry is a runfile or a directory of runfiles in a bundle.
+type bundleEntry struct {
+	// name is the runfiles path of the entry, with canonical repository
+	// names.
+	name string
+	info fs.FileInfo
+}
+
+// isEmpty reports whether the entry is an empty runfile that the manifest
+// maps to no file.
+func (e bundleEntry) isEmpty() bool {
+	_, ok := e.info.(emptyFileInfo)
+	return ok
+}
+
+// mode returns the permissions of the entry in a bundle: everyone may read
+// it, and execute it if it is a directory or an executable file.
+func (e bundleEntry) mode() fs.FileMode {
+	if e.info.IsDir() || e.info.Mode()&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// WriteDirectory copies all runfiles of r, including the repository mapping,
+// to dir, which is created if it doesn't exist.  Symlinks are resolved, so
+// that dir is self-contained and can be moved to another machine and used with
+// the [Directory] option.  A MANIFEST file is written to dir as well, whose
+// targets are relative to dir.
+func (r *Runfiles) WriteDirectory(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("runfiles: %w", err)
+	}
+	return r.writeBundle(func(e bundleEntry, content io.Reader) error {
+		p := filepath.Join(dir, filepath.FromSlash(e.name))
+		if e.info.IsDir() {
+			return os.MkdirAll(p, e.mode())
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.mode())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, content); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// WriteTar writes all runfiles of r, including the repository mapping, and a
+// MANIFEST file whose targets are relative to the root of the archive, to w as
+// a tar archive.  The archive only depends on the paths, contents and
+// executable bits of the runfiles: it can be extracted on another machine and
+// used with the [Directory] option.
+func (r *Runfiles) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := r.writeBundle(func(e bundleEntry, content io.Reader) error {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    int64(e.mode()),
+			ModTime: bundleModTime,
+			Format:  tar.FormatPAX,
+		}
+		if e.info.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = e.info.Size()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// WriteZip writes all runfiles of r like [Runfiles.WriteTar], but as a zip
+// archive.
+func (r *Runfiles) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := r.writeBundle(func(e bundleEntry, content io.Reader) error {
+		hdr := &zip.FileHeader{
+			Name:     e.name,
+			Method:   zip.Deflate,
+			Modified: bundleModTime,
+		}
+		hdr.SetMode(e.mode())
+		if e.info.IsDir() {
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+			hdr.SetMode(fs.ModeDir | e.mode())
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil || e.info.IsDir() {
+			return err
+		}
+		_, err = io.Copy(fw, content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeBundle calls write with all runfiles and the directories containing
+// them in lexical order, parents first, and finally with the manifest of the
+// bundle.  content is empty for directories.
+func (r *Runfiles) writeBundle(write func(e bundleEntry, content io.Reader) error) error {
+	if r.impl == nil {
+		return errors.New("runfiles: uninitialized Runfiles object")
+	}
+	fsys := implFS{r.impl}
+	var manifest strings.Builder
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			// The manifest of a runfiles directory is replaced by the one
+			// of the bundle.
+			if dir == "." && entry.Name() == bundleManifest {
+				continue
+			}
+			name := path.Join(dir, entry.Name())
+			// Stat resolves symlinks, which may point to directories.
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			e := bundleEntry{name, info}
+			if info.IsDir() {
+				if err := write(e, strings.NewReader("")); err != nil {
+					return err
+				}
+				if err := walk(name); err != nil {
+					return err
+				}
+				continue
+			}
+			if e.isEmpty() {
+				manifest.WriteString(manifestLine(name, ""))
+			} else {
+				manifest.WriteString(manifestLine(name, name))
+			}
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			err = write(e, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("."); err != nil {
+		return fmt.Errorf("runfiles: %w", err)
+	}
+	info := bundleManifestInfo(manifest.Len())
+	if err := write(bundleEntry{bundleManifest, info}, strings.NewReader(manifest.String())); err != nil {
+		return fmt.Errorf("runfiles: %w", err)
+	}
+	return nil
+}
+
+// manifestLine returns the line of a runfiles manifest that maps link to
+// target, escaping them like Bazel if necessary.
+func manifestLine(link, target string) string {
+	if !strings.ContainsAny(link, " \n\\") && !strings.ContainsAny(target, "\n\\") {
+		return link + " " + target + "\n"
+	}
+	link = strings.NewReplacer(`\`, `\b`, " ", `\s`, "\n", `\n`).Replace(link)
+	target = strings.NewReplacer(`\`, `\b`, "\n", `\n`).Replace(target)
+	return " " + link + " " + target + "\n"
+}
+
+// implFS exposes the runfiles of an implementation with their canonical
+// repository names only.
+type implFS struct {
+	impl runfiles
+}
+
+func (f implFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.impl.open(name)
+}
+
+type bundleManifestInfo int64
+
+func (bundleManifestInfo) Name() string       { return bundleManifest }
+func (i bundleManifestInfo) Size() int64      { return int64(i) }
+func (bundleManifestInfo) Mode() fs.FileMode  { return 0444 }
+func (bundleManifestInfo) ModTime() time.Time { return bundleModTime }
+func (bundleManifestInfo) IsDir() bool        { return false }
+func (bundleManifestInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,263 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// explainVar is the environmental variable that attaches an [Explanation] to
+// the errors of failed lookups if set to a true value, like "1".
+const explainVar = "RUNFILES_EXPLAIN"
+
+// maxSuggestions is the maximum number of similar runfiles in an
+// [Explanation].
+const maxSuggestions = 5
+
+// Explanation describes how a runfile was looked up, to diagnose why a lookup
+// failed.  Use [Runfiles.Explain] to obtain one.  If the environmental
+// variable RUNFILES_EXPLAIN is set to 1, the [Error] values returned by
+// [Rlocation], [RlocationLabel] and the corresponding methods of [Runfiles]
+// also contain one.
+type Explanation struct {
+	// Path is the runfiles path or label that was looked up.
+	Path string
+
+	// Discovery describes how [New] found the runfiles, e.g. which manifest
+	// file or directory it chose and why.
+	Discovery string
+
+	// SourceRepo is the canonical name of the repository whose repository
+	// mapping applies to Path.  It is empty for the main repository.
+	SourceRepo string
+
+	// Mapping describes how the repository mapping applied to Path.
+	Mapping string
+
+	// MappedPath is the runfiles path that was looked up, with the canonical
+	// name of its repository.
+	MappedPath string
+
+	// Location and Err are the result of the lookup.
+	Location string
+	Err      error
+
+	// Suggestions are the runfiles paths of existing runfiles similar to
+	// MappedPath if it doesn't exist, such as runfiles with the same name in
+	// other repositories, best matches first.  They use apparent repository
+	// names where the source repository sees one.
+	Suggestions []string
+}
+
+// String formats e as a human-readable report of several lines.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "runfiles lookup of %q:\n", e.Path)
+	fmt.Fprintf(&b, "  discovery: %s\n", e.Discovery)
+	if e.SourceRepo == "" {
+		fmt.Fprintf(&b, "  source repository: main repository\n")
+	} else {
+		fmt.Fprintf(&b, "  source repository: %q\n", e.SourceRepo)
+	}
+	fmt.Fprintf(&b, "  repository mapping: %s\n", e.Mapping)
+	fmt.Fprintf(&b, "  looked up: %q\n", e.MappedPath)
+	if e.Err != nil {
+		fmt.Fprintf(&b, "  result: %v\n", e.Err)
+	} else {
+		fmt.Fprintf(&b, "  result: %s\n", e.Location)
+	}
+	if len(e.Suggestions) > 0 {
+		fmt.Fprintf(&b, "  similar runfiles: %s\n", strings.Join(quote(e.Suggestions), ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func quote(ss []string) []string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = strconv.Quote(s)
+	}
+	return quoted
+}
+
+// Explain looks up a runfile like [Runfiles.Rlocation] and describes how:
+// how the runfiles were discovered, how the repository mapping applied to
+// the path, what was looked up and, if it doesn't exist, similar runfiles
+// that do.
+func (r *Runfiles) Explain(path string) *Explanation {
+	if r.impl == nil {
+		return &Explanation{Path: path, Err: errors.New("runfiles: uninitialized Runfiles object")}
+	}
+	quiet := *r
+	quiet.explain = false
+	location, err := quiet.Rlocation(path)
+	mappedPath := path
+	if !filepath.IsAbs(path) {
+		mappedPath = r.mapPath(path)
+	}
+	return r.explainLookup(path, mappedPath, r.describeMapping(path), location, err)
+}
+
+// explainLookup describes the lookup of a runfile with the given name, which
+// may be a label, and runfiles path.
+func (r *Runfiles) explainLookup(name, mappedPath, mapping, location string, err error) *Explanation {
+	e := &Explanation{
+		Path:       name,
+		Discovery:  r.discovery,
+		SourceRepo: r.sourceRepo,
+		Mapping:    mapping,
+		MappedPath: mappedPath,
+		Location:   location,
+		Err:        err,
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		e.Suggestions = r.suggest(mappedPath)
+	}
+	return e
+}
+
+// lookupError returns the error for a failed lookup of the runfile with the
+// given name and runfiles path, with an explanation if RUNFILES_EXPLAIN is
+// set.
+func (r *Runfiles) lookupError(name, mappedPath, mapping string, err error) error {
+	e := Error{Name: name, Err: err}
+	if r.explain {
+		e.Explanation = r.explainLookup(name, mappedPath, mapping, "", err)
+	}
+	return e
+}
+
+// describeMapping describes how the repository mapping applies to a runfiles
+// path.
+func (r *Runfiles) describeMapping(p string) string {
+	repo, _, ok := strings.Cut(p, "/")
+	switch {
+	case filepath.IsAbs(p):
+		return "none, the path is absolute"
+	case !ok:
+		return "none, the path has no repository"
+	case r.repoMapping.isEmpty():
+		return "none, the runfiles have no repository mapping"
+	}
+	if target, ok := r.repoMapping.Get(repoMappingKey{r.sourceRepo, repo}); ok {
+		return fmt.Sprintf("apparent name %q maps to %q", repo, target)
+	}
+	return fmt.Sprintf("%q isn't an apparent name visible from the source repository, so it is used as a canonical name", repo)
+}
+
+// suggest returns the runfiles paths of existing runfiles similar to the
+// given one.  Runfiles with the same name are ranked by whether they have the
+// same path in another repository, are in the same repository, and by the
+// number of trailing path segments they have in common with p.
+func (r *Runfiles) suggest(p string) []string {
+	repo, rest, ok := strings.Cut(p, "/")
+	if !ok {
+		repo, rest = "", p
+	}
+	type candidate struct {
+		path              string
+		sameRest          bool
+		sameRepo          bool
+		commonTrailingSeg int
+	}
+	var candidates []candidate
+	r.forEachRunfile(func(c string) {
+		if c == p || c == repoMappingRlocation || path.Base(c) != path.Base(p) {
+			return
+		}
+		cRepo, cRest, _ := strings.Cut(c, "/")
+		candidates = append(candidates, candidate{
+			path:              c,
+			sameRest:          cRest == rest,
+			sameRepo:          cRepo == repo,
+			commonTrailingSeg: commonTrailingSegments(c, p),
+		})
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.sameRest != b.sameRest {
+			return a.sameRest
+		}
+		if a.sameRepo != b.sameRepo {
+			return a.sameRepo
+		}
+		if a.commonTrailingSeg != b.commonTrailingSeg {
+			return a.commonTrailingSeg > b.commonTrailingSeg
+		}
+		return a.path < b.path
+	})
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+
+	// Suggest paths that can be passed to Rlocation as is.
+	apparentNames := make(map[string]string)
+	r.repoMapping.ForEachVisible(r.sourceRepo, func(apparentName, targetRepo string) {
+		if old, ok := apparentNames[targetRepo]; apparentName != "" && (!ok || apparentName < old) {
+			apparentNames[targetRepo] = apparentName
+		}
+	})
+	var suggestions []string
+	for _, c := range candidates {
+		cRepo, cRest, ok := strings.Cut(c.path, "/")
+		if apparentName, found := apparentNames[cRepo]; ok && found {
+			suggestions = append(suggestions, apparentName+"/"+cRest)
+		} else {
+			suggestions = append(suggestions, c.path)
+		}
+	}
+	return suggestions
+}
+
+func commonTrailingSegments(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// forEachRunfile calls f with the runfiles paths of all runfiles, which may
+// be directories in the case of manifests.  Runfiles that can't be listed
+// are skipped.
+func (r *Runfiles) forEachRunfile(f func(string)) {
+	if m, ok := r.impl.(*manifest); ok {
+		// Listing directories of manifests stats their targets, which is
+		// slow and fails for missing targets.
+		m.forEachLink(f)
+		return
+	}
+	fs.WalkDir(implFS{r.impl}, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			f(p)
+		}
+		return nil
+	})
+}
+
+// explainEnabled reports whether RUNFILES_EXPLAIN is set to a true value.
+func explainEnabled() bool {
+	explain, _ := strconv.ParseBool(os.Getenv(explainVar))
+	return explain
+}
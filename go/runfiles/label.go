@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The runfiles directory of the main repository with Bzlmod.
+const mainRepoDirectory = "_main"
+
+// RlocationLabel returns the (relative or absolute) path name of the runfile
+// built or provided by the given label, like Rlocation.  The label must be
+// absolute, like "//pkg:file" or "@my_dep//pkg:data.txt".  Its apparent
+// repository name is resolved using the repository mapping of the repository
+// containing the caller of RlocationLabel.
+func RlocationLabel(label string) (string, error) {
+	r, err := g.get()
+	if err != nil {
+		return "", err
+	}
+	return r.WithSourceRepo(CallerRepository()).RlocationLabel(label)
+}
+
+// RlocationLabel returns the (relative or absolute) path name of the runfile
+// built or provided by the given label.  The label must be absolute: it is of
+// the form "@repo//pkg:name" or "//pkg:name", where "@repo" is an apparent
+// repository name resolved using the repository mapping of the source
+// repository of r, "@@repo" is a canonical repository name, "@" and "@@" refer
+// to the main repository, and no repository refers to the source repository
+// itself.  As in Bazel, "//pkg" is short for
+// "//pkg:pkg" and "@repo" for "@repo//:repo".
+//
+// RlocationLabel returns an error if the repository of the label is not
+// visible from the source repository, and otherwise behaves like
+// [Runfiles.Rlocation].
+func (r *Runfiles) RlocationLabel(label string) (string, error) {
+	if r.impl == nil {
+		return "", errors.New("runfiles: uninitialized Runfiles object")
+	}
+	mappedPath, err := r.labelPath(label)
+	if err != nil {
+		return "", err
+	}
+	p, err := r.impl.path(mappedPath)
+	if err != nil {
+		return "", r.lookupError(label, mappedPath, fmt.Sprintf("label refers to %q", mappedPath), err)
+	}
+	return p, nil
+}
+
+// OpenLabel opens the runfile built or provided by the given label, which is
+// resolved like in [Runfiles.RlocationLabel].
+func (r *Runfiles) OpenLabel(label string) (fs.File, error) {
+	if r.impl == nil {
+		return nil, errors.New("runfiles: uninitialized Runfiles object")
+	}
+	mappedPath, err := r.labelPath(label)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.impl.open(mappedPath)
+	if err != nil {
+		return nil, r.lookupError(label, mappedPath, fmt.Sprintf("label refers to %q", mappedPath), err)
+	}
+	return f, nil
+}
+
+// labelPath returns the runfiles path of the given label, with the canonical
+// name of its repository.
+func (r *Runfiles) labelPath(label string) (string, error) {
+	repo, pkg, name, err := parseLabel(label)
+	if err != nil {
+		return "", err
+	}
+	repoDirectory, err := r.labelRepoDirectory(label, repo)
+	if err != nil {
+		return "", err
+	}
+	p := repoDirectory + "/" + name
+	if pkg != "" {
+		p = repoDirectory + "/" + pkg + "/" + name
+	}
+	if err := isNormalizedPath(p); err != nil {
+		return "", fmt.Errorf("runfiles: invalid label %q: %w", label, err)
+	}
+	return p, nil
+}
+
+// parseLabel splits an absolute label into its repository, including the
+// leading "@" or "@@" if any, its package and its target name.
+func parseLabel(label string) (repo, pkg, name string, err error) {
+	rest := label
+	if strings.HasPrefix(rest, "@") {
+		repo = rest
+		if i := strings.Index(rest, "//"); i >= 0 {
+			repo, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		repoName := strings.TrimLeft(repo, "@")
+		if len(repo)-len(repoName) > 2 || strings.ContainsAny(repoName, "/:") {
+			return "", "", "", fmt.Errorf("runfiles: invalid repository name in label %q", label)
+		}
+		if rest == "" {
+			// "@repo" is short for "@repo//:repo".
+			if repoName == "" {
+				return "", "", "", fmt.Errorf("runfiles: label %q has no target name", label)
+			}
+			return repo, "", repoName, nil
+		}
+	}
+	if !strings.HasPrefix(rest, "//") {
+		return "", "", "", fmt.Errorf("runfiles: label %q must be absolute, like \"//pkg:name\" or \"@repo//pkg:name\"", label)
+	}
+	pkg, name, hasName := strings.Cut(rest[2:], ":")
+	if !hasName {
+		// "//pkg" is short for "//pkg:pkg".
+		name = path.Base(pkg)
+	}
+	if name == "" || name == "." {
+		return "", "", "", fmt.Errorf("runfiles: label %q has no target name", label)
+	}
+	if strings.HasPrefix(pkg, "/") || strings.HasSuffix(pkg, "/") {
+		return "", "", "", fmt.Errorf("runfiles: invalid package name in label %q", label)
+	}
+	return repo, pkg, name, nil
+}
+
+// labelRepoDirectory returns the runfiles directory of the repository of a
+// label as returned by parseLabel.
+func (r *Runfiles) labelRepoDirectory(label, repo string) (string, error) {
+	switch {
+	case repo == "":
+		if r.sourceRepo != "" {
+			return r.sourceRepo, nil
+		}
+		return r.mainRepoDirectory(label)
+	case repo == "@" || repo == "@@":
+		return r.mainRepoDirectory(label)
+	case strings.HasPrefix(repo, "@@"):
+		return repo[2:], nil
+	}
+	apparentName := repo[1:]
+	if repoDirectory, ok := r.repoMapping.Get(repoMappingKey{r.sourceRepo, apparentName}); ok {
+		return repoDirectory, nil
+	}
+	if r.repoMapping.isEmpty() {
+		// Without Bzlmod, apparent repository names are canonical.
+		return apparentName, nil
+	}
+	var visible []string
+	r.repoMapping.ForEachVisible(r.sourceRepo, func(targetRepoApparentName, _ string) {
+		visible = append(visible, "@"+targetRepoApparentName)
+	})
+	sort.Strings(visible)
+	source := "the main repository"
+	if r.sourceRepo != "" {
+		source = fmt.Sprintf("repository @@%s", r.sourceRepo)
+	}
+	if len(visible) == 0 {
+		return "", fmt.Errorf("runfiles: repository %s of label %q is not visible from %s, which sees no repositories", repo, label, source)
+	}
+	return "", fmt.Errorf("runfiles: repository %s of label %q is not visible from %s, which sees %s", repo, label, source, strings.Join(visible, ", "))
+}
+
+// mainRepoDirectory returns the runfiles directory of the main repository:
+// "_main" with Bzlmod and the workspace name otherwise, which is only known
+// in tests.
+func (r *Runfiles) mainRepoDirectory(label string) (string, error) {
+	if !r.repoMapping.isEmpty() {
+		return mainRepoDirectory, nil
+	}
+	if workspace := os.Getenv("TEST_WORKSPACE"); workspace != "" {
+		return workspace, nil
+	}
+	return "", fmt.Errorf("runfiles: cannot resolve label %q: the runfiles directory of the main repository is unknown without Bzlmod outside of tests", label)
+}
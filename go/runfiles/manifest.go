@@ -15,7 +15,8 @@
 package runfiles
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"io/fs"
@@ -24,6 +25,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -31,6 +33,13 @@ import (
 // ManifestFile specifies the location of the runfile manifest file.  You can
 // pass this as an option to [New].  If unset or empty, use the value of the
 // environmental variable RUNFILES_MANIFEST_FILE.
+//
+// The manifest is mapped into memory and its lines are indexed instead of
+// parsed up front.  The index is cached in a file named like the manifest
+// with an ".index" suffix if that directory is writable, or in the directory
+// named by the environmental variable RUNFILES_INDEX_CACHE_DIR if set, so that
+// processes that use the same large manifest load it in constant time.  The
+// cache is keyed by the path, size and modification time of the manifest.
 type ManifestFile string
 
 func (f ManifestFile) new(sourceRepo SourceRepo) (*Runfiles, error) {
@@ -54,7 +63,7 @@ func (f ManifestFile) new(sourceRepo SourceRepo) (*Runfiles, error) {
 			legacyDirectoryVar+"="+d)
 	}
 	r := &Runfiles{
-		impl:       &m,
+		impl:       m,
 		env:        env,
 		sourceRepo: string(sourceRepo),
 	}
@@ -62,67 +71,197 @@ func (f ManifestFile) new(sourceRepo SourceRepo) (*Runfiles, error) {
 	return r, err
 }
 
-type trie map[string]trie
-
+// manifest is a runfiles manifest.  Instead of parsing all of its lines, which
+// takes a while for large manifests, runfiles are looked up by binary search
+// in an index of its lines sorted by link; see manifestIndex.
 type manifest struct {
-	index map[string]string
-	trie  trie
+	data  []byte // the contents of the manifest
+	cache string // the path of the cached index, if any
+
+	mu      sync.Mutex
+	index   manifestIndex
+	rebuilt bool // whether index was built from data after a stale cache
 }
 
-func (f ManifestFile) parse() (manifest, error) {
-	r, err := os.Open(string(f))
+func (f ManifestFile) parse() (*manifest, error) {
+	data, info, err := readFile(string(f))
 	if err != nil {
-		return manifest{}, fmt.Errorf("runfiles: can’t open manifest file: %w", err)
-	}
-	defer r.Close()
-
-	s := bufio.NewScanner(r)
-	m := manifest{make(map[string]string), nil}
-	for s.Scan() {
-		line := s.Text()
-		var link, target string
-		if strings.HasPrefix(line, " ") {
-			// In lines that start with a space, spaces, newlines, and backslashes are escaped as \s, \n, and \b in
-			// link and newlines and backslashes are escaped in target.
-			fields := strings.SplitN(s.Text()[1:], " ", 2)
-			link = fields[0]
-			target = fields[1]
-			link = strings.ReplaceAll(link, `\s`, " ")
-			link = strings.ReplaceAll(link, `\n`, "\n")
-			link = strings.ReplaceAll(link, `\b`, `\`)
-			target = strings.ReplaceAll(target, `\n`, "\n")
-			target = strings.ReplaceAll(target, `\b`, `\`)
-		} else {
-			fields := strings.SplitN(line, " ", 2)
-			link = fields[0]
-			target = fields[1]
-		}
-		m.index[link] = filepath.FromSlash(target)
+		return nil, fmt.Errorf("runfiles: can’t open manifest file: %w", err)
+	}
+	cache := indexCachePath(string(f))
+	return &manifest{
+		data:  data,
+		cache: cache,
+		index: loadIndex(cache, data, info.ModTime().UnixNano()),
+	}, nil
+}
+
+// withIndex calls f with a view of the index of the manifest.  If f finds
+// that the index doesn't match the manifest, it is rebuilt and f is called
+// again.
+func (m *manifest) withIndex(f func(v *indexView)) {
+	v := m.view()
+	f(v)
+	if v.stale {
+		m.rebuildIndex()
+		f(m.view())
+	}
+}
+
+func (m *manifest) view() *indexView {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &indexView{m: m, index: m.index}
+}
+
+// rebuildIndex replaces a cached index that turned out not to match the
+// manifest, which happens if the manifest was rewritten without changing its
+// size and modification time, or if the cache is corrupt.
+func (m *manifest) rebuildIndex() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rebuilt {
+		return
+	}
+	m.index = buildIndex(m.data, int64(binary.LittleEndian.Uint64(m.index[16:])))
+	m.rebuilt = true
+	if m.cache != "" {
+		writeIndexCache(m.cache, m.index)
+	}
+}
+
+// indexView looks up lines through the index of a manifest and records
+// whether it used an offset that isn't the start of a line or found links
+// out of order.  Offsets of cached indexes are only checked this way, as
+// they are used.
+type indexView struct {
+	m     *manifest
+	index manifestIndex
+	stale bool
+}
+
+func (v *indexView) len() int {
+	return v.index.len()
+}
+
+// link returns the runfiles path of the i-th line in sorted order.
+func (v *indexView) link(i int) []byte {
+	return v.m.link(v.offset(i))
+}
+
+func (v *indexView) offset(i int) int {
+	offset := v.index.offset(i)
+	if offset < 0 || offset >= len(v.m.data) || (offset > 0 && v.m.data[offset-1] != '\n') {
+		v.stale = true
+		return -1
+	}
+	return offset
+}
+
+// search returns the smallest i for which the link of the i-th line is
+// greater than or equal to key, or greater than key if after is true.
+func (v *indexView) search(key []byte, after bool) int {
+	n := v.len()
+	i := sort.Search(n, func(i int) bool {
+		c := bytes.Compare(v.link(i), key)
+		return c > 0 || (c == 0 && !after)
+	})
+	if i > 0 && i < n && bytes.Compare(v.link(i-1), v.link(i)) > 0 {
+		v.stale = true
+	}
+	return i
+}
+
+// find returns the offset of the line for the runfiles path s.  If there are
+// several, the last one in the manifest wins.
+func (v *indexView) find(s string) (int, bool) {
+	key := []byte(s)
+	i := v.search(key, true)
+	if i > 0 && bytes.Equal(v.link(i-1), key) {
+		return v.offset(i - 1), true
+	}
+	return 0, false
+}
+
+// line returns the line of the manifest at the given offset, without the
+// line terminator.
+func (m *manifest) line(offset int) []byte {
+	if offset < 0 || offset >= len(m.data) {
+		return nil
+	}
+	line := m.data[offset:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+var (
+	linkUnescaper   = strings.NewReplacer(`\s`, " ", `\n`, "\n", `\b`, `\`)
+	targetUnescaper = strings.NewReplacer(`\n`, "\n", `\b`, `\`)
+)
+
+// link returns the runfiles path of the line at the given offset.
+func (m *manifest) link(offset int) []byte {
+	line := m.line(offset)
+	if len(line) > 0 && line[0] == ' ' {
+		// In lines that start with a space, spaces, newlines, and backslashes are escaped as \s, \n, and \b in
+		// link and newlines and backslashes are escaped in target.
+		link, _, _ := bytes.Cut(line[1:], []byte(" "))
+		return []byte(linkUnescaper.Replace(string(link)))
 	}
+	link, _, _ := bytes.Cut(line, []byte(" "))
+	return link
+}
 
-	if err := s.Err(); err != nil {
-		return manifest{}, fmt.Errorf("runfiles: error parsing manifest file %s: %w", f, err)
+// target returns the file the line at the given offset maps its link to, or
+// "" for an empty runfile.
+func (m *manifest) target(offset int) string {
+	line := m.line(offset)
+	if len(line) > 0 && line[0] == ' ' {
+		_, target, _ := bytes.Cut(line[1:], []byte(" "))
+		return filepath.FromSlash(targetUnescaper.Replace(string(target)))
 	}
+	_, target, _ := bytes.Cut(line, []byte(" "))
+	return filepath.FromSlash(string(target))
+}
+
+// find returns the offset of the line for the runfiles path s.  If there are
+// several, the last one in the manifest wins.
+func (m *manifest) find(s string) (offset int, ok bool) {
+	m.withIndex(func(v *indexView) { offset, ok = v.find(s) })
+	return offset, ok
+}
 
-	return m, nil
+// forEachLink calls f with the runfiles path of each line, in sorted order.
+func (m *manifest) forEachLink(f func(string)) {
+	var links []string
+	m.withIndex(func(v *indexView) {
+		links = links[:0]
+		for i, n := 0, v.len(); i < n; i++ {
+			links = append(links, string(v.link(i)))
+		}
+	})
+	for _, link := range links {
+		f(link)
+	}
 }
 
 func (m *manifest) path(s string) (string, error) {
-	r, ok := m.index[s]
-	if ok && r == "" {
+	if offset, ok := m.find(s); ok {
+		if r := m.target(offset); r != "" {
+			return r, nil
+		}
 		return "", ErrEmpty
 	}
-	if ok {
-		return r, nil
-	}
 
 	// If path references a runfile that lies under a directory that itself is a
 	// runfile, then only the directory is listed in the manifest. Look up all
 	// prefixes of path in the manifest.
 	for prefix := s; prefix != ""; prefix, _ = path.Split(prefix) {
 		prefix = strings.TrimSuffix(prefix, "/")
-		if prefixMatch, ok := m.index[prefix]; ok {
-			return prefixMatch + filepath.FromSlash(strings.TrimPrefix(s, prefix)), nil
+		if offset, ok := m.find(prefix); ok {
+			return m.target(offset) + filepath.FromSlash(strings.TrimPrefix(s, prefix)), nil
 		}
 	}
 
@@ -133,7 +272,7 @@ func (m *manifest) open(name string) (fs.File, error) {
 	if name != "." {
 		r, err := m.path(name)
 		if err == ErrEmpty {
-			return emptyFile(name), nil
+			return emptyFile(path.Base(name)), nil
 		} else if err == nil {
 			// name refers to an actual file or dir listed in the manifest. The
 			// basename of name may not match the basename of the underlying
@@ -148,53 +287,62 @@ func (m *manifest) open(name string) (fs.File, error) {
 			return nil, err
 		}
 		// err == os.ErrNotExist, but name may still refer to a directory that
-		// is a prefix of some manifest entry. We fall back to a trie lookup.
-	}
-
-	// At this point the file is not directly listed in the manifest (or
-	// contained in a directory that is). We lazily build a trie to allow
-	// efficient listing of the contents of intermediate directories.
-	if m.trie == nil {
-		m.trie = make(map[string]trie)
-		for k := range m.index {
-			segments := strings.Split(k, "/")
-			current := m.trie
-			for _, s := range segments {
-				if current[s] == nil {
-					current[s] = make(map[string]trie)
-				}
-				current = current[s]
-			}
-		}
+		// is a prefix of some manifest entry.
+	}
+
+	entries := m.readDir(name)
+	if name != "." && len(entries) == 0 {
+		return nil, os.ErrNotExist
 	}
+	return &manifestReadDirFile{dirFile(path.Base(name)), entries}, nil
+}
 
-	dir := m.trie
+// readDir returns the entries of a directory that is not listed in the
+// manifest, but is a prefix of the runfiles paths of some entries.
+func (m *manifest) readDir(name string) (entries []manifestDirEntry) {
+	m.withIndex(func(v *indexView) { entries = v.readDir(name) })
+	return entries
+}
+
+func (v *indexView) readDir(name string) []manifestDirEntry {
+	prefix := ""
 	if name != "." {
-		segments := strings.Split(name, "/")
-		for _, s := range segments {
-			if dir == nil {
-				break
-			}
-			dir = dir[s]
+		prefix = name + "/"
+	}
+	// The runfiles paths starting with prefix are contiguous in the index,
+	// but not the paths of the same child: "a-b" sorts between "a" and
+	// "a/b".
+	n := v.len()
+	i := v.search([]byte(prefix), false)
+	seen := make(map[string]bool)
+	var entries []manifestDirEntry
+	for ; i < n; i++ {
+		link := v.link(i)
+		if !bytes.HasPrefix(link, []byte(prefix)) {
+			break
 		}
-		if dir == nil {
-			return nil, os.ErrNotExist
+		child, _, _ := bytes.Cut(link[len(prefix):], []byte("/"))
+		if len(child) == 0 || seen[string(child)] {
+			continue
 		}
-	}
-
-	entries := make([]manifestDirEntry, 0, len(dir))
-	for e := range dir {
-		entries = append(entries, manifestDirEntry{e, m.index[path.Join(name, e)]})
+		seen[string(child)] = true
+		e := manifestDirEntry{name: string(child)}
+		if offset, ok := v.find(prefix + e.name); ok {
+			e.path = v.m.target(offset)
+			e.empty = e.path == ""
+		}
+		entries = append(entries, e)
 	}
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].name < entries[j].name
 	})
-	return &manifestReadDirFile{dirFile(path.Base(name)), entries}, nil
+	return entries
 }
 
 type manifestDirEntry struct {
-	name string
-	path string
+	name  string
+	path  string
+	empty bool // whether the entry is an empty runfile
 }
 
 type manifestReadDirFile struct {
@@ -215,7 +363,9 @@ func (m *manifestReadDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
 	dirEntries := make([]fs.DirEntry, 0, len(entries))
 	for _, e := range entries {
 		var info fs.FileInfo
-		if e.path == "" {
+		if e.empty {
+			info = emptyFileInfo(e.name)
+		} else if e.path == "" {
 			// The entry corresponds to a directory that is a prefix of some
 			// manifest entry. We represent it as a read-only directory.
 			info = dirFileInfo(e.name)
@@ -0,0 +1,170 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// indexCacheDirVar is the environmental variable that names the
+	// directory the indexes of manifests are cached in instead of next to
+	// the manifests, if any.
+	indexCacheDirVar = "RUNFILES_INDEX_CACHE_DIR"
+
+	// indexCacheSuffix is appended to the path of a manifest, or to the hash
+	// of that path in the cache directory, to obtain the path of the cached
+	// index of its lines.
+	indexCacheSuffix = ".index"
+
+	indexMagic      = "rfindex3"
+	indexHeaderSize = 32
+)
+
+// manifestIndex holds the offsets of the non-empty lines of a manifest,
+// sorted by link.  Lines with the same link keep their order in the
+// manifest.  It is stored in the same format as cached indexes, which can
+// thus be mapped into memory and used without decoding them:
+//
+//	magic           [8]byte
+//	manifest size   uint64
+//	manifest mtime  int64 (nanoseconds since the Unix epoch)
+//	number of lines uint64
+//	line offsets    [number of lines]uint64
+//
+// All numbers are little endian.
+type manifestIndex []byte
+
+func (x manifestIndex) len() int {
+	if len(x) < indexHeaderSize {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint64(x[24:]))
+}
+
+func (x manifestIndex) offset(i int) int {
+	return int(binary.LittleEndian.Uint64(x[indexHeaderSize+8*i:]))
+}
+
+// matches reports whether x is an index of a manifest with the given size
+// and modification time.  Only the header is checked, so that using a cached
+// index doesn't take time proportional to the size of the manifest; the
+// offsets are checked as lookups use them, see indexView.
+func (x manifestIndex) matches(size, modTime int64) bool {
+	if len(x) < indexHeaderSize || string(x[:8]) != indexMagic {
+		return false
+	}
+	n := binary.LittleEndian.Uint64(x[24:])
+	return binary.LittleEndian.Uint64(x[8:]) == uint64(size) &&
+		int64(binary.LittleEndian.Uint64(x[16:])) == modTime &&
+		n <= uint64(size) &&
+		uint64(len(x)) == indexHeaderSize+8*n
+}
+
+// indexCachePath returns the path of the cached index of the given
+// manifest: the manifest path with an ".index" suffix, or a file named after
+// the hash of the absolute manifest path in RUNFILES_INDEX_CACHE_DIR if that
+// is set.
+func indexCachePath(manifest string) string {
+	dir := os.Getenv(indexCacheDirVar)
+	if dir == "" {
+		return manifest + indexCacheSuffix
+	}
+	if abs, err := filepath.Abs(manifest); err == nil {
+		manifest = abs
+	}
+	sum := sha256.Sum256([]byte(manifest))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+indexCacheSuffix)
+}
+
+// loadIndex returns the index of the manifest with the given contents and
+// modification time.  If cache isn't empty, the index is read from that
+// file if its header matches the manifest, and written to it otherwise.
+// Failing to write the cache, e.g. because the manifest is in a read-only
+// directory, isn't an error.
+func loadIndex(cache string, data []byte, modTime int64) manifestIndex {
+	if cache != "" {
+		if b, _, err := readFile(cache); err == nil && manifestIndex(b).matches(int64(len(data)), modTime) {
+			return b
+		}
+	}
+	x := buildIndex(data, modTime)
+	if cache != "" {
+		writeIndexCache(cache, x)
+	}
+	return x
+}
+
+// buildIndex indexes the lines of the manifest with the given contents and
+// modification time.  Manifests written by Bazel are already sorted, so this usually takes
+// a single pass over the manifest.
+func buildIndex(data []byte, modTime int64) manifestIndex {
+	m := &manifest{data: data}
+	var offsets []int
+	for offset := 0; offset < len(data); {
+		if len(m.line(offset)) > 0 {
+			offsets = append(offsets, offset)
+		}
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		offset += i + 1
+	}
+	less := func(i, j int) bool {
+		return bytes.Compare(m.link(offsets[i]), m.link(offsets[j])) < 0
+	}
+	if !sort.SliceIsSorted(offsets, less) {
+		sort.SliceStable(offsets, less)
+	}
+
+	x := make(manifestIndex, indexHeaderSize+8*len(offsets))
+	copy(x, indexMagic)
+	binary.LittleEndian.PutUint64(x[8:], uint64(len(data)))
+	binary.LittleEndian.PutUint64(x[16:], uint64(modTime))
+	binary.LittleEndian.PutUint64(x[24:], uint64(len(offsets)))
+	for i, offset := range offsets {
+		binary.LittleEndian.PutUint64(x[indexHeaderSize+8*i:], uint64(offset))
+	}
+	return x
+}
+
+// writeIndexCache atomically replaces the cache file with the given index,
+// so that concurrent readers never see a partial index.
+func writeIndexCache(cache string, x manifestIndex) {
+	if err := os.MkdirAll(filepath.Dir(cache), 0o777); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(cache), filepath.Base(cache)+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(x)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), cache)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Manifest is an [Option] that uses an in-memory runfiles manifest instead of
+// discovering the runfiles.  It maps runfiles paths, with canonical repository
+// names, to filesystem paths, or to "" for empty runfiles.  As in manifest
+// files, a runfiles path may map to a directory, in which case all files in
+// it are runfiles.  The runfiles have no environment variables to pass to
+// subprocesses.
+//
+// Manifest is mostly useful in tests of code that uses runfiles: combine it
+// with [RepoMapping] to simulate Bzlmod.
+type Manifest map[string]string
+
+func (m Manifest) new(sourceRepo SourceRepo) (*Runfiles, error) {
+	links := make([]string, 0, len(m))
+	for link := range m {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+	var b strings.Builder
+	for _, link := range links {
+		b.WriteString(manifestLine(link, m[link]))
+	}
+	data := []byte(b.String())
+	r := &Runfiles{
+		impl:       &manifest{data: data, index: buildIndex(data, 0)},
+		sourceRepo: string(sourceRepo),
+	}
+	err := r.loadRepoMapping()
+	return r, err
+}
+
+// FS returns an [Option] that uses the given file system as the runfiles
+// directory instead of discovering the runfiles, for example a
+// [testing/fstest.MapFS].  Since the files of fsys may not exist on disk,
+// [Runfiles.Rlocation] copies the runfiles it returns, and all files in them
+// if they are directories, to a temporary directory that is not removed.
+// A file named "_repo_mapping" in fsys is used as the repository mapping,
+// unless the [RepoMapping] option is given.  The runfiles have no
+// environment variables to pass to subprocesses.
+//
+// FS is mostly useful in tests of code that uses runfiles.
+func FS(fsys fs.FS) Option {
+	return fsOption{fsys}
+}
+
+type fsOption struct {
+	fsys fs.FS
+}
+
+func newFS(fsys fs.FS, sourceRepo SourceRepo) (*Runfiles, error) {
+	r := &Runfiles{
+		impl:       &fsRunfiles{fsys: fsys, copied: make(map[string]bool)},
+		sourceRepo: string(sourceRepo),
+	}
+	err := r.loadRepoMapping()
+	return r, err
+}
+
+// fsRunfiles are runfiles in a file system, which are copied to disk when
+// their paths are needed.
+type fsRunfiles struct {
+	fsys fs.FS
+
+	// mu guards the fields below.
+	mu     sync.Mutex
+	dir    string          // the temporary directory runfiles are copied to
+	copied map[string]bool // the runfiles that were copied
+}
+
+func (f *fsRunfiles) path(s string) (string, error) {
+	if !fs.ValidPath(s) {
+		return "", os.ErrNotExist
+	}
+	if _, err := fs.Stat(f.fsys, s); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", os.ErrNotExist
+		}
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		dir, err := os.MkdirTemp("", "runfiles")
+		if err != nil {
+			return "", err
+		}
+		f.dir = dir
+	}
+	p := filepath.Join(f.dir, filepath.FromSlash(s))
+	if f.copied[s] {
+		return p, nil
+	}
+	if err := f.copy(s); err != nil {
+		return "", fmt.Errorf("copying runfile to %s: %w", f.dir, err)
+	}
+	f.copied[s] = true
+	return p, nil
+}
+
+// copy copies the runfile s, with all files in it if it is a directory, to
+// f.dir.
+func (f *fsRunfiles) copy(s string) error {
+	if err := os.MkdirAll(filepath.Join(f.dir, filepath.FromSlash(path.Dir(s))), 0755); err != nil {
+		return err
+	}
+	return fs.WalkDir(f.fsys, s, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(f.dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(p, 0755)
+		}
+		src, err := f.fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		mode := fs.FileMode(0644)
+		if info, err := src.Stat(); err == nil && info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		dst, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
+
+func (f *fsRunfiles) open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
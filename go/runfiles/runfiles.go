@@ -24,7 +24,9 @@
 // Most users should use the [Rlocation] and [Env] functions directly to access
 // individual runfiles.  Use [Rlocation] to find the filesystem location of a
 // runfile, and use [Env] to obtain environmental variables to pass on to
-// subprocesses that themselves may need to access runfiles.
+// subprocesses that themselves may need to access runfiles.  Use
+// [RlocationLabel] to find a runfile by the label of the target that builds or
+// provides it instead of its runfiles path.
 //
 // The [New] function returns a [Runfiles] object that implements [fs.FS].
 // This allows more complex operations on runfiles, such as iterating over all
@@ -39,7 +41,23 @@
 //
 // If you need to look up runfiles in a custom way (e.g., you use them for a
 // packaged application or one that is available on PATH), you can pass
-// [Option] values to [New] to force a specific runfiles location.
+// [Option] values to [New] to force a specific runfiles location.  To test
+// code that uses runfiles without Bazel, pass the [Manifest] or [FS] option
+// with in-memory runfiles, and [RepoMapping] to simulate Bzlmod.
+//
+// To deploy runfiles, including runfiles only listed in a manifest, write them
+// to a self-contained directory or archive with [Runfiles.WriteDirectory],
+// [Runfiles.WriteTar] or [Runfiles.WriteZip], or with the runfilesbundle tool
+// in go/tools/runfilesbundle.  The result can be used with the [Directory]
+// option on another machine.
+//
+// # Diagnosing failed lookups
+//
+// If a runfile can't be found, [Runfiles.Explain] reports how the runfiles
+// were discovered, how the repository mapping applied to the runfiles path,
+// and similar runfiles that exist, such as the same file in another
+// repository.  Set the environmental variable RUNFILES_EXPLAIN to 1 to
+// include this report in the errors of failed lookups, e.g. in CI.
 //
 // # Restrictions
 //
@@ -86,6 +104,8 @@ type Runfiles struct {
 	env         []string
 	repoMapping *repoMapping
 	sourceRepo  string
+	discovery   string // how New found the runfiles, for Explain
+	explain     bool   // whether lookup errors contain an Explanation
 }
 
 const noSourceRepoSentinel = "_not_a_valid_repository_name"
@@ -107,18 +127,43 @@ func New(opts ...Option) (*Runfiles, error) {
 		o.sourceRepo = SourceRepo(CallerRepository())
 	}
 
+	r, err := o.new()
+	if err != nil {
+		return nil, err
+	}
+	if o.repoMapping != nil {
+		r.repoMapping = o.repoMapping.build()
+	}
+	r.explain = explainEnabled()
+	return r, nil
+}
+
+// new creates a [Runfiles] object from the given options, as described in
+// [New].
+func (o options) new() (*Runfiles, error) {
+	if o.fsys != nil {
+		return discovered("FS option")(newFS(o.fsys, o.sourceRepo))
+	}
+	if o.manifestMap != nil {
+		return discovered("Manifest option")(o.manifestMap.new(o.sourceRepo))
+	}
+
+	source := "ManifestFile option"
 	if o.manifest == "" {
 		o.manifest = ManifestFile(os.Getenv(manifestFileVar))
+		source = manifestFileVar
 	}
 	if o.manifest != "" {
-		return o.manifest.new(o.sourceRepo)
+		return discovered("manifest file %s from %s", o.manifest, source)(o.manifest.new(o.sourceRepo))
 	}
 
+	source = "Directory option"
 	if o.directory == "" {
 		o.directory = Directory(os.Getenv(directoryVar))
+		source = directoryVar
 	}
 	if o.directory != "" {
-		return o.directory.new(o.sourceRepo)
+		return discovered("runfiles directory %s from %s", o.directory, source)(o.directory.new(o.sourceRepo))
 	}
 
 	if o.program == "" {
@@ -140,17 +185,31 @@ func New(opts ...Option) (*Runfiles, error) {
 	}
 	manifest := ManifestFile(o.program + ".runfiles_manifest")
 	if stat, err := os.Stat(string(manifest)); err == nil && stat.Mode().IsRegular() {
-		return manifest.new(o.sourceRepo)
+		return discovered("manifest file %s next to program %s", manifest, o.program)(manifest.new(o.sourceRepo))
 	}
 
 	dir := Directory(o.program + ".runfiles")
 	if stat, err := os.Stat(string(dir)); err == nil && stat.IsDir() {
-		return dir.new(o.sourceRepo)
+		return discovered("runfiles directory %s next to program %s", dir, o.program)(dir.new(o.sourceRepo))
 	}
 
+	if explainEnabled() {
+		return nil, fmt.Errorf("runfiles: no runfiles found: %s and %s are unset, and neither %s nor %s exists", manifestFileVar, directoryVar, manifest, dir)
+	}
 	return nil, errors.New("runfiles: no runfiles found")
 }
 
+// discovered returns a function that records how [New] found the runfiles it
+// returns, for [Runfiles.Explain].
+func discovered(format string, args ...interface{}) func(*Runfiles, error) (*Runfiles, error) {
+	return func(r *Runfiles, err error) (*Runfiles, error) {
+		if r != nil {
+			r.discovery = fmt.Sprintf(format, args...)
+		}
+		return r, err
+	}
+}
+
 // Rlocation returns the (relative or absolute) path name of a runfile.
 // The runfile name must be a runfile-root relative path, using the slash (not
 // backslash) as directory separator. It is typically of the form
@@ -183,20 +242,25 @@ func (r *Runfiles) Rlocation(path string) (string, error) {
 		return path, nil
 	}
 
-	mappedPath := path
+	mappedPath := r.mapPath(path)
+	p, err := r.impl.path(mappedPath)
+	if err != nil {
+		return "", r.lookupError(path, mappedPath, r.describeMapping(path), err)
+	}
+	return p, nil
+}
+
+// mapPath applies the repository mapping of the source repository to a
+// runfiles path.
+func (r *Runfiles) mapPath(path string) string {
 	split := strings.SplitN(path, "/", 2)
 	if len(split) == 2 {
 		key := repoMappingKey{r.sourceRepo, split[0]}
 		if targetRepoDirectory, exists := r.repoMapping.Get(key); exists {
-			mappedPath = targetRepoDirectory + "/" + split[1]
+			return targetRepoDirectory + "/" + split[1]
 		}
 	}
-
-	p, err := r.impl.path(mappedPath)
-	if err != nil {
-		return "", Error{path, err}
-	}
-	return p, nil
+	return path
 }
 
 func isNormalizedPath(s string) error {
@@ -275,10 +339,17 @@ type Error struct {
 
 	// Underlying error.
 	Err error
+
+	// Explanation of the failed lookup if the environmental variable
+	// RUNFILES_EXPLAIN is set to 1, or nil.
+	Explanation *Explanation
 }
 
 // Error implements [error.Error].
 func (e Error) Error() string {
+	if e.Explanation != nil {
+		return fmt.Sprintf("runfile %s: %s\n%s", e.Name, e.Err.Error(), e.Explanation)
+	}
 	return fmt.Sprintf("runfile %s: %s", e.Name, e.Err.Error())
 }
 
@@ -290,16 +361,28 @@ func (e Error) Unwrap() error { return e.Err }
 var ErrEmpty = errors.New("empty runfile")
 
 type options struct {
-	program    ProgramName
-	manifest   ManifestFile
-	directory  Directory
-	sourceRepo SourceRepo
+	program     ProgramName
+	manifest    ManifestFile
+	manifestMap Manifest
+	directory   Directory
+	fsys        fs.FS
+	sourceRepo  SourceRepo
+	repoMapping RepoMapping
 }
 
 func (p ProgramName) apply(o *options)  { o.program = p }
 func (m ManifestFile) apply(o *options) { o.manifest = m }
+func (m Manifest) apply(o *options)     { o.manifestMap = m }
 func (d Directory) apply(o *options)    { o.directory = d }
+func (f fsOption) apply(o *options)     { o.fsys = f.fsys }
 func (sr SourceRepo) apply(o *options)  { o.sourceRepo = sr }
+func (rm RepoMapping) apply(o *options) {
+	if rm == nil {
+		// A nil RepoMapping still replaces the repository mapping.
+		rm = RepoMapping{}
+	}
+	o.repoMapping = rm
+}
 
 type runfiles interface {
 	path(string) (string, error)
@@ -335,6 +418,12 @@ func (rm *repoMapping) Get(key repoMappingKey) (string, bool) {
 	return "", false
 }
 
+// isEmpty reports whether rm maps no repositories, which is the case without
+// Bzlmod.
+func (rm *repoMapping) isEmpty() bool {
+	return len(rm.exactMappings) == 0 && len(rm.prefixMappings) == 0
+}
+
 // ForEachVisible iterates over all target repositories that are visible to the
 // given source repo in an unspecified order.
 func (rm *repoMapping) ForEachVisible(sourceRepo string, f func(targetRepoApparentName, targetRepoDirectory string)) {
@@ -369,28 +458,57 @@ func parseRepoMapping(path string) (*repoMapping, error) {
 	// canonical name of source repo,apparent name of target repo,target repo runfiles directory
 	// https://cs.opensource.google/bazel/bazel/+/1b073ac0a719a09c9b2d1a52680517ab22dc971e:src/main/java/com/google/devtools/build/lib/analysis/RepoMappingManifestAction.java;l=117
 	s := bufio.NewScanner(r)
-	exactMappings := make(map[repoMappingKey]string)
-	prefixMappingsMap := make(map[string]map[string]string)
+	var entries RepoMapping
 	for s.Scan() {
 		fields := strings.SplitN(s.Text(), ",", 3)
 		if len(fields) != 3 {
 			return nil, fmt.Errorf("runfiles: bad repo mapping line %q in file %s", s.Text(), path)
 		}
-		if strings.HasSuffix(fields[0], "*") {
-			prefix := strings.TrimSuffix(fields[0], "*")
+		entries = append(entries, RepoMappingEntry{fields[0], fields[1], fields[2]})
+	}
+
+	if err = s.Err(); err != nil {
+		return nil, fmt.Errorf("runfiles: error parsing repo mapping file %s: %w", path, err)
+	}
+	return entries.build(), nil
+}
+
+// RepoMapping is an [Option] that replaces the repository mapping of the
+// runfiles, which is otherwise read from the runfiles themselves.  Use it with
+// the [Manifest] or [FS] options to simulate Bzlmod in tests.  An empty
+// RepoMapping disables the repository mapping, as without Bzlmod.
+type RepoMapping []RepoMappingEntry
+
+// RepoMappingEntry maps an apparent repository name, as seen from a source
+// repository, to the runfiles directory of the target repository, like a line
+// of the repository mapping manifest written by Bazel.
+type RepoMappingEntry struct {
+	// SourceRepo is the canonical name of the source repository.  If it
+	// ends with "*", the entry applies to all source repositories whose
+	// canonical name starts with the part before the "*".
+	SourceRepo string
+	// ApparentName is the apparent name of the target repository.
+	ApparentName string
+	// TargetRepo is the runfiles directory of the target repository, which
+	// is its canonical name or "_main" for the main repository.
+	TargetRepo string
+}
+
+func (rm RepoMapping) build() *repoMapping {
+	exactMappings := make(map[repoMappingKey]string)
+	prefixMappingsMap := make(map[string]map[string]string)
+	for _, e := range rm {
+		if strings.HasSuffix(e.SourceRepo, "*") {
+			prefix := strings.TrimSuffix(e.SourceRepo, "*")
 			if _, ok := prefixMappingsMap[prefix]; !ok {
 				prefixMappingsMap[prefix] = make(map[string]string)
 			}
-			prefixMappingsMap[prefix][fields[1]] = fields[2]
+			prefixMappingsMap[prefix][e.ApparentName] = e.TargetRepo
 		} else {
-			exactMappings[repoMappingKey{fields[0], fields[1]}] = fields[2]
+			exactMappings[repoMappingKey{e.SourceRepo, e.ApparentName}] = e.TargetRepo
 		}
 	}
 
-	if err = s.Err(); err != nil {
-		return nil, fmt.Errorf("runfiles: error parsing repo mapping file %s: %w", path, err)
-	}
-
 	// No prefix can be a prefix of another prefix, so we can use binary search
 	// on a sorted slice to find the unique prefix that may match a given source
 	// repo.
@@ -405,5 +523,5 @@ func parseRepoMapping(path string) (*repoMapping, error) {
 		return strings.Compare(a.prefix, b.prefix)
 	})
 
-	return &repoMapping{exactMappings, prefixMappings}, nil
+	return &repoMapping{exactMappings, prefixMappings}
 }
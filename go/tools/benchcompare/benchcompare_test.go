@@ -0,0 +1,166 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	for _, tc := range []struct {
+		x, y []float64
+		want float64
+	}{
+		{x: []float64{1, 2, 3, 4, 5}, y: []float64{6, 7, 8, 9, 10}, want: 0.0079365},
+		{x: []float64{6, 7, 8, 9, 10}, y: []float64{1, 2, 3, 4, 5}, want: 0.0079365},
+		{x: []float64{1, 3, 5, 7, 9}, y: []float64{2, 4, 6, 8, 10}, want: 0.6904762},
+		{x: []float64{10, 11, 12}, y: []float64{13, 14, 15, 16}, want: 0.0571429},
+		{x: []float64{3, 1, 2}, y: []float64{5, 4}, want: 0.2},
+		// Ties use the normal approximation.
+		{x: []float64{1, 2, 2, 3, 4}, y: []float64{3, 4, 5, 5, 6}, want: 0.0344536},
+		{x: []float64{1, 1}, y: []float64{1, 1}, want: 1},
+	} {
+		if got := mannWhitneyU(tc.x, tc.y); math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("mannWhitneyU(%v, %v) = %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestRemoveOutliers(t *testing.T) {
+	got := removeOutliers([]float64{10, 11, 10, 12, 50, 11})
+	if want := []float64{10, 11, 10, 12, 11}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+const oldResults = `goos: linux
+goarch: amd64
+pkg: example.com/foo
+BenchmarkEncode-8   	    1000	      1000 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1010 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	       990 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1020 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	       980 ns/op	     128 B/op	       2 allocs/op
+BenchmarkDecode-8   	    1000	       500 ns/op
+BenchmarkDecode-8   	    1000	       510 ns/op
+BenchmarkDecode-8   	    1000	       490 ns/op
+--- BENCH: BenchmarkDecode-8
+    foo_test.go:10: ignored
+BenchmarkRemoved-8  	    1000	       100 ns/op
+PASS
+`
+
+const newResults = `goos: linux
+goarch: amd64
+pkg: example.com/foo
+BenchmarkEncode-8   	    1000	      1200 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1210 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1190 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1220 ns/op	     128 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1180 ns/op	     128 B/op	       2 allocs/op
+BenchmarkDecode-8   	    1000	       505 ns/op
+BenchmarkDecode-8   	    1000	       495 ns/op
+BenchmarkDecode-8   	    1000	       500 ns/op
+BenchmarkAdded-8    	    1000	       100 ns/op
+`
+
+func writeResults(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(oldPath, []byte(oldResults), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte(newResults), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return oldPath, newPath
+}
+
+func TestRun(t *testing.T) {
+	oldPath, newPath := writeResults(t)
+	var out bytes.Buffer
+	if err := run([]string{oldPath, newPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `name                old ns/op   new ns/op   delta
+BenchmarkEncode-8   1.00k ± 2%  1.20k ± 2%  +20.00% (p=0.008 n=5+5)
+BenchmarkDecode-8   500 ± 2%    500 ± 1%    ~ (p=1.000 n=3+3)
+BenchmarkAdded-8                100 ± 0%
+BenchmarkRemoved-8  100 ± 0%
+
+name               old B/op  new B/op  delta
+BenchmarkEncode-8  128 ± 0%  128 ± 0%  ~ (p=1.000 n=5+5)
+
+name               old allocs/op  new allocs/op  delta
+BenchmarkEncode-8  2.00 ± 0%      2.00 ± 0%      ~ (p=1.000 n=5+5)
+`
+	if got := trimLines(out.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMaxRegression(t *testing.T) {
+	oldPath, newPath := writeResults(t)
+	var out bytes.Buffer
+	if err := run([]string{"-max_regression=25", oldPath, newPath}, &out); err != nil {
+		t.Errorf("unexpected error with a smaller regression: %v", err)
+	}
+	err := run([]string{"-max_regression=10", oldPath, newPath}, &out)
+	if err == nil || !strings.Contains(err.Error(), "BenchmarkEncode-8 ns/op: +20.00%") {
+		t.Fatalf("got error %v, want a regression of BenchmarkEncode-8", err)
+	}
+	// Regressions of the decoding time are not significant.
+	if strings.Contains(err.Error(), "BenchmarkDecode") {
+		t.Errorf("unexpected regression of BenchmarkDecode-8: %v", err)
+	}
+}
+
+func TestNoCommonBenchmarks(t *testing.T) {
+	oldPath, _ := writeResults(t)
+	emptyPath := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(emptyPath, []byte("PASS\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{oldPath, emptyPath}, &out); err == nil || !strings.Contains(err.Error(), "no benchmarks in common") {
+		t.Errorf("got error %v, want no benchmarks in common", err)
+	}
+}
+
+// trimLines removes the trailing spaces of the lines in s.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,453 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// benchcompare compares two files of Go benchmark results, such as the
+// benchmarks.txt files written by go_test to its undeclared outputs, and
+// prints a table of the changes like benchstat.
+//
+// Usage:
+//
+//	bazel run @io_bazel_rules_go//go/tools/benchcompare -- old.txt new.txt
+//
+// For each benchmark and unit, the mean of the runs in each file is reported
+// after removing outliers, along with the largest deviation from the mean.
+// Changes are reported with the p-value of a Mann-Whitney U-test and marked
+// with ~ if they are not significant. With -max_regression, benchcompare
+// exits with status 1 if the time, bytes or allocations per operation of a
+// benchmark increase significantly by more than the given percentage.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// regressionUnits are the units that -max_regression applies to. Smaller
+// values are better for all of them.
+var regressionUnits = map[string]bool{
+	"ns/op":     true,
+	"sec/op":    true,
+	"B/op":      true,
+	"allocs/op": true,
+}
+
+// key identifies the values of one unit of one benchmark.
+type key struct {
+	pkg, name, unit string
+}
+
+// results holds the values of the benchmarks in a file.
+type results struct {
+	values map[key][]float64
+	keys   []key // in the order in which they first appear
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("benchcompare: ")
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("benchcompare", flag.ContinueOnError)
+	alpha := fs.Float64("alpha", 0.05, "consider changes significant if p < `α`")
+	maxRegression := fs.Float64("max_regression", -1, "fail if a benchmark regresses significantly by more than `percent`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: benchcompare [flags] old.txt new.txt\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two files, got %d", fs.NArg())
+	}
+	before, err := readFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := readFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	rows := compare(before, after, *alpha)
+	common := false
+	for _, r := range rows {
+		common = common || (!r.oldMissing && !r.newMissing)
+	}
+	if !common {
+		return fmt.Errorf("no benchmarks in common between %s and %s", fs.Arg(0), fs.Arg(1))
+	}
+	printTable(stdout, rows)
+	if *maxRegression >= 0 {
+		var regressions []string
+		for _, r := range rows {
+			if regressionUnits[r.unit] && r.significant && r.delta*100 > *maxRegression {
+				regressions = append(regressions, fmt.Sprintf("%s %s: %+.2f%%", r.name, r.unit, r.delta*100))
+			}
+		}
+		if len(regressions) > 0 {
+			return fmt.Errorf("benchmarks regressed by more than %g%%:\n\t%s", *maxRegression, strings.Join(regressions, "\n\t"))
+		}
+	}
+	return nil
+}
+
+// readFile reads a file of benchmark results. Relative paths are resolved
+// against the directory bazel run was invoked from.
+func readFile(path string) (*results, error) {
+	if wd := os.Getenv("BUILD_WORKING_DIRECTORY"); wd != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// parse reads benchmark results in the Go benchmark data format: results
+// like "BenchmarkName-8  1000  1234 ns/op  56 B/op" preceded by
+// configuration lines like "pkg: example.com/foo". Other lines are ignored.
+func parse(r io.Reader) (*results, error) {
+	res := &results{values: make(map[key][]float64)}
+	var pkg string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimSpace(line[len("pkg: "):])
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 || !isBenchmarkName(fields[0]) {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			k := key{pkg: pkg, name: fields[0], unit: fields[i+1]}
+			if _, ok := res.values[k]; !ok {
+				res.keys = append(res.keys, k)
+			}
+			res.values[k] = append(res.values[k], v)
+		}
+	}
+	return res, s.Err()
+}
+
+// isBenchmarkName reports whether s is the name of a benchmark, that is
+// "Benchmark" not followed by a lower-case letter.
+func isBenchmarkName(s string) bool {
+	if !strings.HasPrefix(s, "Benchmark") {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[len("Benchmark"):])
+	return !unicode.IsLower(r)
+}
+
+// row is the comparison of one unit of one benchmark.
+type row struct {
+	name, unit             string
+	old, new               stats
+	delta                  float64 // the relative change of the mean
+	p                      float64
+	significant            bool
+	oldMissing, newMissing bool
+}
+
+type stats struct {
+	n         int
+	mean, dev float64 // dev is the largest relative deviation from the mean
+}
+
+// compare compares the benchmarks in before and after, in the order in which
+// they appear in after, followed by those only in before.
+func compare(before, after *results, alpha float64) []row {
+	keys := append([]key(nil), after.keys...)
+	for _, k := range before.keys {
+		if _, ok := after.values[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	// Only show the package if the files have benchmarks of several packages.
+	pkgs := make(map[string]bool)
+	for _, k := range keys {
+		pkgs[k.pkg] = true
+	}
+	var rows []row
+	for _, k := range keys {
+		oldValues, newValues := removeOutliers(before.values[k]), removeOutliers(after.values[k])
+		r := row{
+			name:       k.name,
+			unit:       k.unit,
+			old:        summarize(oldValues),
+			new:        summarize(newValues),
+			oldMissing: len(oldValues) == 0,
+			newMissing: len(newValues) == 0,
+			p:          1,
+		}
+		if len(pkgs) > 1 {
+			r.name = k.pkg + "." + k.name
+		}
+		if !r.oldMissing && !r.newMissing {
+			if r.old.mean != 0 {
+				r.delta = r.new.mean/r.old.mean - 1
+			}
+			r.p = mannWhitneyU(oldValues, newValues)
+			r.significant = r.p < alpha && r.new.mean != r.old.mean
+		}
+		rows = append(rows, r)
+	}
+	// Group rows by unit, keeping the order of benchmarks.
+	sort.SliceStable(rows, func(i, j int) bool {
+		return unitOrder(rows[i].unit, keys) < unitOrder(rows[j].unit, keys)
+	})
+	return rows
+}
+
+// unitOrder returns the index of the first key with the given unit.
+func unitOrder(unit string, keys []key) int {
+	for i, k := range keys {
+		if k.unit == unit {
+			return i
+		}
+	}
+	return len(keys)
+}
+
+// removeOutliers returns the values within 1.5 interquartile ranges of the
+// first and third quartiles, like benchstat.
+func removeOutliers(values []float64) []float64 {
+	if len(values) < 4 {
+		return values
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	lo, hi := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	var kept []float64
+	for _, v := range values {
+		if lo <= v && v <= hi {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// quantile returns the q-th quantile of sorted values, interpolating between
+// the closest values.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+func summarize(values []float64) stats {
+	s := stats{n: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.mean += v
+	}
+	s.mean /= float64(len(values))
+	if s.mean == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.dev = math.Max(s.dev, math.Abs(v-s.mean)/math.Abs(s.mean))
+	}
+	return s
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U-test of
+// whether x and y are samples of the same distribution. The exact
+// distribution of U is used for samples without ties, and the normal
+// approximation with a tie correction otherwise.
+func mannWhitneyU(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	type sample struct {
+		v float64
+		x bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign ranks, giving tied values the average of their ranks.
+	var rankSum, tieCorrection float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].x {
+				rankSum += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieCorrection += t*t*t - t
+		}
+		i = j
+	}
+	u := rankSum - float64(n1*(n1+1))/2
+	mean := float64(n1*n2) / 2
+
+	if !ties && n1*n2 <= 10000 {
+		// P(U <= u) for the smaller tail, doubled.
+		tail := math.Min(u, float64(n1*n2)-u)
+		return math.Min(1, 2*uCDF(n1, n2, int(tail)))
+	}
+	n := float64(n1 + n2)
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieCorrection/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Apply a continuity correction.
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		return 1
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// uCDF returns P(U <= u) for samples of sizes n1 and n2 without ties, by
+// counting the arrangements of the samples with each value of U.
+func uCDF(n1, n2, u int) float64 {
+	// After the m-th iteration, counts[i][j] is the number of arrangements
+	// of i values of the first sample and m values of the second one with
+	// U = j. If the largest value is from the first sample, it adds m to U,
+	// otherwise it adds nothing:
+	//   f(i, m, j) = f(i-1, m, j-m) + f(i, m-1, j)
+	counts := make([][]float64, n1+1)
+	for i := range counts {
+		counts[i] = make([]float64, u+1)
+		counts[i][0] = 1
+	}
+	for m := 1; m <= n2; m++ {
+		for i := 1; i <= n1; i++ {
+			for j := m; j <= u; j++ {
+				counts[i][j] += counts[i-1][j-m]
+			}
+		}
+	}
+	total := 0.0
+	for j := 0; j <= u; j++ {
+		total += counts[n1][j]
+	}
+	return total / binomial(n1+n2, n1)
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+// printTable prints the rows in tables of one unit each, like
+//
+//	name      old ns/op   new ns/op   delta
+//	Encode-8  1.20k ± 3%  1.00k ± 2%  -16.67% (p=0.008 n=5+5)
+func printTable(w io.Writer, rows []row) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, r := range rows {
+		if i == 0 || rows[i-1].unit != r.unit {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+			fmt.Fprintf(tw, "name\told %s\tnew %s\tdelta\t\n", r.unit, r.unit)
+		}
+		var delta string
+		switch {
+		case r.oldMissing || r.newMissing:
+			delta = ""
+		case !r.significant:
+			delta = fmt.Sprintf("~ (p=%.3f n=%d+%d)", r.p, r.old.n, r.new.n)
+		default:
+			delta = fmt.Sprintf("%+.2f%% (p=%.3f n=%d+%d)", r.delta*100, r.p, r.old.n, r.new.n)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", r.name, formatStats(r.old, r.oldMissing), formatStats(r.new, r.newMissing), delta)
+	}
+	tw.Flush()
+}
+
+func formatStats(s stats, missing bool) string {
+	if missing {
+		return ""
+	}
+	return fmt.Sprintf("%s ± %.0f%%", formatValue(s.mean), s.dev*100)
+}
+
+// formatValue formats v with three significant digits and an SI prefix.
+func formatValue(v float64) string {
+	prefix := ""
+	for _, p := range []struct {
+		scale  float64
+		prefix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if math.Abs(v) >= p.scale {
+			v, prefix = v/p.scale, p.prefix
+			break
+		}
+	}
+	switch a := math.Abs(v); {
+	case a == 0 || a >= 99.95:
+		return fmt.Sprintf("%.0f%s", v, prefix)
+	case a >= 9.995:
+		return fmt.Sprintf("%.1f%s", v, prefix)
+	default:
+		return fmt.Sprintf("%.2f%s", v, prefix)
+	}
+}
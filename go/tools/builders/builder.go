@@ -20,22 +20,59 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("builder: ")
 
-	args, _, err := expandParamsFiles(os.Args[1:])
-	if err != nil {
+	args := os.Args[1:]
+	protocol := workerProtocolProto
+	if len(args) > 0 && strings.HasPrefix(args[0], workerProtocolFlag) {
+		protocol, args = strings.TrimPrefix(args[0], workerProtocolFlag), args[1:]
+	}
+	if startupArgs, ok := persistentWorkerArgs(args); ok {
+		if err := runWorker(os.Stdin, os.Stdout, startupArgs, protocol); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := runBuilder(args); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		log.Fatal(err)
 	}
+}
+
+// exitError is returned by actions that have already reported why they
+// failed and only need the builder to exit with the given code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// runBuilder runs the action named by the builder's file name or by the first
+// argument.
+func runBuilder(args []string) error {
+	args, _, err := expandParamsFiles(args)
+	if err != nil {
+		return err
+	}
 
 	verb := verbFromName(os.Args[0])
 	if verb == "" && len(args) == 0 {
-		log.Fatalf("usage: %s verb options...", os.Args[0])
+		return fmt.Errorf("usage: %s verb options...", os.Args[0])
 	}
 
 	var rest []string
@@ -61,6 +98,12 @@ func main() {
 		action = link
 	case "gennogomain":
 		action = genNogoMain
+	case "gennogobaseline":
+		action = genNogoBaseline
+	case "nogofix":
+		action = nogoFix
+	case "nogoprofile":
+		action = nogoProfileReport
 	case "stdlib":
 		action = stdlib
 	case "stdliblist":
@@ -68,11 +111,9 @@ func main() {
 	case "cc":
 		action = cc
 	default:
-		log.Fatalf("unknown action: %s", verb)
+		return fmt.Errorf("unknown action: %s", verb)
 	}
 	log.SetPrefix(verb + ": ")
 
-	if err := action(rest); err != nil {
-		log.Fatal(err)
-	}
+	return action(rest)
 }
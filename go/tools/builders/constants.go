@@ -24,6 +24,31 @@ const (
 	nogoError
 	nogoViolation
 
-	nogoLogBasename = "nogo.log"
-	nogoFixBasename = "nogo.patch"
+	nogoLogBasename   = "nogo.log"
+	nogoFixBasename   = "nogo.patch"
+	nogoJSONBasename  = "nogo.json"
+	nogoSARIFBasename = "nogo.sarif"
+	// The fixes in nogo.patch together with the analyzers that suggested them.
+	nogoFixesBasename = "nogo_fix.json"
+	// The log of diagnostics that are printed but don't fail the build.
+	nogoWarningsBasename = "nogo_warnings.log"
+	// The per-package profile written if profiling is enabled.
+	nogoProfileBasename = "nogo_profile.json"
+
+	// The severity levels of nogo diagnostics.
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+
+	// The mechanisms that may suppress a nogo diagnostic.
+	suppressedByNolint       = "nolint"
+	suppressedByOnlyFiles    = "only_files"
+	suppressedByExcludeFiles = "exclude_files"
+	suppressedByBaseline     = "baseline"
+
+	// The policies that select which of the alternative suggested fixes of a
+	// diagnostic nogo applies.
+	fixPolicyFirst = "first"
+	fixPolicyNone  = "none"
+	fixPolicyRegex = "regex"
 )
@@ -21,8 +21,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"strconv"
 )
 
@@ -86,7 +88,9 @@ func instrumentForCoverage(
 		// Augment coverage source files to store a mapping of <importpath>/<filename> -> <execroot_relative_path>
 		// as this information is only known during compilation but is required when the rules_go generated
 		// test main exits and go coverage files are converted to lcov format.
-		if err := registerCoverage(outfile, importPathFile, srcName); err != nil {
+		// The functions declared in the file are registered along with it so that the lcov files also
+		// contain function coverage.
+		if err := registerCoverage(outfile, importPathFile, srcName, coverFuncs(infiles[i])); err != nil {
 			return nil, err
 		}
 	}
@@ -126,10 +130,79 @@ type coverPkgConfig struct {
 	EmitMetaFile string
 }
 
+// coverFunc is a function declared in a source file instrumented for coverage.
+type coverFunc struct {
+	name string
+	// line is the line of the func keyword. The other positions are those of
+	// the braces around the body, with 1-based byte columns like in coverage
+	// profiles.
+	line, startLine, startCol, endLine, endCol int
+}
+
+// coverFuncs returns the functions with bodies declared in a source file,
+// named like "F", "T.M" and "(*T).M". Functions with the same name, like
+// init functions, are numbered like "init.0" and "init.1". Parse errors are
+// ignored and let the compiler fail.
+func coverFuncs(filename string) []coverFunc {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil
+	}
+	var funcs []coverFunc
+	count := make(map[string]int)
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) == 1 {
+			name = receiverName(fd.Recv.List[0].Type) + "." + name
+		}
+		count[name]++
+		start, end := fset.Position(fd.Body.Lbrace), fset.Position(fd.Body.Rbrace)
+		funcs = append(funcs, coverFunc{
+			name:      name,
+			line:      fset.Position(fd.Pos()).Line,
+			startLine: start.Line,
+			startCol:  start.Column,
+			endLine:   end.Line,
+			endCol:    end.Column,
+		})
+	}
+	index := make(map[string]int)
+	for i, fn := range funcs {
+		if count[fn.name] > 1 {
+			funcs[i].name = fmt.Sprintf("%s.%d", fn.name, index[fn.name])
+			index[fn.name]++
+		}
+	}
+	return funcs
+}
+
+// receiverName returns the name of a receiver type without type parameters,
+// like "T" or "(*T)".
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return receiverName(t.X)
+	case *ast.StarExpr:
+		return "(*" + receiverName(t.X) + ")"
+	default:
+		name := types.ExprString(expr)
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		return name
+	}
+}
+
 // registerCoverage modifies coverSrcFilename, the output file from go tool cover.
 // It adds a call to coverdata.RegisterSrcPathMapping, which ensures that rules_go
-// can produce lcov files with exec root relative file paths.
-func registerCoverage(coverSrcFilename, importPathFile, srcName string) error {
+// can produce lcov files with exec root relative file paths, and a call to
+// coverdata.RegisterFuncs for the functions declared in the file.
+func registerCoverage(coverSrcFilename, importPathFile, srcName string, funcs []coverFunc) error {
 	coverSrc, err := os.ReadFile(coverSrcFilename)
 	if err != nil {
 		return fmt.Errorf("instrumentForCoverage: reading instrumented source: %w", err)
@@ -187,8 +260,20 @@ func registerCoverage(coverSrcFilename, importPathFile, srcName string) error {
 	fmt.Fprintf(buf, `
 func init() {
 	%s.RegisterSrcPathMapping(%q, %q)
-}
 `, coverdataName, importPathFile, srcName)
+	if len(funcs) > 0 {
+		names := make([]string, len(funcs))
+		pos := make([]string, 0, 5*len(funcs))
+		for i, fn := range funcs {
+			names[i] = strconv.Quote(fn.name)
+			for _, p := range []int{fn.line, fn.startLine, fn.startCol, fn.endLine, fn.endCol} {
+				pos = append(pos, strconv.Itoa(p))
+			}
+		}
+		fmt.Fprintf(buf, "\t%s.RegisterFuncs(%q, []string{%s}, []uint32{%s})\n",
+			coverdataName, importPathFile, strings.Join(names, ", "), strings.Join(pos, ", "))
+	}
+	buf.WriteString("}\n")
 	if err := os.WriteFile(coverSrcFilename, buf.Bytes(), writeFileMode); err != nil {
 		return fmt.Errorf("registerCoverage: %v", err)
 	}
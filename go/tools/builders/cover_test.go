@@ -4,13 +4,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
 type test struct {
-	name string
-	in   string
-	out  string
+	name  string
+	in    string
+	funcs []coverFunc
+	out   string
 }
 
 var tests = []test{
@@ -72,6 +74,22 @@ import coverdata "github.com/bazelbuild/rules_go/go/tools/coverdata"
 func init() {
 	coverdata.RegisterSrcPathMapping("some.importh/path/file.go", "src/path/file.go")
 }
+`,
+	},
+	{
+		name: "functions",
+		in: `package main
+`,
+		funcs: []coverFunc{
+			{name: "main", line: 3, startLine: 3, startCol: 13, endLine: 5, endCol: 1},
+			{name: "(*T).M", line: 7, startLine: 7, startCol: 18, endLine: 7, endCol: 19},
+		},
+		out: `package main; import "github.com/bazelbuild/rules_go/go/tools/coverdata"
+
+func init() {
+	coverdata.RegisterSrcPathMapping("some.importh/path/file.go", "src/path/file.go")
+	coverdata.RegisterFuncs("some.importh/path/file.go", []string{"main", "(*T).M"}, []uint32{3, 3, 13, 5, 1, 7, 7, 18, 7, 19})
+}
 `,
 	},
 	{
@@ -98,7 +116,7 @@ func TestRegisterCoverage(t *testing.T) {
 			t.Errorf("writing input file: %v", err)
 			return
 		}
-		err := registerCoverage(filename, "some.importh/path/file.go", "src/path/file.go")
+		err := registerCoverage(filename, "some.importh/path/file.go", "src/path/file.go", test.funcs)
 		if err != nil {
 			t.Errorf("%q: %+v", test.name, err)
 			continue
@@ -113,3 +131,41 @@ func TestRegisterCoverage(t *testing.T) {
 		}
 	}
 }
+
+func TestCoverFuncs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "funcs.go")
+	src := `package p
+
+func F() {
+	println()
+}
+
+func (t *T) M() {}
+
+func (l List[E]) Len() int { return 0 }
+
+func (m *Map[K, V]) Len() int { return 0 }
+
+func init() {}
+
+func init() {}
+
+func External()
+
+var x = func() {}
+`
+	if err := ioutil.WriteFile(filename, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	want := []coverFunc{
+		{name: "F", line: 3, startLine: 3, startCol: 10, endLine: 5, endCol: 1},
+		{name: "(*T).M", line: 7, startLine: 7, startCol: 17, endLine: 7, endCol: 18},
+		{name: "List.Len", line: 9, startLine: 9, startCol: 28, endLine: 9, endCol: 39},
+		{name: "(*Map).Len", line: 11, startLine: 11, startCol: 31, endLine: 11, endCol: 42},
+		{name: "init.0", line: 13, startLine: 13, startCol: 13, endLine: 13, endCol: 14},
+		{name: "init.1", line: 15, startLine: 15, startCol: 13, endLine: 15, endCol: 14},
+	}
+	if got := coverFuncs(filename); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -281,7 +281,10 @@ func runAndLogCommand(cmd *exec.Cmd, verbose bool) error {
 	if verbose {
 		fmt.Fprintln(os.Stderr, formatCommand(cmd))
 	}
-	cleanup := passLongArgsInResponseFiles(cmd)
+	cleanup, err := passLongArgsInResponseFiles(cmd)
+	if err != nil {
+		return err
+	}
 	defer cleanup()
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("error running subcommand %s: %w", cmd.Path, err)
@@ -290,14 +293,15 @@ func runAndLogCommand(cmd *exec.Cmd, verbose bool) error {
 }
 
 // expandParamsFiles looks for arguments in args of the form
-// "-param=filename". When it finds these arguments it reads the file "filename"
-// and replaces the argument with its content.
+// "-param=filename" or "--flagfile=filename", the latter being used for
+// actions that support persistent workers. When it finds these arguments it
+// reads the file "filename" and replaces the argument with its content.
 // It returns the expanded arguments as well as a bool that is true if any param
 // files have been passed.
 func expandParamsFiles(args []string) ([]string, bool, error) {
 	var paramsIndices []int
 	for i, arg := range args {
-		if strings.HasPrefix(arg, "-param=") {
+		if strings.HasPrefix(arg, "-param=") || strings.HasPrefix(arg, "--flagfile=") {
 			paramsIndices = append(paramsIndices, i)
 		}
 	}
@@ -310,7 +314,7 @@ func expandParamsFiles(args []string) ([]string, bool, error) {
 		expandedArgs = append(expandedArgs, args[last:pi]...)
 		last = pi + 1
 
-		fileName := args[pi][len("-param="):]
+		fileName := args[pi][strings.Index(args[pi], "=")+1:]
 		fileArgs, err := readParamsFile(fileName)
 		if err != nil {
 			return nil, true, err
@@ -331,7 +335,12 @@ func readParamsFile(name string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseParams(data)
+}
 
+// parseParams splits the contents of a params file in Bazel's "shell" format
+// into arguments.
+func parseParams(data []byte) ([]string, error) {
 	var args []string
 	var arg []byte
 	quote := false
@@ -531,7 +540,7 @@ func formatCommand(cmd *exec.Cmd) string {
 //
 // See https://github.com/golang/go/issues/18468 (Windows) and
 // https://github.com/golang/go/issues/37768 (Darwin).
-func passLongArgsInResponseFiles(cmd *exec.Cmd) (cleanup func()) {
+func passLongArgsInResponseFiles(cmd *exec.Cmd) (cleanup func(), err error) {
 	cleanup = func() {} // no cleanup by default
 	var argLen int
 	for _, arg := range cmd.Args {
@@ -540,11 +549,11 @@ func passLongArgsInResponseFiles(cmd *exec.Cmd) (cleanup func()) {
 	// If we're not approaching 32KB of args, just pass args normally.
 	// (use 30KB instead to be conservative; not sure how accounting is done)
 	if !useResponseFile(cmd.Path, argLen) {
-		return
+		return cleanup, nil
 	}
 	tf, err := ioutil.TempFile("", "args")
 	if err != nil {
-		log.Fatalf("error writing long arguments to response file: %v", err)
+		return nil, fmt.Errorf("error writing long arguments to response file: %v", err)
 	}
 	cleanup = func() { os.Remove(tf.Name()) }
 	var buf bytes.Buffer
@@ -558,14 +567,14 @@ func passLongArgsInResponseFiles(cmd *exec.Cmd) (cleanup func()) {
 	if _, err := tf.Write(buf.Bytes()); err != nil {
 		tf.Close()
 		cleanup()
-		log.Fatalf("error writing long arguments to response file: %v", err)
+		return nil, fmt.Errorf("error writing long arguments to response file: %v", err)
 	}
 	if err := tf.Close(); err != nil {
 		cleanup()
-		log.Fatalf("error writing long arguments to response file: %v", err)
+		return nil, fmt.Errorf("error writing long arguments to response file: %v", err)
 	}
 	cmd.Args = []string{cmd.Args[0], "@" + tf.Name()}
-	return cleanup
+	return cleanup, nil
 }
 
 // quotePathIfNeeded quotes path if it contains whitespace and isn't already quoted.
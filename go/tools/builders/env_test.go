@@ -3,6 +3,8 @@
 package main
 
 import (
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -75,3 +77,15 @@ func TestTransformArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestPassLongArgsInResponseFiles_error(t *testing.T) {
+	// The response file can't be created in a missing temporary directory.
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	cmd := exec.Command("compile", strings.Repeat("a", 40<<10))
+	if _, err := passLongArgsInResponseFiles(cmd); err == nil {
+		t.Error("got no error, want an error writing the response file")
+	}
+	if err := runAndLogCommand(cmd, false); err == nil || !strings.Contains(err.Error(), "response file") {
+		t.Errorf("got error %v from runAndLogCommand, want an error writing the response file", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Generates a nogo baseline file from the diagnostics reports written by nogo.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reportFindings is the subset of the nogo.json report needed to build a
+// baseline.
+type reportFindings struct {
+	Diagnostics []struct {
+		Analyzer     string `json:"analyzer"`
+		Message      string `json:"message"`
+		Fingerprint  string `json:"fingerprint"`
+		SuppressedBy string `json:"suppressed_by"`
+		Range        struct {
+			File string `json:"file"`
+		} `json:"range"`
+	} `json:"diagnostics"`
+}
+
+func genNogoBaseline(args []string) error {
+	flags := flag.NewFlagSet("gennogobaseline", flag.ExitOnError)
+	out := flags.String("output", "", "baseline file to write")
+	reportsFile := flags.String("reports", "", "file listing nogo report directories or nogo.json files, one per line")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("must provide output file")
+	}
+
+	paths, err := nogoOutputFiles(flags.Args(), *reportsFile, nogoJSONBasename)
+	if err != nil {
+		return err
+	}
+
+	var findings []baselineEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// nogo did not validate this package.
+			continue
+		} else if err != nil {
+			return err
+		}
+		var r reportFindings
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		for _, d := range r.Diagnostics {
+			// Findings suppressed by other means don't need to be part of the
+			// baseline.
+			if d.SuppressedBy != "" && d.SuppressedBy != suppressedByBaseline {
+				continue
+			}
+			if d.Fingerprint == "" {
+				return fmt.Errorf("%s: diagnostic at %s has no fingerprint", path, d.Range.File)
+			}
+			findings = append(findings, baselineEntry{
+				Analyzer:    d.Analyzer,
+				File:        d.Range.File,
+				Fingerprint: d.Fingerprint,
+				Message:     d.Message,
+				Count:       1,
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(newNogoBaseline(findings), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(workingDirPath(*out), append(data, '\n'), 0o666)
+}
+
+// workingDirPath resolves a relative path against the directory 'bazel run'
+// was invoked from, if any.
+func workingDirPath(path string) string {
+	wd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	if wd == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(wd, path)
+}
+
+// nogoOutputFiles returns the paths of the files with the given basename in
+// the nogo output directories given in args and listed in reportsFile, one
+// per line. Arguments may also name the files directly.
+func nogoOutputFiles(args []string, reportsFile, basename string) ([]string, error) {
+	dirs := args
+	if reportsFile != "" {
+		data, err := os.ReadFile(workingDirPath(reportsFile))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				dirs = append(dirs, line)
+			}
+		}
+	}
+	paths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		path := workingDirPath(dir)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, basename)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
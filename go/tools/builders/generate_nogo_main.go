@@ -80,12 +80,43 @@ var configs = map[string]config{
 			{{printf "regexp.MustCompile(%q)" $path}},
 			{{- end}}
 		},
+		{{- end -}}
+		{{- if $config.Severity}}
+		severity: {{printf "%q" $config.Severity}},
+		{{- end -}}
+		{{- if $config.SeverityOverrides}}
+		severityOverrides: []severityOverride{
+			{{- range $path, $severity := $config.SeverityOverrides}}
+			{pattern: {{printf "regexp.MustCompile(%q)" $path}}, severity: {{printf "%q" $severity}}},
+			{{- end}}
+		},
+		{{- end -}}
+		{{- if $config.FixPolicy}}
+		fixPolicy: fixPolicy{
+			mode: {{printf "%q" $config.FixPolicy}},
+			{{- if $config.FixMessageRegex}}
+			message: {{printf "regexp.MustCompile(%q)" $config.FixMessageRegex}},
+			{{- end}}
+		},
 		{{- end}}
 	},
 {{- end}}
 }
 
+// baseline lists the known findings that are not reported.
+var baseline = []baselineEntry{
+{{- range $entry := .Baseline}}
+	{Analyzer: {{printf "%q" $entry.Analyzer}}, File: {{printf "%q" $entry.File}}, Fingerprint: {{printf "%q" $entry.Fingerprint}}, Count: {{$entry.Count}}},
+{{- end}}
+}
+
 const debugMode = {{ .Debug }}
+
+const strictNolint = {{ .StrictNolint }}
+
+const requireNolintJustification = {{ .RequireNolintJustification }}
+
+const profileMode = {{ .Profile }}
 `
 
 func genNogoMain(args []string) error {
@@ -94,7 +125,11 @@ func genNogoMain(args []string) error {
 	out := flags.String("output", "", "output file to write (defaults to stdout)")
 	flags.Var(&analyzerImportPaths, "analyzer_importpath", "import path of an analyzer library")
 	configFile := flags.String("config", "", "nogo config file")
+	baselineFile := flags.String("baseline", "", "nogo baseline file")
 	debug := flags.Bool("debug", false, "enable debug mode")
+	strictNolint := flags.Bool("strict_nolint", false, "report unknown and unused nolint directives")
+	requireNolintJustification := flags.Bool("require_nolint_justification", false, "report nolint directives without a justification")
+	profile := flags.Bool("profile", false, "write a timing and memory profile for each package")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -119,6 +154,15 @@ func genNogoMain(args []string) error {
 		return err
 	}
 
+	var baseline []baselineEntry
+	if *baselineFile != "" {
+		b, err := readBaselineFile(*baselineFile)
+		if err != nil {
+			return err
+		}
+		baseline = b.Findings
+	}
+
 	type Import struct {
 		Path, Name string
 	}
@@ -135,17 +179,25 @@ func genNogoMain(args []string) error {
 		suffix++
 	}
 	data := struct {
-		Imports    []Import
-		Configs    Configs
-		NeedRegexp bool
-		Debug      bool
+		Imports                    []Import
+		Configs                    Configs
+		Baseline                   []baselineEntry
+		NeedRegexp                 bool
+		Debug                      bool
+		StrictNolint               bool
+		RequireNolintJustification bool
+		Profile                    bool
 	}{
-		Imports: imports,
-		Configs: config,
-		Debug:   *debug,
+		Imports:                    imports,
+		Configs:                    config,
+		Baseline:                   baseline,
+		Debug:                      *debug,
+		StrictNolint:               *strictNolint,
+		RequireNolintJustification: *requireNolintJustification,
+		Profile:                    *profile,
 	}
 	for _, c := range config {
-		if len(c.OnlyFiles) > 0 || len(c.ExcludeFiles) > 0 {
+		if len(c.OnlyFiles) > 0 || len(c.ExcludeFiles) > 0 || len(c.SeverityOverrides) > 0 || c.FixMessageRegex != "" {
 			data.NeedRegexp = true
 			break
 		}
@@ -181,11 +233,41 @@ func buildConfig(path string) (Configs, error) {
 				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
 			}
 		}
+		if config.Severity != "" && !isValidSeverity(config.Severity) {
+			return Configs{}, fmt.Errorf("invalid severity for analysis %q: %q", name, config.Severity)
+		}
+		for pattern, severity := range config.SeverityOverrides {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
+			}
+			if !isValidSeverity(severity) {
+				return Configs{}, fmt.Errorf("invalid severity for pattern %q of analysis %q: %q", pattern, name, severity)
+			}
+		}
+		switch config.FixPolicy {
+		case "", fixPolicyFirst, fixPolicyNone:
+			if config.FixMessageRegex != "" {
+				return Configs{}, fmt.Errorf("fix_message_regex of analysis %q requires fix_policy %q", name, fixPolicyRegex)
+			}
+		case fixPolicyRegex:
+			if config.FixMessageRegex == "" {
+				return Configs{}, fmt.Errorf("fix_policy %q of analysis %q requires fix_message_regex", fixPolicyRegex, name)
+			}
+			if _, err := regexp.Compile(config.FixMessageRegex); err != nil {
+				return Configs{}, fmt.Errorf("invalid fix_message_regex for analysis %q: %v", name, err)
+			}
+		default:
+			return Configs{}, fmt.Errorf("invalid fix_policy for analysis %q: %q", name, config.FixPolicy)
+		}
 		configs[name] = Config{
 			// Description is currently unused.
-			OnlyFiles:     config.OnlyFiles,
-			ExcludeFiles:  config.ExcludeFiles,
-			AnalyzerFlags: config.AnalyzerFlags,
+			OnlyFiles:         config.OnlyFiles,
+			ExcludeFiles:      config.ExcludeFiles,
+			AnalyzerFlags:     config.AnalyzerFlags,
+			Severity:          config.Severity,
+			SeverityOverrides: config.SeverityOverrides,
+			FixPolicy:         config.FixPolicy,
+			FixMessageRegex:   config.FixMessageRegex,
 		}
 	}
 	return configs, nil
@@ -194,8 +276,20 @@ func buildConfig(path string) (Configs, error) {
 type Configs map[string]Config
 
 type Config struct {
-	Description   string
-	OnlyFiles     map[string]string `json:"only_files"`
-	ExcludeFiles  map[string]string `json:"exclude_files"`
-	AnalyzerFlags map[string]string `json:"analyzer_flags"`
+	Description       string
+	OnlyFiles         map[string]string `json:"only_files"`
+	ExcludeFiles      map[string]string `json:"exclude_files"`
+	AnalyzerFlags     map[string]string `json:"analyzer_flags"`
+	Severity          string            `json:"severity"`
+	SeverityOverrides map[string]string `json:"severity_overrides"`
+	FixPolicy         string            `json:"fix_policy"`
+	FixMessageRegex   string            `json:"fix_message_regex"`
+}
+
+func isValidSeverity(severity string) bool {
+	switch severity {
+	case severityError, severityWarning, severityInfo:
+		return true
+	}
+	return false
 }
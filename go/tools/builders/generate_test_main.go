@@ -31,6 +31,7 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
@@ -38,6 +39,7 @@ import (
 	"go/doc"
 	"go/parser"
 	"go/token"
+	"math"
 	"os"
 	"sort"
 	"strings"
@@ -61,18 +63,27 @@ type Example struct {
 	Unordered bool
 }
 
+// ShardTiming is the expected duration of a test, read from the timing file
+// of the duration shard strategy.
+type ShardTiming struct {
+	Name    string
+	Seconds float64
+}
+
 // Cases holds template data.
 type Cases struct {
-	Imports     []*Import
-	Tests       []TestCase
-	Benchmarks  []TestCase
-	FuzzTargets []TestCase
-	Examples    []Example
-	TestMain    string
-	CoverMode   string
-	Covered     string
-	CoverFormat string
-	Pkgname     string
+	Imports       []*Import
+	Tests         []TestCase
+	Benchmarks    []TestCase
+	FuzzTargets   []TestCase
+	Examples      []Example
+	TestMain      string
+	CoverMode     string
+	Covered       string
+	CoverFormat   string
+	Pkgname       string
+	ShardStrategy string
+	ShardTimings  []ShardTiming
 }
 
 // Version returns whether v is a supported Go version (like "go1.18").
@@ -97,7 +108,7 @@ import "github.com/bazelbuild/rules_go/go/tools/bzltestutil"
 import (
 	"flag"
 	"log"
-{{if eq .CoverFormat "lcov"}}
+{{if ne .CoverMode ""}}
 	"io"
 {{end}}
 	"os"
@@ -105,13 +116,20 @@ import (
 {{if .TestMain}}
 	"reflect"
 {{end}}
-	"strconv"
 	"strings"
+{{if and .TestMain (.Version "go1.23")}}
+	"syscall"
+{{end}}
 	"testing"
 	"testing/internal/testdeps"
+{{if and .TestMain (.Version "go1.23")}}
+	"internal/runtime/exithook"
+{{end}}
 
 {{if ne .CoverMode ""}}
 	"internal/coverage/cfile"
+
+	"github.com/bazelbuild/rules_go/go/tools/bzltestutil/bincov"
 {{end}}
 
 {{range $p := .Imports}}
@@ -145,29 +163,116 @@ var examples = []testing.InternalExample{
 {{end}}
 }
 
-func testsInShard() []testing.InternalTest {
-	totalShards, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS"))
-	if err != nil || totalShards <= 1 {
-		return allTests
+{{if .ShardTimings}}
+var shardTimings = map[string]float64{
+{{range .ShardTimings}}
+	{{printf "%q" .Name}}: {{.Seconds}},
+{{end}}
+}
+{{else}}
+var shardTimings map[string]float64
+{{end}}
+
+// testShard is the set of tests, benchmarks, fuzz targets and examples run by
+// the current shard, or nil if all of them should run.
+var testShard map[string]bool
+
+func shardNames() []string {
+	var names []string
+	for _, t := range allTests {
+		names = append(names, t.Name)
 	}
-	file, err := os.Create(os.Getenv("TEST_SHARD_STATUS_FILE"))
-	if err != nil {
-		log.Fatalf("Failed to touch TEST_SHARD_STATUS_FILE: %v", err)
+{{if .Version "go1.18"}}
+	for _, f := range fuzzTargets {
+		names = append(names, f.Name)
+	}
+{{end}}
+	for _, e := range examples {
+		names = append(names, e.Name)
+	}
+	for _, b := range benchmarks {
+		names = append(names, b.Name)
 	}
-	_ = file.Close()
-	shardIndex, err := strconv.Atoi(os.Getenv("TEST_SHARD_INDEX"))
-	if err != nil || shardIndex < 0 {
+	return names
+}
+
+func testsInShard() []testing.InternalTest {
+	if testShard == nil {
 		return allTests
 	}
 	tests := []testing.InternalTest{}
-	for i, t := range allTests {
-		if i % totalShards == shardIndex {
+	for _, t := range allTests {
+		if testShard[t.Name] {
 			tests = append(tests, t)
 		}
 	}
 	return tests
 }
 
+func benchmarksInShard() []testing.InternalBenchmark {
+	if testShard == nil {
+		return benchmarks
+	}
+	shardBenchmarks := []testing.InternalBenchmark{}
+	for _, b := range benchmarks {
+		if testShard[b.Name] {
+			shardBenchmarks = append(shardBenchmarks, b)
+		}
+	}
+	return shardBenchmarks
+}
+
+{{if .Version "go1.18"}}
+func fuzzTargetsInShard() []testing.InternalFuzzTarget {
+	if testShard == nil {
+		return fuzzTargets
+	}
+	shardFuzzTargets := []testing.InternalFuzzTarget{}
+	for _, f := range fuzzTargets {
+		if testShard[f.Name] {
+			shardFuzzTargets = append(shardFuzzTargets, f)
+		}
+	}
+	return shardFuzzTargets
+}
+{{end}}
+
+func examplesInShard() []testing.InternalExample {
+	if testShard == nil {
+		return examples
+	}
+	shardExamples := []testing.InternalExample{}
+	for _, e := range examples {
+		if testShard[e.Name] {
+			shardExamples = append(shardExamples, e)
+		}
+	}
+	return shardExamples
+}
+
+{{if ne .CoverMode ""}}
+// withPerTestCoverage wraps tests so that the coverage of each of them is
+// recorded separately.
+func withPerTestCoverage(tests []testing.InternalTest) []testing.InternalTest {
+	wrapped := make([]testing.InternalTest, len(tests))
+	for i, test := range tests {
+		name, f := test.Name, test.F
+		wrapped[i] = testing.InternalTest{Name: name, F: func(t *testing.T) {
+			if err := bincov.BeginTest(); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := bincov.EndTest(name); err != nil {
+					t.Error(err)
+				}
+			}()
+			f(t)
+		}}
+	}
+	return wrapped
+}
+{{end}}
+
 func main() {
 	// When the test process is originally spawned by Bazel,
 	// it should run in a test directory.
@@ -178,6 +283,7 @@ func main() {
 	// out of the Chdir behavior.
 	_ = os.Unsetenv("GO_TEST_RUN_FROM_BAZEL")
 
+	bzltestutil.ShardStrategy = "{{.ShardStrategy}}"
 	if bzltestutil.ShouldWrap() {
 		err := bzltestutil.Wrap("{{.Pkgname}}")
 		exitCode := 0
@@ -190,6 +296,9 @@ func main() {
 		os.Exit(exitCode)
 	}
 
+	testShard = bzltestutil.TestsInShard(shardNames(), shardTimings)
+	tests := testsInShard()
+
 	{{if ne .CoverMode ""}}
 		testdeps.CoverMode = "{{ .CoverMode }}"
 		testdeps.Covered = "{{ .Covered }}"
@@ -197,18 +306,32 @@ func main() {
   		{{if eq .CoverFormat "lcov"}}
 			testdeps.CoverProcessTestDirFunc = func(dir string, covfile string, cm string, cpkg string, w io.Writer, selpkgs []string) error {
 				cfile.ProcessCoverTestDir(dir, covfile, cm, cpkg, w, selpkgs)
+				if err := bincov.WritePerTestCoverage(); err != nil {
+					return err
+				}
 				return bzltestutil.ConvertCoverToLcov()
 			}
 		{{ else }}
-			testdeps.CoverProcessTestDirFunc = cfile.ProcessCoverTestDir
+			testdeps.CoverProcessTestDirFunc = func(dir string, covfile string, cm string, cpkg string, w io.Writer, selpkgs []string) error {
+				if err := cfile.ProcessCoverTestDir(dir, covfile, cm, cpkg, w, selpkgs); err != nil {
+					return err
+				}
+				return bincov.WritePerTestCoverage()
+			}
 		{{ end }}
 		testdeps.CoverMarkProfileEmittedFunc = cfile.MarkProfileEmitted
+
+		if perTest, err := bincov.PerTestCoverage(); err != nil {
+			log.Fatal(err)
+		} else if perTest {
+			tests = withPerTestCoverage(tests)
+		}
 	{{end}}
 
   {{if .Version "go1.18"}}
-	m := testing.MainStart(testdeps.TestDeps{}, testsInShard(), benchmarks, fuzzTargets, examples)
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarksInShard(), fuzzTargetsInShard(), examplesInShard())
   {{else}}
-	m := testing.MainStart(testdeps.TestDeps{}, testsInShard(), benchmarks, examples)
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarksInShard(), examplesInShard())
   {{end}}
 
 	if filter := os.Getenv("TESTBRIDGE_TEST_ONLY"); filter != "" {
@@ -250,14 +373,35 @@ func main() {
 		bzltestutil.RegisterTimeoutHandler()
 	}
 
+	leakCheck, err := bzltestutil.StartLeakCheck()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	{{if not .TestMain}}
 	res := m.Run()
 	{{else}}
+	{{if .Version "go1.23"}}
+	if check := leakCheck; check != nil {
+		// TestMain may call os.Exit instead of returning, so leaks are
+		// checked when the process exits. Exit hooks can't change the exit
+		// code and are only told whether it is zero, by running the hooks
+		// that don't run on failure.
+		exitCode := 1
+		exithook.Add(exithook.Hook{F: func() {
+			if code := check.Finish(exitCode); code != exitCode {
+				syscall.Exit(code)
+			}
+		}, RunOnFailure: true})
+		exithook.Add(exithook.Hook{F: func() { exitCode = 0 }})
+		leakCheck = nil
+	}
+	{{end}}
 	{{.TestMain}}(m)
 	{{/* See golang.org/issue/34129 and golang.org/cl/219639 */}}
 	res := int(reflect.ValueOf(m).Elem().FieldByName("exitCode").Int())
 	{{end}}
-	os.Exit(res)
+	os.Exit(leakCheck.Finish(res))
 }
 `
 
@@ -275,6 +419,8 @@ func genTestMain(args []string) error {
 	coverMode := flags.String("cover_mode", "", "the coverage mode to use")
 	coverFormat := flags.String("cover_format", "", "the coverage report type to generate (go_cover or lcov)")
 	pkgname := flags.String("pkgname", "", "package name of test")
+	shardStrategy := flags.String("shard_strategy", "round_robin", "how tests are assigned to shards (round_robin, hash or duration)")
+	shardTimings := flags.String("shard_timings", "", "JSON file with the expected durations of tests in seconds, for the duration shard strategy")
 	flags.Var(&imports, "import", "Packages to import")
 	flags.Var(&sources, "src", "Sources to process for tests")
 	if err := flags.Parse(args); err != nil {
@@ -283,6 +429,10 @@ func genTestMain(args []string) error {
 	if err := goenv.checkFlagsAndSetGoroot(); err != nil {
 		return err
 	}
+	timings, err := readShardTimings(*shardStrategy, *shardTimings)
+	if err != nil {
+		return err
+	}
 	// Process import args
 	importMap := map[string]*Import{}
 	for _, imp := range imports {
@@ -323,9 +473,11 @@ func genTestMain(args []string) error {
 	}
 
 	cases := Cases{
-		CoverFormat: *coverFormat,
-		CoverMode:   *coverMode,
-		Pkgname:     *pkgname,
+		CoverFormat:   *coverFormat,
+		CoverMode:     *coverMode,
+		Pkgname:       *pkgname,
+		ShardStrategy: *shardStrategy,
+		ShardTimings:  timings,
 	}
 
 	testFileSet := token.NewFileSet()
@@ -447,3 +599,41 @@ func genTestMain(args []string) error {
 	}
 	return nil
 }
+
+// readShardTimings reads the timing file of the duration shard strategy,
+// which maps the names of tests, benchmarks, fuzz targets and examples to
+// their expected durations in seconds.
+func readShardTimings(strategy, path string) ([]ShardTiming, error) {
+	switch strategy {
+	case "round_robin", "hash":
+		if path != "" {
+			return nil, fmt.Errorf("a shard timing file can only be used with the duration shard strategy, got %q", strategy)
+		}
+		return nil, nil
+	case "duration":
+		if path == "" {
+			return nil, fmt.Errorf("the duration shard strategy requires a shard timing file")
+		}
+	default:
+		return nil, fmt.Errorf("invalid shard strategy %q, must be one of round_robin, hash or duration", strategy)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var seconds map[string]float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return nil, fmt.Errorf("parsing shard timing file %s: %v", path, err)
+	}
+	timings := make([]ShardTiming, 0, len(seconds))
+	for name, s := range seconds {
+		if s < 0 || math.IsInf(s, 0) || math.IsNaN(s) {
+			return nil, fmt.Errorf("shard timing file %s: invalid duration %v for %s", path, s, name)
+		}
+		timings = append(timings, ShardTiming{Name: name, Seconds: s})
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].Name < timings[j].Name
+	})
+	return timings, nil
+}
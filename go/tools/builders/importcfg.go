@@ -25,6 +25,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type archive struct {
@@ -39,26 +41,13 @@ type archive struct {
 // for standard library packages.
 func checkImports(files []fileInfo, archives []archive, stdPackageListPath string, importPath string, recompileInternalDeps []string) (map[string]*archive, error) {
 	// Read the standard package list.
-	packagesTxt, err := ioutil.ReadFile(stdPackageListPath)
+	stdPkgList, err := readStdPackageList(stdPackageListPath)
 	if err != nil {
 		return nil, err
 	}
-	stdPkgs := make(map[string]bool)
-	for len(packagesTxt) > 0 {
-		n := bytes.IndexByte(packagesTxt, '\n')
-		var line string
-		if n < 0 {
-			line = string(packagesTxt)
-			packagesTxt = nil
-		} else {
-			line = string(packagesTxt[:n])
-			packagesTxt = packagesTxt[n+1:]
-		}
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		stdPkgs[line] = true
+	stdPkgs := make(map[string]bool, len(stdPkgList))
+	for _, pkg := range stdPkgList {
+		stdPkgs[pkg] = true
 	}
 
 	// Index the archives.
@@ -161,21 +150,12 @@ func buildImportcfgFileForLink(archives []archive, stdPackageListPath, installSu
 		return "", errors.New("GOROOT not set")
 	}
 	prefix := abs(filepath.Join(goroot, "pkg", installSuffix))
-	stdPackageListFile, err := os.Open(stdPackageListPath)
+	stdPkgList, err := readStdPackageList(stdPackageListPath)
 	if err != nil {
 		return "", err
 	}
-	defer stdPackageListFile.Close()
-	scanner := bufio.NewScanner(stdPackageListFile)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		fmt.Fprintf(buf, "packagefile %s=%s.a\n", line, filepath.Join(prefix, filepath.FromSlash(line)))
-	}
-	if err := scanner.Err(); err != nil {
-		return "", err
+	for _, pkg := range stdPkgList {
+		fmt.Fprintf(buf, "packagefile %s=%s.a\n", pkg, filepath.Join(prefix, filepath.FromSlash(pkg)))
 	}
 	depsSeen := map[string]string{}
 	for _, arc := range archives {
@@ -270,3 +250,51 @@ func (m *archiveMultiFlag) Set(v string) error {
 	*m = append(*m, a)
 	return nil
 }
+
+// stdPackageListCache holds the standard package lists read so far. A
+// persistent worker reads the same list for every compile and link action.
+var stdPackageListCache = struct {
+	sync.Mutex
+	lists map[string]stdPackageList
+}{lists: make(map[string]stdPackageList)}
+
+type stdPackageList struct {
+	size     int64
+	modTime  time.Time
+	packages []string
+}
+
+// readStdPackageList returns the packages listed in the file at path, one per
+// line. The result is cached until the file changes.
+func readStdPackageList(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := abs(path)
+	stdPackageListCache.Lock()
+	defer stdPackageListCache.Unlock()
+	if l, ok := stdPackageListCache.lists[key]; ok && l.size == info.Size() && l.modTime.Equal(info.ModTime()) {
+		return l.packages, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var packages []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		packages = append(packages, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	stdPackageListCache.lists[key] = stdPackageList{size: info.Size(), modTime: info.ModTime(), packages: packages}
+	return packages, nil
+}
@@ -123,34 +123,48 @@ func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []ar
 		return fmt.Errorf("error writing nogo params file: %v", err)
 	}
 
-	cmd := exec.Command(args[0], "-param="+paramsFile)
-	out := &bytes.Buffer{}
-	cmd.Stdout, cmd.Stderr = out, out
-	err := cmd.Run()
-	if err == nil {
-		return nil
-	}
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if !exitErr.Exited() {
-			cmdLine := strings.Join(args, " ")
-			return fmt.Errorf("nogo command '%s' exited unexpectedly: %s", cmdLine, exitErr.String())
-		}
-		prettyOut := relativizePaths(out.Bytes())
-		if exitErr.ExitCode() != nogoViolation {
-			return errors.New(string(prettyOut))
-		}
-		outLog, err := os.Create(filepath.Join(outDirPath, nogoLogBasename))
+	var exitCode int
+	var out []byte
+	if nogoWorkers != nil {
+		// The builder runs as a persistent worker, so nogo does too.
+		var err error
+		exitCode, out, err = nogoWorkers.run(nogoPath, []string{"-param=" + paramsFile})
 		if err != nil {
-			return fmt.Errorf("error creating nogo log file: %v", err)
+			return fmt.Errorf("running nogo worker: %v", err)
 		}
-		defer outLog.Close()
-		_, err = outLog.Write(prettyOut)
-		if err != nil {
-			return fmt.Errorf("error writing nogo log file: %v", err)
+	} else {
+		cmd := exec.Command(args[0], "-param="+paramsFile)
+		buf := &bytes.Buffer{}
+		cmd.Stdout, cmd.Stderr = buf, buf
+		err := cmd.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if !exitErr.Exited() {
+				cmdLine := strings.Join(args, " ")
+				return fmt.Errorf("nogo command '%s' exited unexpectedly: %s", cmdLine, exitErr.String())
+			}
+			exitCode = exitErr.ExitCode()
+		} else if err != nil {
+			return err
 		}
-		// Do not fail the action if nogo has findings so that facts are
-		// still available for downstream targets.
+		out = buf.Bytes()
+	}
+	if exitCode == 0 {
 		return nil
 	}
-	return err
+	prettyOut := relativizePaths(out)
+	if exitCode != nogoViolation {
+		return errors.New(string(prettyOut))
+	}
+	outLog, err := os.Create(filepath.Join(outDirPath, nogoLogBasename))
+	if err != nil {
+		return fmt.Errorf("error creating nogo log file: %v", err)
+	}
+	defer outLog.Close()
+	_, err = outLog.Write(prettyOut)
+	if err != nil {
+		return fmt.Errorf("error writing nogo log file: %v", err)
+	}
+	// Do not fail the action if nogo has findings so that facts are
+	// still available for downstream targets.
+	return nil
 }
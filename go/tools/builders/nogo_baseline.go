@@ -0,0 +1,141 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains the format of nogo baseline files, which list known
+// findings that nogo should not report. Note that this file is shared between
+// the nogo binary and the builder.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const nogoBaselineVersion = 1
+
+// nogoBaseline is the content of a baseline file.
+type nogoBaseline struct {
+	Version  int             `json:"version"`
+	Findings []baselineEntry `json:"findings"`
+}
+
+// baselineEntry identifies a known finding. Findings are keyed by analyzer,
+// file and fingerprint, which makes them insensitive to changes that only
+// move the offending code to another line. Message is informational.
+type baselineEntry struct {
+	Analyzer    string `json:"analyzer"`
+	File        string `json:"file"`
+	Fingerprint string `json:"fingerprint"`
+	Message     string `json:"message,omitempty"`
+	// Count is the number of identical findings covered by this entry.
+	Count int `json:"count"`
+}
+
+func readBaselineFile(path string) (*nogoBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %v", err)
+	}
+	var baseline nogoBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal baseline file %s: %v", path, err)
+	}
+	if baseline.Version != nogoBaselineVersion {
+		return nil, fmt.Errorf("baseline file %s has unsupported version %d, expected %d", path, baseline.Version, nogoBaselineVersion)
+	}
+	for i, entry := range baseline.Findings {
+		if entry.Analyzer == "" || entry.File == "" || entry.Fingerprint == "" {
+			return nil, fmt.Errorf("baseline file %s: finding %d must set analyzer, file and fingerprint", path, i)
+		}
+		if entry.Count < 1 {
+			baseline.Findings[i].Count = 1
+		}
+	}
+	return &baseline, nil
+}
+
+// baselineFingerprint returns a fingerprint of a diagnostic with the given
+// message reported on the given line of source code. Whitespace in the line is
+// normalized so that reformatting the code does not invalidate the baseline.
+func baselineFingerprint(message, line string) string {
+	h := sha256.New()
+	h.Write([]byte(message))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(line), " ")))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+type baselineKey struct {
+	analyzer, file, fingerprint string
+}
+
+// baselineMatcher matches findings against the entries of a baseline. Each
+// entry suppresses at most Count findings.
+type baselineMatcher struct {
+	remaining map[baselineKey]int
+}
+
+func newBaselineMatcher(entries []baselineEntry) *baselineMatcher {
+	m := &baselineMatcher{remaining: make(map[baselineKey]int)}
+	for _, e := range entries {
+		m.remaining[baselineKey{e.Analyzer, e.File, e.Fingerprint}] += e.Count
+	}
+	return m
+}
+
+// match reports whether the given finding is covered by the baseline.
+func (m *baselineMatcher) match(analyzer, file, fingerprint string) bool {
+	key := baselineKey{analyzer, file, fingerprint}
+	if m.remaining[key] == 0 {
+		return false
+	}
+	m.remaining[key]--
+	return true
+}
+
+// newNogoBaseline builds a baseline from a list of findings, merging
+// identical ones.
+func newNogoBaseline(findings []baselineEntry) *nogoBaseline {
+	counts := make(map[baselineKey]*baselineEntry)
+	baseline := &nogoBaseline{Version: nogoBaselineVersion, Findings: []baselineEntry{}}
+	for _, f := range findings {
+		key := baselineKey{f.Analyzer, f.File, f.Fingerprint}
+		if e, ok := counts[key]; ok {
+			e.Count += f.Count
+			continue
+		}
+		e := f
+		counts[key] = &e
+	}
+	for _, e := range counts {
+		baseline.Findings = append(baseline.Findings, *e)
+	}
+	sort.Slice(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Analyzer != b.Analyzer {
+			return a.Analyzer < b.Analyzer
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return baseline
+}
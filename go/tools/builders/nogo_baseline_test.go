@@ -0,0 +1,140 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBaselineFingerprint(t *testing.T) {
+	fp := baselineFingerprint("redundant or", "\treturn a || a")
+	if got := baselineFingerprint("redundant or", "return  a ||\ta  "); got != fp {
+		t.Errorf("fingerprint changed with whitespace: %s != %s", got, fp)
+	}
+	if got := baselineFingerprint("redundant and", "\treturn a || a"); got == fp {
+		t.Errorf("fingerprint did not change with message")
+	}
+	if got := baselineFingerprint("redundant or", "\treturn b || b"); got == fp {
+		t.Errorf("fingerprint did not change with code")
+	}
+}
+
+func TestBaselineMatcher(t *testing.T) {
+	m := newBaselineMatcher([]baselineEntry{
+		{Analyzer: "bools", File: "a.go", Fingerprint: "f1", Count: 2},
+		{Analyzer: "printf", File: "a.go", Fingerprint: "f2", Count: 1},
+	})
+	tests := []struct {
+		analyzer, file, fingerprint string
+		want                        bool
+	}{
+		{"bools", "a.go", "f1", true},
+		{"bools", "b.go", "f1", false},
+		{"printf", "a.go", "f1", false},
+		{"bools", "a.go", "f1", true},
+		// The entry only covers two findings.
+		{"bools", "a.go", "f1", false},
+		{"printf", "a.go", "f2", true},
+	}
+	for i, tc := range tests {
+		if got := m.match(tc.analyzer, tc.file, tc.fingerprint); got != tc.want {
+			t.Errorf("%d: match(%q, %q, %q) = %v, want %v", i, tc.analyzer, tc.file, tc.fingerprint, got, tc.want)
+		}
+	}
+}
+
+func TestReadBaselineFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name, content string
+		wantErr       bool
+		want          []baselineEntry
+	}{
+		{
+			name:    "valid",
+			content: `{"version": 1, "findings": [{"analyzer": "bools", "file": "a.go", "fingerprint": "f1"}]}`,
+			want:    []baselineEntry{{Analyzer: "bools", File: "a.go", Fingerprint: "f1", Count: 1}},
+		},
+		{
+			name:    "bad version",
+			content: `{"version": 2, "findings": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing fingerprint",
+			content: `{"version": 1, "findings": [{"analyzer": "bools", "file": "a.go"}]}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".json")
+			if err := os.WriteFile(path, []byte(tc.content), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readBaselineFile(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Findings, tc.want) {
+				t.Errorf("got %+v, want %+v", got.Findings, tc.want)
+			}
+		})
+	}
+}
+
+func TestGenNogoBaseline(t *testing.T) {
+	dir := t.TempDir()
+	reportDir := filepath.Join(dir, "lib_nogo")
+	if err := os.Mkdir(reportDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	report := `{
+  "package": "example.com/lib",
+  "diagnostics": [
+    {"analyzer": "bools", "message": "m1", "range": {"file": "lib/b.go"}, "fingerprint": "f1"},
+    {"analyzer": "bools", "message": "m1", "range": {"file": "lib/b.go"}, "fingerprint": "f1", "suppressed_by": "baseline"},
+    {"analyzer": "printf", "message": "m2", "range": {"file": "lib/a.go"}, "fingerprint": "f2"},
+    {"analyzer": "printf", "message": "m3", "range": {"file": "lib/a.go"}, "fingerprint": "f3", "suppressed_by": "nolint"}
+  ]
+}`
+	if err := os.WriteFile(filepath.Join(reportDir, "nogo.json"), []byte(report), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "baseline.json")
+	// A report directory without a nogo.json file is skipped.
+	if err := genNogoBaseline([]string{"-output", out, reportDir, filepath.Join(dir, "missing_nogo")}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBaselineFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []baselineEntry{
+		{Analyzer: "printf", File: "lib/a.go", Fingerprint: "f2", Message: "m2", Count: 1},
+		{Analyzer: "bools", File: "lib/b.go", Fingerprint: "f1", Message: "m1", Count: 2},
+	}
+	if !reflect.DeepEqual(got.Findings, want) {
+		t.Errorf("got %+v, want %+v", got.Findings, want)
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the caches nogo keeps between the requests it handles
+// as a persistent worker.
+//
+// The type information of imported packages is shared by requests. Analyzing
+// a package must produce the same diagnostics and facts as the nogo binary
+// started for that package alone. Importing a package from its export data
+// only creates the objects of its dependencies that are referenced by its
+// API, and facts about other objects are discarded when they are decoded.
+// Once a dependency is complete in the cache, more of its objects are known,
+// so a package whose imported facts describe objects that a new process would
+// not have created is analyzed again without the cache.
+
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/internal/facts"
+)
+
+const (
+	// nogoWorkerCacheSize is the number of imported packages and of facts
+	// files a nogo worker keeps in memory.
+	nogoWorkerCacheSize = 2000
+
+	// maxCacheFileSetBase bounds the positions allocated in the file set of
+	// the cache. Files are never removed from the file set before Go 1.20 and
+	// its base never decreases, so the cache is reset when it is exceeded.
+	maxCacheFileSetBase = 1 << 30
+)
+
+// exportDataCache holds the type information of imported packages, keyed by
+// package path and the digest of the archive it was read from, as well as
+// the contents of facts files, keyed by their digest.
+type exportDataCache struct {
+	capacity int
+
+	fset     *token.FileSet
+	packages map[string]*types.Package
+	entries  map[string]*cacheEntry
+	lru      *list.List // of *cacheEntry, most recently used first
+	// stale is set when the type information in the cache is found to be
+	// inconsistent with an archive, which resets the cache on release.
+	stale bool
+
+	fileDigests map[string]fileDigest
+	facts       map[string]*factsFile
+	factsLRU    *list.List // of *factsFile, most recently used first
+}
+
+// cacheEntry is a package that was imported from the export data in an
+// archive, as opposed to packages that were only referenced by it.
+type cacheEntry struct {
+	path, digest string
+	pkg          *types.Package
+	// views maps the path of each package referenced by the export data of
+	// pkg to the names of its package-level objects that are created by
+	// importing pkg on its own.
+	views map[string]map[string]bool
+	elem  *list.Element
+}
+
+// fileDigest is the digest of a file, which is valid as long as the size and
+// modification time of the file do not change.
+type fileDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+func newExportDataCache(capacity int) *exportDataCache {
+	c := &exportDataCache{
+		capacity:    capacity,
+		fileDigests: make(map[string]fileDigest),
+		facts:       make(map[string]*factsFile),
+		factsLRU:    list.New(),
+	}
+	c.reset()
+	return c
+}
+
+// reset drops all type information from the cache.
+func (c *exportDataCache) reset() {
+	c.fset = token.NewFileSet()
+	c.packages = make(map[string]*types.Package)
+	c.entries = make(map[string]*cacheEntry)
+	c.lru = list.New()
+	c.stale = false
+}
+
+// digest returns the hex-encoded SHA-256 digest of the file at path.
+func (c *exportDataCache) digest(path string) (string, error) {
+	path = abs(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	d, ok := c.fileDigests[path]
+	if ok && d.size == info.Size() && d.modTime.Equal(info.ModTime()) {
+		return d.digest, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	d = fileDigest{size: info.Size(), modTime: info.ModTime(), digest: hex.EncodeToString(h.Sum(nil))}
+	c.fileDigests[path] = d
+	return d.digest, nil
+}
+
+// acquire prepares the type information in the cache to be used by a request,
+// which must call release when done. Packages read from other archives than
+// those in packageFile are evicted.
+func (c *exportDataCache) acquire(packageFile map[string]string) {
+	var stale []string
+	for path, e := range c.entries {
+		archive, ok := packageFile[path]
+		if !ok {
+			continue
+		}
+		if d, err := c.digest(archive); err != nil || d != e.digest {
+			stale = append(stale, path)
+		}
+	}
+	c.evict(stale)
+}
+
+// release removes the given source files of the analyzed package from the
+// file set and evicts the least recently used packages in excess of the
+// capacity of the cache.
+func (c *exportDataCache) release(files []*token.File) {
+	removeFiles(c.fset, files)
+	for len(c.entries) > c.capacity {
+		c.evict([]string{c.lru.Back().Value.(*cacheEntry).path})
+	}
+	if c.stale || c.fset.Base() > maxCacheFileSetBase {
+		c.reset()
+	}
+}
+
+// lookup returns the package with the given path if it was read from an
+// archive with the given digest.
+func (c *exportDataCache) lookup(path, digest string) *types.Package {
+	e, ok := c.entries[path]
+	if !ok || e.digest != digest {
+		return nil
+	}
+	c.lru.MoveToFront(e.elem)
+	return e.pkg
+}
+
+// add records that pkg was read from the export data in an archive with the
+// given digest. private is the same package imported on its own.
+func (c *exportDataCache) add(digest string, pkg, private *types.Package) {
+	views := make(map[string]map[string]bool)
+	for _, imp := range private.Imports() {
+		names := make(map[string]bool)
+		for _, name := range imp.Scope().Names() {
+			names[name] = true
+		}
+		views[imp.Path()] = names
+	}
+	e := &cacheEntry{path: pkg.Path(), digest: digest, pkg: pkg, views: views}
+	e.elem = c.lru.PushFront(e)
+	c.entries[e.path] = e
+}
+
+// evict removes the packages with the given paths from the cache, together
+// with the packages whose export data references them.
+func (c *exportDataCache) evict(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	dependents := make(map[string][]string)
+	for path, e := range c.entries {
+		for _, imp := range e.pkg.Imports() {
+			dependents[imp.Path()] = append(dependents[imp.Path()], path)
+		}
+	}
+	for len(paths) > 0 {
+		path := paths[len(paths)-1]
+		paths = paths[:len(paths)-1]
+		e, ok := c.entries[path]
+		if !ok {
+			continue
+		}
+		c.lru.Remove(e.elem)
+		delete(c.entries, path)
+		paths = append(paths, dependents[path]...)
+	}
+
+	// Only keep the packages that are still referenced.
+	c.packages = make(map[string]*types.Package)
+	for path, e := range c.entries {
+		c.packages[path] = e.pkg
+	}
+	for _, e := range c.entries {
+		for _, imp := range e.pkg.Imports() {
+			if _, ok := c.packages[imp.Path()]; !ok {
+				c.packages[imp.Path()] = imp
+			}
+		}
+	}
+}
+
+// consistent reports whether the objects created by importing private on its
+// own, with positions in fset, are the same as those in the cache. This is
+// not the case if the cache holds packages that differ from those the
+// archive of private was compiled against, for example because they were
+// built in another configuration.
+func (c *exportDataCache) consistent(private *types.Package, fset *token.FileSet) bool {
+	for _, pkg := range append([]*types.Package{private}, private.Imports()...) {
+		shared := c.packages[pkg.Path()]
+		if shared == nil || shared.Name() != pkg.Name() {
+			return false
+		}
+		for _, name := range pkg.Scope().Names() {
+			obj, sharedObj := pkg.Scope().Lookup(name), shared.Scope().Lookup(name)
+			if sharedObj == nil || describeObject(obj, fset) != describeObject(sharedObj, c.fset) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// describeObject returns a description of the declaration of a package-level
+// object, including its position and the methods of named types.
+func describeObject(obj types.Object, fset *token.FileSet) string {
+	qualifier := func(pkg *types.Package) string { return pkg.Path() }
+	desc := []string{fset.Position(obj.Pos()).String(), types.ObjectString(obj, qualifier)}
+	if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+		named, _ := tn.Type().(*types.Named)
+		var methods []string
+		for i := 0; named != nil && i < named.NumMethods(); i++ {
+			m := named.Method(i)
+			methods = append(methods, fset.Position(m.Pos()).String()+" "+types.ObjectString(m, qualifier))
+		}
+		sort.Strings(methods)
+		desc = append(desc, methods...)
+	}
+	return strings.Join(desc, "\n")
+}
+
+// factsFile is the content of a facts file written by nogo.
+type factsFile struct {
+	digest string
+	data   []byte
+	elem   *list.Element
+}
+
+// readFacts returns the content of the facts file at path.
+func (c *exportDataCache) readFacts(path string) ([]byte, error) {
+	digest, err := c.digest(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := c.facts[digest]; ok {
+		c.factsLRU.MoveToFront(f.elem)
+		return f.data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &factsFile{digest: digest, data: data}
+	f.elem = c.factsLRU.PushFront(f)
+	c.facts[digest] = f
+	for len(c.facts) > c.capacity {
+		oldest := c.factsLRU.Remove(c.factsLRU.Back()).(*factsFile)
+		delete(c.facts, oldest.digest)
+	}
+	return data, nil
+}
+
+// hiddenFacts reports whether set holds facts of the given types about
+// packages or objects that do not exist in a process that only imported the
+// packages in imported. Complete packages are imported directly. Other
+// packages only contain the objects created by importing the packages in
+// imported, which is recorded in the views of their entries.
+func (c *exportDataCache) hiddenFacts(set *facts.Set, factTypes map[reflect.Type]bool, imported map[string]bool) bool {
+	// Package facts are only read from the facts of direct imports.
+	for _, f := range set.AllPackageFacts(factTypes) {
+		if !imported[f.Package.Path()] {
+			return true
+		}
+	}
+	for _, f := range set.AllObjectFacts(factTypes) {
+		pkgPath := f.Object.Pkg().Path()
+		if imported[pkgPath] {
+			continue
+		}
+		path, err := objectpath.For(f.Object)
+		if err != nil {
+			return true
+		}
+		// Object paths start with the name of a package-level object.
+		name := string(path)
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[:i]
+		}
+		visible := false
+		for imp := range imported {
+			if e, ok := c.entries[imp]; ok && e.views[pkgPath][name] {
+				visible = true
+				break
+			}
+		}
+		if !visible {
+			return true
+		}
+	}
+	return false
+}
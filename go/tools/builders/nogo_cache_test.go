@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/gob"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/facts"
+)
+
+// newTestPackage returns a complete package with the given imports and an
+// integer variable for each of the given names.
+func newTestPackage(path string, imports []*types.Package, names ...string) *types.Package {
+	pkg := types.NewPackage(path, filepath.Base(path))
+	for _, name := range names {
+		pkg.Scope().Insert(types.NewVar(token.NoPos, pkg, name, types.Typ[types.Int]))
+	}
+	pkg.SetImports(imports)
+	pkg.MarkComplete()
+	return pkg
+}
+
+func cachedPaths(c *exportDataCache) []string {
+	var paths []string
+	for path := range c.entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestExportDataCacheDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.a")
+	if err := os.WriteFile(path, []byte("a"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	c := newExportDataCache(10)
+	d1, err := c.digest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := c.digest(path); d != d1 {
+		t.Errorf("got digest %s for the same file, want %s", d, d1)
+	}
+
+	if err := os.WriteFile(path, []byte("ab"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	d2, err := c.digest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2 == d1 {
+		t.Error("got the same digest after the file changed")
+	}
+}
+
+func TestExportDataCacheEvict(t *testing.T) {
+	dir := t.TempDir()
+	archive := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	packageFile := map[string]string{
+		"example.com/a": archive("a.a", "a"),
+		"example.com/b": archive("b.a", "b"),
+		"example.com/c": archive("c.a", "c"),
+	}
+
+	c := newExportDataCache(10)
+	c.acquire(packageFile)
+	a := newTestPackage("example.com/a", nil, "A")
+	b := newTestPackage("example.com/b", []*types.Package{a}, "B")
+	c2 := newTestPackage("example.com/c", nil, "C")
+	for _, pkg := range []*types.Package{a, b, c2} {
+		d, err := c.digest(packageFile[pkg.Path()])
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.packages[pkg.Path()] = pkg
+		c.add(d, pkg, pkg)
+	}
+	c.release(nil)
+
+	// Packages that reference a changed package are evicted as well.
+	time.Sleep(10 * time.Millisecond)
+	archive("a.a", "changed")
+	c.acquire(packageFile)
+	if got, want := cachedPaths(c), []string{"example.com/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cached packages %v, want %v", got, want)
+	}
+	if _, ok := c.packages["example.com/a"]; ok {
+		t.Error("evicted package is still referenced")
+	}
+	c.release(nil)
+
+	// The least recently used packages are evicted on release.
+	small := newExportDataCache(1)
+	small.acquire(packageFile)
+	small.add("a", a, a)
+	small.add("c", c2, c2)
+	if small.lookup("example.com/a", "a") != a {
+		t.Error("could not look up a cached package")
+	}
+	if small.lookup("example.com/a", "other") != nil {
+		t.Error("looked up a package read from another archive")
+	}
+	small.release(nil)
+	if got, want := cachedPaths(small), []string{"example.com/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cached packages %v, want %v", got, want)
+	}
+}
+
+func TestExportDataCacheConsistent(t *testing.T) {
+	c := newExportDataCache(10)
+	a := newTestPackage("example.com/a", nil, "A", "B")
+	b := newTestPackage("example.com/b", []*types.Package{a}, "B")
+	c.packages[a.Path()], c.packages[b.Path()] = a, b
+
+	same := newTestPackage("example.com/a", nil, "A")
+	if !c.consistent(newTestPackage("example.com/b", []*types.Package{same}, "B"), token.NewFileSet()) {
+		t.Error("got inconsistent packages with the same objects")
+	}
+
+	other := types.NewPackage("example.com/a", "a")
+	other.Scope().Insert(types.NewVar(token.NoPos, other, "A", types.Typ[types.String]))
+	if c.consistent(newTestPackage("example.com/b", []*types.Package{other}, "B"), token.NewFileSet()) {
+		t.Error("got consistent packages with objects of different types")
+	}
+}
+
+type testFact struct{ Value string }
+
+func (*testFact) AFact() {}
+
+func TestExportDataCacheHiddenFacts(t *testing.T) {
+	gob.Register(&testFact{})
+	factTypes := map[reflect.Type]bool{reflect.TypeOf(&testFact{}): true}
+
+	// a is complete in the cache, but importing b on its own only creates
+	// the type A of a, which is the type of the variable B of b.
+	newNamed := func(pkg *types.Package, name string) *types.Named {
+		named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), types.Typ[types.Int], nil)
+		pkg.Scope().Insert(named.Obj())
+		return named
+	}
+	newB := func(a *types.Package) *types.Package {
+		b := types.NewPackage("example.com/b", "b")
+		b.Scope().Insert(types.NewVar(token.NoPos, b, "B", a.Scope().Lookup("A").Type()))
+		b.SetImports([]*types.Package{a})
+		b.MarkComplete()
+		return b
+	}
+	a := types.NewPackage("example.com/a", "a")
+	newNamed(a, "A")
+	newNamed(a, "Hidden")
+	a.MarkComplete()
+	aView := types.NewPackage("example.com/a", "a")
+	newNamed(aView, "A")
+	b, bPrivate := newB(a), newB(aView)
+	x := newTestPackage("example.com/x", []*types.Package{b})
+
+	decode := func(pkg *types.Package, data map[string][]byte) *facts.Set {
+		set, err := facts.NewDecoder(pkg).Decode(func(path string) ([]byte, error) {
+			return data[path], nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+	// factsOfX returns the facts that x imports from b, which re-exports the
+	// facts about the given types of a.
+	factsOfX := func(names ...string) *facts.Set {
+		setA := decode(a, nil)
+		for _, name := range names {
+			setA.ExportObjectFact(a.Scope().Lookup(name), &testFact{name})
+		}
+		setB := decode(b, map[string][]byte{"example.com/a": setA.Encode()})
+		setB.ExportPackageFact(&testFact{"b"})
+		return decode(x, map[string][]byte{"example.com/b": setB.Encode()})
+	}
+
+	c := newExportDataCache(10)
+	c.add("b", b, bPrivate)
+	imported := map[string]bool{"example.com/b": true}
+	if c.hiddenFacts(factsOfX("A"), factTypes, imported) {
+		t.Error("got hidden facts about objects created by importing b")
+	}
+	if !c.hiddenFacts(factsOfX("A", "Hidden"), factTypes, imported) {
+		t.Error("got no hidden facts about an object that importing b does not create")
+	}
+
+	// All facts about directly imported packages are visible.
+	c.add("a", a, a)
+	imported["example.com/a"] = true
+	if c.hiddenFacts(factsOfX("A", "Hidden"), factTypes, imported) {
+		t.Error("got hidden facts about a directly imported package")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.20
+// +build go1.20
+
+package main
+
+import "go/token"
+
+// removeFiles removes files from fset so that their line tables can be
+// garbage collected.
+func removeFiles(fset *token.FileSet, files []*token.File) {
+	for _, f := range files {
+		fset.RemoveFile(f)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -19,75 +20,58 @@ import (
 type diagnosticEntry struct {
 	analysis.Diagnostic
 	analyzerName string
+	// severity is one of the severity* constants.
+	severity string
+	// suppressedBy is the mechanism that suppressed this diagnostic, if any.
+	// It is one of the suppressedBy* constants.
+	suppressedBy string
+	// justification is the explanation given in the nolint directive that
+	// suppressed this diagnostic, if any.
+	justification string
+	// fingerprint identifies the diagnostic in baseline files.
+	fingerprint string
+	// fixPolicy selects the suggested fix that is applied.
+	fixPolicy fixPolicy
 }
 
-// A nogoEdit describes the replacement of a portion of a text file.
-type nogoEdit struct {
-	New   string // the replacement
-	Start int    // starting byte offset of the region to replace
-	End   int    // (exclusive) ending byte offset of the region to replace
-	analyzerName string
-}
-
-type fileChange struct {
-	fileName string
-	changes []nogoEdit
-}
-
-func (e nogoEdit) String() string {
-	return fmt.Sprintf("{Start:%d,End:%d,New:%q}", e.Start, e.End, e.New)
-}
-
-func (e nogoEdit) Equals(other nogoEdit) bool {
-	return e.Start == other.Start && e.End == other.End && e.New == other.New
+// fixPolicy selects which of the alternative suggested fixes of a diagnostic
+// may be applied.
+type fixPolicy struct {
+	// mode is one of the fixPolicy* constants. When empty, it is
+	// fixPolicyFirst.
+	mode string
+	// message matches the messages of the fixes that may be applied if mode
+	// is fixPolicyRegex.
+	message *regexp.Regexp
 }
 
-// byStartEnd orders a slice of nogoEdits by (start, end) offset.
-// This ordering puts insertions (end = start) before deletions
-// (end > start) at the same point. We will use a stable sort to preserve
-// the order of multiple insertions at the same point.
-type byStartEnd []nogoEdit
-
-func (a byStartEnd) Len() int { return len(a) }
-func (a byStartEnd) Less(i, j int) bool {
-	if a[i].Start != a[j].Start {
-		return a[i].Start < a[j].Start
+// allows reports whether the suggested fix sf may be applied.
+func (p fixPolicy) allows(sf analysis.SuggestedFix) bool {
+	switch p.mode {
+	case fixPolicyNone:
+		return false
+	case fixPolicyRegex:
+		return p.message.MatchString(sf.Message)
+	default:
+		return true
 	}
-	return a[i].End < a[j].End
 }
-func (a byStartEnd) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-
-
-// applyEdits applies a sequence of nogoEdits to the src byte slice and returns the result.
-// Edits are applied in order of start offset; edits with the same start offset are applied in the order they were provided.
-// The function assumes that edits are unique, sorted and non-overlapping.
-// This is guaranteed by invoking validate() earlier.
-func applyEdits(src []byte, edits []nogoEdit) []byte {
-	size := len(src)
-	// performance only: this computes the size for preallocation to avoid the slice resizing below.
-	for _, edit := range edits {
-		size += len(edit.New) + edit.Start - edit.End
-	}
 
-	out := make([]byte, 0, size)
-	lastEnd := 0
-	for _, edit := range edits {
-		out = append(out, src[lastEnd:edit.Start]...)
-		out = append(out, edit.New...)
-		lastEnd = edit.End
-	}
-	out = append(out, src[lastEnd:]...)
-
-	return out
+type fileChange struct {
+	fileName string
+	changes []nogoEdit
 }
 
-// getFixes merges the suggested fixes from all analyzers, returns one fileChange object per file,
-// while reporting conflicts as error.
-func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange, error) {
+// getFixes merges the suggested fixes from all analyzers, returns one fileChange object per file
+// as well as the index of the suggested fix applied for each entry, or -1, while reporting
+// conflicts as error.
+func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange, []int, error) {
 	var allErrors []error
+	selected := make([]int, len(entries))
 	finalChanges := make(map[string][]nogoEdit)
 
-	for _, entry := range entries {
+	for i, entry := range entries {
+		selected[i] = -1
 		if len(entry.Diagnostic.SuggestedFixes) == 0 {
 			continue
 		}
@@ -96,10 +80,16 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 		// We will go over all the suggested fixes until the we find one with no conflict
 		// with previously selected fixes. No backtracking is used for simplicity and performance. If
 		// none of the suggested fixes of a diagnostic can be applied, the diagnostic entry will be skipped
-		// with an error message to the user.
+		// with an error message to the user. Fixes that the fix policy of the analyzer doesn't allow are
+		// never applied.
 		foundApplicableFix := false
+		tried := false
 		var perAnalyzerErrors []error
-		for _, sf := range entry.Diagnostic.SuggestedFixes {
+		for j, sf := range entry.Diagnostic.SuggestedFixes {
+			if !entry.fixPolicy.allows(sf) {
+				continue
+			}
+			tried = true
 			candidateChanges := make(map[string][]nogoEdit)
 			applicable := true
 			for _, edit := range sf.TextEdits {
@@ -141,12 +131,13 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 				for fileName, edits := range candidateChanges {
 					finalChanges[fileName] = edits
 				}
+				selected[i] = j
 				foundApplicableFix = true
 				break
 			}
 			// Move on to the next SuggestedFix of the same Diagnostic if any edit of the current SuggestedFix has issues.
 		}
-		if !foundApplicableFix {
+		if tried && !foundApplicableFix {
 			allErrors = append(allErrors, fmt.Errorf(
 				"ignoring suggested fixes from analyzer %q at %s because:\n\t%s",
 				entry.analyzerName, fileSet.Position(entry.Pos),
@@ -161,7 +152,7 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 	}
 
 	if len(allErrors) == 0 {
-		return finalFileChanges, nil
+		return finalFileChanges, selected, nil
 	}
 
 	var errMsg bytes.Buffer
@@ -169,45 +160,66 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 		errMsg.WriteString("\n\t")
 		errMsg.WriteString(e.Error())
 	}
-	return finalFileChanges, errors.New(errMsg.String())
+	return finalFileChanges, selected, errors.New(errMsg.String())
 }
 
-
-// validate whether the list of edits has overlaps or contains invalid ones.
-// If there is any issue, an error is returned. Otherwise, the function
-// returns a new list of edits that is sorted and unique.
-func validate(edits []nogoEdit) ([]nogoEdit, error) {
-	if len(edits) == 0 {
-		return nil, nil
+// newNogoFixes lists the suggested fixes of the given diagnostics, of which
+// getFixes applied the ones in selected, in the fixes file of a package. File
+// names are made relative to cwd if possible.
+func newNogoFixes(packagePath, cwd string, entries []diagnosticEntry, selected []int, fset *token.FileSet) (*nogoFixes, error) {
+	fixes := &nogoFixes{
+		Package:     packagePath,
+		Files:       make(map[string]string),
+		Diagnostics: []fixDiagnostic{},
 	}
-	validatedEdits := make([]nogoEdit, len(edits))
-	// avoid modifying the original slice for safety.
-	copy(validatedEdits, edits)
-	sort.Stable(byStartEnd(validatedEdits))
-	tail := 0
-	for i, cur := range validatedEdits {
-		if cur.Start > cur.End {
-			return nil, fmt.Errorf("invalid suggestion from %q: %s", cur.analyzerName, cur)
+	for i, entry := range entries {
+		if len(entry.SuggestedFixes) == 0 {
+			continue
 		}
-		if i > 0 {
-			prev := validatedEdits[i-1]
-			if prev.Equals(cur) {
-				// equivalent ones are safely skipped
-				continue
-			}
-
-			if prev.End > cur.Start {
-				return nil, fmt.Errorf("overlapping suggestions from %q and %q at %s and %s",
-					prev.analyzerName, cur.analyzerName, prev, cur)
+		pos := fset.Position(entry.Pos)
+		pos.Filename = relativeFilename(cwd, pos.Filename)
+		d := fixDiagnostic{
+			Analyzer: entry.analyzerName,
+			Position: pos.String(),
+			Message:  entry.Message,
+			Selected: selected[i],
+		}
+		for _, sf := range entry.SuggestedFixes {
+			alternative := fixAlternative{Message: sf.Message, Edits: []fixEdit{}}
+			for _, edit := range sf.TextEdits {
+				end := edit.End
+				if !end.IsValid() {
+					end = edit.Pos
+				}
+				file := fset.File(edit.Pos)
+				if file == nil {
+					// Such fixes are never applied, see getFixes, so only
+					// their message is listed.
+					alternative.Edits = []fixEdit{}
+					break
+				}
+				name := relativeFilename(cwd, file.Name())
+				if _, ok := fixes.Files[name]; !ok {
+					digest, err := fileSHA256(file.Name())
+					if err != nil {
+						return nil, err
+					}
+					fixes.Files[name] = digest
+				}
+				alternative.Edits = append(alternative.Edits, fixEdit{
+					File:    name,
+					Start:   file.Offset(edit.Pos),
+					End:     file.Offset(end),
+					NewText: string(edit.NewText),
+				})
 			}
+			d.Alternatives = append(d.Alternatives, alternative)
 		}
-		validatedEdits[tail] = cur
-		tail++
+		fixes.Diagnostics = append(fixes.Diagnostics, d)
 	}
-	return validatedEdits[:tail], nil
+	return fixes, nil
 }
 
-
 func writePatch(patchFile io.Writer, changes []fileChange) error {
 	// sort the changes by file name to make sure the patch is stable.
 	sort.Slice(changes, func(i, j int) bool {
@@ -240,6 +252,17 @@ func writePatch(patchFile io.Writer, changes []fileChange) error {
 	return nil
 }
 
+// relativeFilename returns filename relative to cwd if possible, with forward
+// slashes.
+func relativeFilename(cwd, filename string) string {
+	if cwd != "" && filename != "" {
+		if rel, err := filepath.Rel(cwd, filename); err == nil {
+			filename = rel
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
 func formatErrors(errs []error) []string {
 	result := make([]string, len(errs))
 	for i, err := range errs {
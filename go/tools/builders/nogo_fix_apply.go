@@ -0,0 +1,406 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Applies the suggested fixes written by nogo across a build to the source
+// tree, or prints them as a single patch.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each hunk of a diff.
+const diffContext = 3
+
+// fixPlan is the result of merging the fixes of several packages.
+type fixPlan struct {
+	// contents and edits are keyed by the file names in the fixes files,
+	// which are relative to the root of the workspace.
+	contents map[string][]byte
+	edits    map[string][]nogoEdit
+	// applied is the number of distinct fixes in edits.
+	applied int
+	// skipped explains why each of the fixes that are not applied was
+	// skipped.
+	skipped []string
+}
+
+func nogoFix(args []string) error {
+	flags := flag.NewFlagSet("nogofix", flag.ExitOnError)
+	reportsFile := flags.String("reports", "", "file listing nogo fix directories or nogo_fix.json files, one per line")
+	analyzers := flags.String("analyzers", "", "comma-separated list of analyzers whose fixes are applied (defaults to all analyzers)")
+	dryRun := flags.Bool("dry_run", false, "print the combined patch of all fixes instead of applying them")
+	out := flags.String("output", "", "file to write the patch to in dry-run mode (defaults to stdout)")
+	root := flags.String("root", "", "directory the fixed files are relative to (defaults to the workspace 'bazel run' was invoked in)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *root == "" {
+		*root = os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	}
+	if *root == "" {
+		*root = "."
+	}
+
+	paths, err := nogoOutputFiles(flags.Args(), *reportsFile, nogoFixesBasename)
+	if err != nil {
+		return err
+	}
+	var reports []*nogoFixes
+	for _, path := range paths {
+		fixes, err := readFixesFile(path)
+		if os.IsNotExist(err) {
+			// nogo did not suggest any fixes for this package.
+			continue
+		} else if err != nil {
+			return err
+		}
+		reports = append(reports, fixes)
+	}
+
+	var only map[string]bool
+	if *analyzers != "" {
+		only = make(map[string]bool)
+		for _, name := range strings.Split(*analyzers, ",") {
+			only[strings.TrimSpace(name)] = true
+		}
+	}
+	plan := planFixes(workingDirPath(*root), reports, only)
+	for _, reason := range plan.skipped {
+		fmt.Fprintf(os.Stderr, "skipping %s\n", reason)
+	}
+
+	if *dryRun {
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			f, err := os.Create(workingDirPath(*out))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return plan.writePatch(w)
+	}
+	if err := plan.apply(workingDirPath(*root)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "applied %d fixes to %d files, skipped %d fixes\n", plan.applied, len(plan.edits), len(plan.skipped))
+	return nil
+}
+
+// planFixes merges the fixes that nogo selected in reports whose analyzer is
+// in only, or all of them if only is nil. The edits of a fix are applied
+// together or not at all. Fixes are skipped if they overlap with a fix of
+// another package that was merged before or if the file they edit under root
+// changed after nogo ran. Identical fixes, for example those reported for a
+// library and its internal test, are applied once.
+func planFixes(root string, reports []*nogoFixes, only map[string]bool) *fixPlan {
+	plan := &fixPlan{
+		contents: make(map[string][]byte),
+		edits:    make(map[string][]nogoEdit),
+	}
+	reports = append([]*nogoFixes(nil), reports...)
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Package < reports[j].Package
+	})
+
+	// unusable records why the files that can't be edited were rejected.
+	unusable := make(map[string]string)
+	checkFile := func(name, digest string) string {
+		if reason, ok := unusable[name]; ok {
+			return reason
+		}
+		if _, ok := plan.contents[name]; ok {
+			if sha256Hex(plan.contents[name]) != digest {
+				return fmt.Sprintf("%s changed after nogo ran", name)
+			}
+			return ""
+		}
+		var reason string
+		if !isSourceFile(name) {
+			reason = fmt.Sprintf("%s is not a source file of the workspace", name)
+		} else if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			reason = err.Error()
+		} else if sha256Hex(data) != digest {
+			reason = fmt.Sprintf("%s changed after nogo ran", name)
+		} else {
+			plan.contents[name] = data
+			return ""
+		}
+		unusable[name] = reason
+		return reason
+	}
+
+	for _, report := range reports {
+		for _, d := range report.Diagnostics {
+			if d.Selected < 0 || d.Selected >= len(d.Alternatives) || (only != nil && !only[d.Analyzer]) {
+				continue
+			}
+			fix := d.Alternatives[d.Selected]
+			describe := func(reason string) string {
+				return fmt.Sprintf("fix %q from analyzer %q at %s in package %s: %s", fix.Message, d.Analyzer, d.Position, report.Package, reason)
+			}
+
+			candidates := make(map[string][]nogoEdit)
+			var reason string
+			for _, edit := range fix.Edits {
+				if reason = checkFile(edit.File, report.Files[edit.File]); reason != "" {
+					break
+				}
+				if edit.Start < 0 || edit.End > len(plan.contents[edit.File]) {
+					reason = fmt.Sprintf("edit of %s at offsets %d to %d is out of range", edit.File, edit.Start, edit.End)
+					break
+				}
+				candidates[edit.File] = append(candidates[edit.File], nogoEdit{
+					Start:        edit.Start,
+					End:          edit.End,
+					New:          edit.NewText,
+					analyzerName: d.Analyzer,
+				})
+			}
+			if reason != "" {
+				plan.skipped = append(plan.skipped, describe(reason))
+				continue
+			}
+
+			added := false
+			for name, edits := range candidates {
+				merged, err := validate(append(edits, plan.edits[name]...))
+				if err != nil {
+					reason = err.Error()
+					break
+				}
+				added = added || len(merged) > len(plan.edits[name])
+				candidates[name] = merged
+			}
+			if reason != "" {
+				plan.skipped = append(plan.skipped, describe(reason))
+				continue
+			}
+			for name, edits := range candidates {
+				plan.edits[name] = edits
+			}
+			if added {
+				plan.applied++
+			}
+		}
+	}
+	return plan
+}
+
+// isSourceFile reports whether name, relative to the root of the workspace,
+// is a file of the main repository rather than an output or a file of an
+// external repository.
+func isSourceFile(name string) bool {
+	if name == "" || path.Clean(name) != name || path.IsAbs(name) || filepath.IsAbs(name) {
+		return false
+	}
+	first := strings.SplitN(name, "/", 2)[0]
+	return first != ".." && first != "external" && !strings.HasPrefix(first, "bazel-")
+}
+
+// files returns the names of the files edited by the plan in sorted order.
+func (p *fixPlan) files() []string {
+	names := make([]string, 0, len(p.edits))
+	for name := range p.edits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply writes the fixed files under root.
+func (p *fixPlan) apply(root string) error {
+	for _, name := range p.files() {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, applyEdits(p.contents[name], p.edits[name]), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePatch writes the fixes as a unified diff that can be applied with
+// 'patch -p1' from the root of the workspace.
+func (p *fixPlan) writePatch(w io.Writer) error {
+	for _, name := range p.files() {
+		if _, err := io.WriteString(w, unifiedDiff(name, p.contents[name], p.edits[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineChange replaces the lines [start, start+len(old)) of a file with new.
+type lineChange struct {
+	start    int
+	old, new []string
+}
+
+// unifiedDiff returns the unified diff between src and the result of applying
+// edits to it, which must be sorted and must not overlap. Only the lines
+// touched by the edits are compared.
+func unifiedDiff(name string, src []byte, edits []nogoEdit) string {
+	lines := splitLines(string(src))
+	n := len(lines)
+	lineStarts := make([]int, n+1)
+	for i, line := range lines {
+		lineStarts[i+1] = lineStarts[i] + len(line)
+	}
+	// lineOf returns the index of the line containing the byte at off. An
+	// offset at the end of a file ending with a newline is on line n.
+	lineOf := func(off int) int {
+		i := sort.Search(n, func(i int) bool { return lineStarts[i] > off })
+		if i == n && off == len(src) && (n == 0 || src[len(src)-1] == '\n') {
+			return n
+		}
+		return i - 1
+	}
+
+	// Group the edits whose lines touch, and compare the lines of each group
+	// before and after applying its edits.
+	var changes []lineChange
+	for i := 0; i < len(edits); {
+		first, last := lineOf(edits[i].Start), lineOf(edits[i].End)+1
+		j := i + 1
+		for ; j < len(edits) && lineOf(edits[j].Start) <= last; j++ {
+			if end := lineOf(edits[j].End) + 1; end > last {
+				last = end
+			}
+		}
+		if last > n {
+			last = n
+		}
+		base := lineStarts[first]
+		group := make([]nogoEdit, 0, j-i)
+		for _, edit := range edits[i:j] {
+			edit.Start -= base
+			edit.End -= base
+			group = append(group, edit)
+		}
+		before := lines[first:last]
+		after := splitLines(string(applyEdits(src[base:lineStarts[last]], group)))
+		i = j
+
+		// Drop the lines that did not change.
+		for len(before) > 0 && len(after) > 0 && before[0] == after[0] {
+			before, after = before[1:], after[1:]
+			first++
+		}
+		for len(before) > 0 && len(after) > 0 && before[len(before)-1] == after[len(after)-1] {
+			before, after = before[:len(before)-1], after[:len(after)-1]
+		}
+		if len(before) > 0 || len(after) > 0 {
+			changes = append(changes, lineChange{start: first, old: before, new: after})
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	delta := 0
+	for i := 0; i < len(changes); {
+		// Changes whose context overlaps end up in the same hunk.
+		j := i + 1
+		for ; j < len(changes); j++ {
+			prev := changes[j-1]
+			if changes[j].start-(prev.start+len(prev.old)) > 2*diffContext {
+				break
+			}
+		}
+		start := changes[i].start - diffContext
+		if start < 0 {
+			start = 0
+		}
+		last := changes[j-1]
+		end := last.start + len(last.old) + diffContext
+		if end > n {
+			end = n
+		}
+
+		var body []string
+		pos := start
+		hunkDelta := 0
+		for _, c := range changes[i:j] {
+			for ; pos < c.start; pos++ {
+				body = append(body, " "+lines[pos])
+			}
+			for _, line := range c.old {
+				body = append(body, "-"+line)
+			}
+			for _, line := range c.new {
+				body = append(body, "+"+line)
+			}
+			pos = c.start + len(c.old)
+			hunkDelta += len(c.new) - len(c.old)
+		}
+		for ; pos < end; pos++ {
+			body = append(body, " "+lines[pos])
+		}
+		oldCount := end - start
+		newCount := oldCount + hunkDelta
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(start, oldCount), hunkRange(start+delta, newCount))
+		for _, line := range body {
+			b.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		delta += hunkDelta
+		i = j
+	}
+	return b.String()
+}
+
+// hunkRange formats the range of lines [start, start+count) in a hunk header.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		// An empty range refers to the line before it.
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// splitLines splits s into lines that keep their terminating newline.
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
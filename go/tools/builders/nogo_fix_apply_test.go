@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanFixes(t *testing.T) {
+	root := t.TempDir()
+	src := "package a\n\nvar x = 1\n\nvar y = 2\n"
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte(src), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256Hex([]byte(src))
+	xStart := strings.Index(src, "x")
+	yStart := strings.Index(src, "y")
+
+	rename := fixDiagnostic{
+		Analyzer: "rename",
+		Position: "a.go:3:5",
+		Message:  "x is a bad name",
+		Alternatives: []fixAlternative{
+			{Message: "rename x to z", Edits: []fixEdit{{File: "a.go", Start: xStart, End: xStart + 1, NewText: "z"}}},
+		},
+	}
+	conflicting := fixDiagnostic{
+		Analyzer: "other",
+		Position: "a.go:3:5",
+		Message:  "x and y are bad names",
+		Alternatives: []fixAlternative{
+			{Message: "remove x", Edits: []fixEdit{{File: "a.go", Start: xStart - 4, End: xStart + 6}}},
+			{Message: "rename x to v and y to w", Edits: []fixEdit{
+				{File: "a.go", Start: yStart, End: yStart + 1, NewText: "w"},
+				{File: "a.go", Start: xStart, End: xStart + 1, NewText: "v"},
+			}},
+		},
+		Selected: 1,
+	}
+	reports := []*nogoFixes{
+		{
+			Package:     "example.com/b",
+			Files:       map[string]string{"a.go": digest},
+			Diagnostics: []fixDiagnostic{conflicting},
+		},
+		{
+			Package: "example.com/a",
+			Files:   map[string]string{"a.go": digest, "bazel-out/gen.go": digest},
+			Diagnostics: []fixDiagnostic{
+				rename,
+				{
+					Analyzer: "rename",
+					Position: "bazel-out/gen.go:1:1",
+					Alternatives: []fixAlternative{
+						{Edits: []fixEdit{{File: "bazel-out/gen.go", NewText: "// generated\n"}}},
+					},
+				},
+				{
+					// The fix policy of the analyzer allows no fixes.
+					Analyzer:     "rename",
+					Position:     "a.go:1:1",
+					Alternatives: []fixAlternative{{Edits: []fixEdit{{File: "a.go", NewText: "//"}}}},
+					Selected:     -1,
+				},
+			},
+		},
+		{
+			// The internal test of the library reports the same fix.
+			Package:     "example.com/a_test",
+			Files:       map[string]string{"a.go": digest},
+			Diagnostics: []fixDiagnostic{rename},
+		},
+		{
+			Package: "example.com/stale",
+			Files:   map[string]string{"a.go": sha256Hex([]byte("package a\n"))},
+			Diagnostics: []fixDiagnostic{{
+				Analyzer:     "rename",
+				Alternatives: []fixAlternative{{Edits: []fixEdit{{File: "a.go", NewText: "//"}}}},
+			}},
+		},
+	}
+
+	plan := planFixes(root, reports, nil)
+	if plan.applied != 1 {
+		t.Errorf("got %d applied fixes, want 1", plan.applied)
+	}
+	if len(plan.skipped) != 3 {
+		t.Fatalf("got skipped fixes %q, want 3", plan.skipped)
+	}
+	for i, want := range []string{
+		"bazel-out/gen.go is not a source file of the workspace",
+		`overlapping suggestions from "other" and "rename"`,
+		"a.go changed after nogo ran",
+	} {
+		if !strings.Contains(plan.skipped[i], want) {
+			t.Errorf("got skipped fix %q, want it to contain %q", plan.skipped[i], want)
+		}
+	}
+	if err := plan.apply(root); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package a\n\nvar z = 1\n\nvar y = 2\n"; string(got) != want {
+		t.Errorf("got fixed file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Filtering by analyzer leaves the other fix without conflicts.
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte(src), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	plan = planFixes(root, reports, map[string]bool{"other": true})
+	if plan.applied != 1 || len(plan.skipped) != 0 {
+		t.Errorf("got %d applied and skipped fixes %q, want 1 and none", plan.applied, plan.skipped)
+	}
+	var patch bytes.Buffer
+	if err := plan.writePatch(&patch); err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a/a.go
++++ b/a.go
+@@ -1,5 +1,5 @@
+ package a
+ 
+-var x = 1
++var v = 1
+ 
+-var y = 2
++var w = 2
+`
+	if patch.String() != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", patch.String(), want)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, strings.Repeat("x", i)+"\n")
+	}
+	src := strings.Join(lines, "")
+	offset := func(line int) int {
+		return strings.Index(src, lines[line-1])
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		src   string
+		edits []nogoEdit
+		want  string
+	}{
+		{
+			desc: "separate hunks",
+			src:  src,
+			edits: []nogoEdit{
+				{Start: offset(2), End: offset(3), New: ""},
+				{Start: offset(15), End: offset(15), New: "new\n"},
+			},
+			want: `--- a/f.go
++++ b/f.go
+@@ -1,5 +1,4 @@
+ x
+-xx
+ xxx
+ xxxx
+ xxxxx
+@@ -12,6 +11,7 @@
+ xxxxxxxxxxxx
+ xxxxxxxxxxxxx
+ xxxxxxxxxxxxxx
++new
+ xxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxx
+`,
+		},
+		{
+			desc: "edits within a line",
+			src:  "a b c\nd\n",
+			edits: []nogoEdit{
+				{Start: 0, End: 1, New: "A"},
+				{Start: 4, End: 5, New: "C"},
+			},
+			want: `--- a/f.go
++++ b/f.go
+@@ -1,2 +1,2 @@
+-a b c
++A b C
+ d
+`,
+		},
+		{
+			desc:  "no newline at end of file",
+			src:   "a\nb",
+			edits: []nogoEdit{{Start: 3, End: 3, New: "\n"}},
+			want: `--- a/f.go
++++ b/f.go
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+		{
+			desc:  "append to file",
+			src:   "a\n",
+			edits: []nogoEdit{{Start: 2, End: 2, New: "b\n"}},
+			want: `--- a/f.go
++++ b/f.go
+@@ -1 +1,2 @@
+ a
++b
+`,
+		},
+		{
+			desc:  "no change",
+			src:   "a\n",
+			edits: []nogoEdit{{Start: 0, End: 1, New: "a"}},
+			want:  "",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := unifiedDiff("f.go", []byte(tt.src), tt.edits); got != tt.want {
+				t.Errorf("got diff:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -109,7 +110,7 @@ func TestGetFixes_SuccessCases(t *testing.T) {
 		},
 	}
 
-	fileChanges, err := getFixes(diagnosticEntries, fset)
+	fileChanges, _, err := getFixes(diagnosticEntries, fset)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -180,7 +181,7 @@ func TestGetFixes_Conflict(t *testing.T) {
 	detailedExpectedError := `because:
 	- overlapping suggestions from "analyzer2" and "analyzer1" at {Start:54,End:61,New:""} and {Start:54,End:62,New:""}`
 
-	fileChanges, err := getFixes(diagnosticEntries, fset)
+	fileChanges, selected, err := getFixes(diagnosticEntries, fset)
 	if err == nil || !strings.Contains(err.Error(), expectedError) || !strings.Contains(err.Error(), detailedExpectedError) {
 		t.Errorf("expected errors: %s or %s\ngot:%v+", expectedError, detailedExpectedError, err)
 	}
@@ -196,6 +197,99 @@ func TestGetFixes_Conflict(t *testing.T) {
 	if !reflect.DeepEqual(fileChanges, expectedChanges) {
 		t.Errorf("unexpected changes:\n\tgot:\t%v\n\twant:\t%v", fileChanges, expectedChanges)
 	}
+	if expectedSelected := []int{0, -1}; !reflect.DeepEqual(selected, expectedSelected) {
+		t.Errorf("unexpected selected fixes:\n\tgot:\t%v\n\twant:\t%v", selected, expectedSelected)
+	}
+}
+
+func TestGetFixes_Policy(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("file1.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(50)
+
+	alternatives := []analysis.SuggestedFix{
+		{Message: "first", TextEdits: []analysis.TextEdit{{Pos: token.Pos(5), End: token.Pos(6), NewText: []byte("a")}}},
+		{Message: "second", TextEdits: []analysis.TextEdit{{Pos: token.Pos(5), End: token.Pos(6), NewText: []byte("b")}}},
+	}
+	for _, tt := range []struct {
+		desc   string
+		policy fixPolicy
+		want   int
+	}{
+		{desc: "default", want: 0},
+		{desc: "first", policy: fixPolicy{mode: fixPolicyFirst}, want: 0},
+		{desc: "none", policy: fixPolicy{mode: fixPolicyNone}, want: -1},
+		{desc: "regex", policy: fixPolicy{mode: fixPolicyRegex, message: regexp.MustCompile("^sec")}, want: 1},
+		{desc: "no match", policy: fixPolicy{mode: fixPolicyRegex, message: regexp.MustCompile("third")}, want: -1},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			entries := []diagnosticEntry{{
+				analyzerName: "analyzer1",
+				Diagnostic:   analysis.Diagnostic{SuggestedFixes: alternatives},
+				fixPolicy:    tt.policy,
+			}}
+			fileChanges, selected, err := getFixes(entries, fset)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if selected[0] != tt.want {
+				t.Errorf("got selected fix %d, want %d", selected[0], tt.want)
+			}
+			if tt.want < 0 && len(fileChanges) != 0 {
+				t.Errorf("expected no file changes, got: %v", fileChanges)
+			}
+		})
+	}
+}
+
+func TestNewNogoFixes(t *testing.T) {
+	dir := t.TempDir()
+	src := "package a\n\nvar x = 1\n"
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte(src), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(path, fset.Base(), len(src))
+	f.SetLinesForContent([]byte(src))
+	pos := f.Pos(15)
+
+	entries := []diagnosticEntry{
+		{analyzerName: "nofix", Diagnostic: analysis.Diagnostic{Pos: pos, Message: "no fix"}},
+		{
+			analyzerName: "rename",
+			Diagnostic: analysis.Diagnostic{
+				Pos:     pos,
+				Message: "bad name",
+				SuggestedFixes: []analysis.SuggestedFix{
+					{Message: "rename to y", TextEdits: []analysis.TextEdit{{Pos: pos, End: pos + 1, NewText: []byte("y")}}},
+					{Message: "rename to z", TextEdits: []analysis.TextEdit{{Pos: pos, End: pos + 1, NewText: []byte("z")}}},
+				},
+			},
+		},
+	}
+	got, err := newNogoFixes("example.com/a", dir, entries, []int{-1, 1}, fset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &nogoFixes{
+		Package: "example.com/a",
+		Files:   map[string]string{"a.go": sha256Hex([]byte(src))},
+		Diagnostics: []fixDiagnostic{{
+			Analyzer: "rename",
+			Position: "a.go:3:5",
+			Message:  "bad name",
+			Alternatives: []fixAlternative{
+				{Message: "rename to y", Edits: []fixEdit{{File: "a.go", Start: 15, End: 16, NewText: "y"}}},
+				{Message: "rename to z", Edits: []fixEdit{{File: "a.go", Start: 15, End: 16, NewText: "z"}}},
+			},
+			Selected: 1,
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got fixes %+v, want %+v", got, want)
+	}
 }
 
 func TestGetFixes_NoFixes(t *testing.T) {
@@ -210,7 +304,7 @@ func TestGetFixes_NoFixes(t *testing.T) {
 		},
 	}
 
-	fileChanges, err := getFixes(diagnosticEntries, fset)
+	fileChanges, _, err := getFixes(diagnosticEntries, fset)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
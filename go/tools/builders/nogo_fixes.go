@@ -0,0 +1,184 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains the format of the fixes files nogo writes next to the
+// patch of each package, as well as the edits shared by nogo and the nogofix
+// command of the builder. Note that this file is shared between the nogo
+// binary and the builder.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// nogoFixes lists the suggested fixes of the diagnostics that nogo reported
+// for a package, including the alternatives it did not apply to the files in
+// its patch. Unlike the patch, it records which analyzer suggested each fix,
+// so that the fixes of several packages can be filtered and merged, and tools
+// can offer the alternatives to the user.
+type nogoFixes struct {
+	Package string `json:"package"`
+	// Files maps the name of each edited file to the hex-encoded SHA-256
+	// digest of the contents the offsets of the edits refer to.
+	Files       map[string]string `json:"files"`
+	Diagnostics []fixDiagnostic   `json:"diagnostics"`
+}
+
+// fixDiagnostic is a diagnostic with suggested fixes.
+type fixDiagnostic struct {
+	Analyzer string `json:"analyzer"`
+	Position string `json:"position"`
+	Message  string `json:"message"`
+	// Alternatives are the suggested fixes in the order the analyzer
+	// reported them. At most one of them should be applied.
+	Alternatives []fixAlternative `json:"alternatives"`
+	// Selected is the index of the alternative in the patch, or -1 if the fix
+	// policy of the analyzer allows none of them or they all conflict with
+	// other fixes.
+	Selected int `json:"selected"`
+}
+
+// fixAlternative is a suggested fix. Its edits must be applied together.
+type fixAlternative struct {
+	Message string    `json:"message"`
+	Edits   []fixEdit `json:"edits"`
+}
+
+// fixEdit replaces the bytes in [Start, End) of File with NewText.
+type fixEdit struct {
+	File    string `json:"file"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	NewText string `json:"new_text"`
+}
+
+func readFixesFile(path string) (*nogoFixes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixes nogoFixes
+	if err := json.Unmarshal(data, &fixes); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &fixes, nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(data), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// A nogoEdit describes the replacement of a portion of a text file.
+type nogoEdit struct {
+	New          string // the replacement
+	Start        int    // starting byte offset of the region to replace
+	End          int    // (exclusive) ending byte offset of the region to replace
+	analyzerName string
+}
+
+func (e nogoEdit) String() string {
+	return fmt.Sprintf("{Start:%d,End:%d,New:%q}", e.Start, e.End, e.New)
+}
+
+func (e nogoEdit) Equals(other nogoEdit) bool {
+	return e.Start == other.Start && e.End == other.End && e.New == other.New
+}
+
+// byStartEnd orders a slice of nogoEdits by (start, end) offset.
+// This ordering puts insertions (end = start) before deletions
+// (end > start) at the same point. We will use a stable sort to preserve
+// the order of multiple insertions at the same point.
+type byStartEnd []nogoEdit
+
+func (a byStartEnd) Len() int { return len(a) }
+func (a byStartEnd) Less(i, j int) bool {
+	if a[i].Start != a[j].Start {
+		return a[i].Start < a[j].Start
+	}
+	return a[i].End < a[j].End
+}
+func (a byStartEnd) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// applyEdits applies a sequence of nogoEdits to the src byte slice and returns the result.
+// Edits are applied in order of start offset; edits with the same start offset are applied in the order they were provided.
+// The function assumes that edits are unique, sorted and non-overlapping.
+// This is guaranteed by invoking validate() earlier.
+func applyEdits(src []byte, edits []nogoEdit) []byte {
+	size := len(src)
+	// performance only: this computes the size for preallocation to avoid the slice resizing below.
+	for _, edit := range edits {
+		size += len(edit.New) + edit.Start - edit.End
+	}
+
+	out := make([]byte, 0, size)
+	lastEnd := 0
+	for _, edit := range edits {
+		out = append(out, src[lastEnd:edit.Start]...)
+		out = append(out, edit.New...)
+		lastEnd = edit.End
+	}
+	out = append(out, src[lastEnd:]...)
+
+	return out
+}
+
+// validate whether the list of edits has overlaps or contains invalid ones.
+// If there is any issue, an error is returned. Otherwise, the function
+// returns a new list of edits that is sorted and unique.
+func validate(edits []nogoEdit) ([]nogoEdit, error) {
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	validatedEdits := make([]nogoEdit, len(edits))
+	// avoid modifying the original slice for safety.
+	copy(validatedEdits, edits)
+	sort.Stable(byStartEnd(validatedEdits))
+	tail := 0
+	for i, cur := range validatedEdits {
+		if cur.Start > cur.End {
+			return nil, fmt.Errorf("invalid suggestion from %q: %s", cur.analyzerName, cur)
+		}
+		if i > 0 {
+			prev := validatedEdits[i-1]
+			if prev.Equals(cur) {
+				// equivalent ones are safely skipped
+				continue
+			}
+
+			if prev.End > cur.Start {
+				return nil, fmt.Errorf("overlapping suggestions from %q and %q at %s and %s",
+					prev.analyzerName, cur.analyzerName, prev, cur)
+			}
+		}
+		validatedEdits[tail] = cur
+		tail++
+	}
+	return validatedEdits[:tail], nil
+}
@@ -35,9 +35,12 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime"
+	"runtime/metrics"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/gcexportdata"
@@ -57,22 +60,37 @@ var typesSizes = types.SizesFor("gc", os.Getenv("GOARCH"))
 func main() {
 	log.SetFlags(0) // no timestamp
 	log.SetPrefix("nogo: ")
-	if err, exitCode := run(os.Args[1:]); err != nil {
+
+	args := os.Args[1:]
+	protocol := workerProtocolProto
+	if len(args) > 0 && strings.HasPrefix(args[0], workerProtocolFlag) {
+		protocol, args = strings.TrimPrefix(args[0], workerProtocolFlag), args[1:]
+	}
+	if startupArgs, ok := persistentWorkerArgs(args); ok {
+		if err := runNogoWorker(os.Stdin, os.Stdout, startupArgs, protocol); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err, exitCode := run(args, nil); err != nil {
 		log.Print(err)
 		os.Exit(exitCode)
 	}
 }
 
 // run returns an error if there is a problem loading the package or if any
-// analysis fails.
-func run(args []string) (error, int) {
+// analysis fails. If cache is not nil, the type information and facts of
+// imported packages are read through it.
+func run(args []string, cache *exportDataCache) (error, int) {
+	start := time.Now()
 	args, _, err := expandParamsFiles(args)
 	if err != nil {
 		return fmt.Errorf("error reading paramfiles: %v", err), nogoError
 	}
 
 	factMap := factMultiFlag{}
-	flags := flag.NewFlagSet("nogo", flag.ExitOnError)
+	flags := flag.NewFlagSet("nogo", flag.ContinueOnError)
 	flags.Var(&factMap, "fact", "Import path and file containing facts for that library, separated by '=' (may be repeated)'")
 	factsOnly := flags.Bool("facts_only", false, "If true, only facts are emitted, no analyzers are run")
 	importcfg := flags.String("importcfg", "", "The import configuration file")
@@ -82,7 +100,9 @@ func run(args []string) (error, int) {
 	nogoFixDir := flags.String("fix_dir", "", "The path of the directory to store the nogo fixes in")
 	var ignores multiFlag
 	flags.Var(&ignores, "ignore", "Names of files to ignore")
-	flags.Parse(args)
+	if err := flags.Parse(args); err != nil {
+		return err, nogoError
+	}
 	srcs := flags.Args()
 
 	packageFile, importMap, err := readImportCfg(*importcfg)
@@ -92,7 +112,29 @@ func run(args []string) (error, int) {
 
 	normalizedGoVersion := normalizeGoVersion(*goVersion)
 
-	diagnostics, pkg, err := checkPackage(analyzers, *packagePath, normalizedGoVersion, packageFile, importMap, factMap, *factsOnly, srcs, ignores)
+	profile := &nogoProfile{Package: *packagePath, FactsOnly: *factsOnly}
+	// profileMode is defined by the template in generate_nogo_main.go.
+	var stopHeapSampler func() uint64
+	if profileMode {
+		stopHeapSampler = sampleHeap()
+		defer stopHeapSampler()
+	}
+
+	imp := newImporter(importMap, packageFile, factMap)
+	if cache != nil {
+		imp.useCache(cache)
+		defer imp.releaseCache()
+	}
+	diagnostics, suppressed, pkg, err := checkPackage(analyzers, *packagePath, normalizedGoVersion, imp, *factsOnly, srcs, ignores, profile)
+	if imp.conflict || imp.hiddenFacts {
+		// The cached type information does not match the archives of the
+		// imported packages, or it makes facts visible that a new process
+		// would discard, so they are imported again without the cache.
+		*profile = nogoProfile{Package: *packagePath, FactsOnly: *factsOnly}
+		imp = newImporter(importMap, packageFile, factMap)
+		imp.cache = cache
+		diagnostics, suppressed, pkg, err = checkPackage(analyzers, *packagePath, normalizedGoVersion, imp, *factsOnly, srcs, ignores, profile)
+	}
 	if err != nil {
 		return fmt.Errorf("error running analyzers: %v", err), nogoError
 	}
@@ -101,7 +143,9 @@ func run(args []string) (error, int) {
 	if *xPath != "" {
 		var factsContent []byte
 		if pkg != nil {
+			encodeStart := time.Now()
 			factsContent = pkg.facts.Encode()
+			profile.FactsEncode = time.Since(encodeStart)
 		}
 
 		if err := os.WriteFile(abs(*xPath), factsContent, 0o666); err != nil {
@@ -116,9 +160,21 @@ func run(args []string) (error, int) {
 		return fmt.Errorf("pkg should not be nil with diagnostics"), nogoError
 	}
 
+	var errorDiagnostics, warningDiagnostics []diagnosticEntry
+	for _, d := range diagnostics {
+		switch d.severity {
+		case severityWarning:
+			warningDiagnostics = append(warningDiagnostics, d)
+		case severityInfo:
+			// Only recorded in the reports.
+		default:
+			errorDiagnostics = append(errorDiagnostics, d)
+		}
+	}
+
 	exitCode := nogoSuccess
 	var errMsg bytes.Buffer
-	if len(diagnostics) > 0 {
+	if len(errorDiagnostics) > 0 {
 		// debugMode is defined by the template in generate_nogo_main.go.
 		exitCode = nogoViolation
 		if debugMode {
@@ -126,12 +182,30 @@ func run(args []string) (error, int) {
 			exitCode = nogoError
 		}
 		errMsg.WriteString("errors found by nogo during build-time code analysis:")
-		for _, d := range diagnostics {
+		for _, d := range errorDiagnostics {
 			fmt.Fprintf(&errMsg, "\n%s: %s (%s)", fset.Position(d.Pos), d.Message, d.analyzerName)
 		}
 	}
 
-	if errs := saveSuggestedFixes(*nogoFixDir, diagnostics, fset); len(errs) > 0 {
+	if profileMode {
+		profile.Total = time.Since(start)
+		profile.PeakHeapBytes = stopHeapSampler()
+		if err := saveProfile(*nogoFixDir, profile); err != nil {
+			fmt.Fprintf(&errMsg, "\nsaving profile:\n%v", err)
+		}
+	}
+
+	if err := saveWarnings(*nogoFixDir, warningDiagnostics, fset); err != nil {
+		fmt.Fprintf(&errMsg, "\nsaving warnings:\n%v", err)
+	}
+
+	if !*factsOnly {
+		if err := saveReports(*nogoFixDir, *packagePath, diagnostics, suppressed, fset); err != nil {
+			fmt.Fprintf(&errMsg, "\nsaving diagnostics reports:\n%v", err)
+		}
+	}
+
+	if errs := saveSuggestedFixes(*nogoFixDir, *packagePath, diagnostics, fset); len(errs) > 0 {
 		errMsg.WriteString("\nsaving suggested fixes:")
 		for _, err := range errs {
 			fmt.Fprintf(&errMsg, "\n%v", err)
@@ -144,15 +218,28 @@ func run(args []string) (error, int) {
 	return nil, exitCode
 }
 
-func saveSuggestedFixes(nogoFixDir string, diagnostics []diagnosticEntry, fset *token.FileSet) []error {
+func saveSuggestedFixes(nogoFixDir, packagePath string, diagnostics []diagnosticEntry, fset *token.FileSet) []error {
 	if nogoFixDir == "" {
 		return nil
 	}
 	var errs []error
-	fixes, err := getFixes(diagnostics, fset)
+	fixes, selected, err := getFixes(diagnostics, fset)
 	if err != nil {
 		errs = append(errs, err)
 	}
+
+	// The fixes file lists the alternatives even if the fix policies allow
+	// none of them.
+	cwd, _ := os.Getwd()
+	fixesFile, err := newNogoFixes(packagePath, cwd, diagnostics, selected, fset)
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(fixesFile.Diagnostics) > 0 {
+		if err := writeJSONFile(filepath.Join(nogoFixDir, nogoFixesBasename), fixesFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	if len(fixes) == 0 {
 		return errs
 	}
@@ -169,6 +256,103 @@ func saveSuggestedFixes(nogoFixDir string, diagnostics []diagnosticEntry, fset *
 	return errs
 }
 
+// saveWarnings writes the diagnostics with severityWarning to a log file in
+// nogoFixDir, which is printed by the validation action without failing it.
+func saveWarnings(nogoFixDir string, warnings []diagnosticEntry, fset *token.FileSet) error {
+	if nogoFixDir == "" || len(warnings) == 0 {
+		return nil
+	}
+	var msg bytes.Buffer
+	msg.WriteString("warnings found by nogo during build-time code analysis:")
+	for _, d := range warnings {
+		fmt.Fprintf(&msg, "\n%s: %s (%s)", fset.Position(d.Pos), d.Message, d.analyzerName)
+	}
+	msg.WriteString("\n")
+	path := filepath.Join(nogoFixDir, nogoWarningsBasename)
+	if err := os.WriteFile(path, relativizePaths(msg.Bytes()), 0o666); err != nil {
+		return fmt.Errorf("writing %q: %w", path, err)
+	}
+	return nil
+}
+
+// saveProfile writes the profile of the nogo run to nogoFixDir.
+func saveProfile(nogoFixDir string, profile *nogoProfile) error {
+	if nogoFixDir == "" {
+		return nil
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	profile.TotalAllocBytes = memStats.TotalAlloc
+	if memStats.HeapAlloc > profile.PeakHeapBytes {
+		profile.PeakHeapBytes = memStats.HeapAlloc
+	}
+	profile.sortAnalyzers()
+	return writeJSONFile(filepath.Join(nogoFixDir, nogoProfileBasename), profile)
+}
+
+// heapSampleInterval is the interval at which the heap size is sampled to
+// determine its peak.
+const heapSampleInterval = 10 * time.Millisecond
+
+// sampleHeap periodically samples the size of the heap until the returned
+// function is called, which returns the largest observed size. The returned
+// function may be called multiple times.
+func sampleHeap() func() uint64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	var peak uint64
+	sample := func() {
+		metrics.Read(samples)
+		if samples[0].Value.Kind() == metrics.KindUint64 {
+			if v := samples[0].Value.Uint64(); v > peak {
+				peak = v
+			}
+		}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		for {
+			sample()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() uint64 {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			sample()
+		})
+		return peak
+	}
+}
+
+// saveReports writes the JSON and SARIF reports describing all diagnostics,
+// including suppressed ones, to nogoFixDir.
+func saveReports(nogoFixDir, packagePath string, diagnostics, suppressed []diagnosticEntry, fset *token.FileSet) error {
+	if nogoFixDir == "" {
+		return nil
+	}
+	analyzerDocs := make(map[string]string)
+	for _, a := range analyzers {
+		analyzerDocs[a.Name] = a.Doc
+	}
+	analyzerDocs[nolintAnalyzer.Name] = nolintAnalyzer.Doc
+	cwd, _ := os.Getwd()
+	report := newNogoReport(packagePath, cwd, diagnostics, suppressed, fset)
+	if err := writeJSONFile(filepath.Join(nogoFixDir, nogoJSONBasename), report); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(nogoFixDir, nogoSARIFBasename), report.sarif(analyzerDocs, os.ReadFile))
+}
+
 // Adapted from go/src/cmd/compile/internal/gc/main.go. Keep in sync.
 func readImportCfg(file string) (packageFile map[string]string, importMap map[string]string, err error) {
 	packageFile, importMap = make(map[string]string), make(map[string]string)
@@ -227,12 +411,41 @@ func setAnalyzerFlags(a *analysis.Analyzer, flags map[string]string) error {
 	return nil
 }
 
+var (
+	analyzerFlagsOnce sync.Once
+	analyzerFlagsErr  error
+)
+
+// setAllAnalyzerFlags sets the flags of the analyzers from the configuration.
+// Analyzers are shared by all the packages analyzed by a worker, so this is
+// only done once.
+func setAllAnalyzerFlags(analyzers []*analysis.Analyzer) error {
+	// We populate flags for analyzers and their subanalyzers to depth of one. Some analyzers require to provide
+	// flags to their dependencies e.g. nilaway has specific nilaway_config subanalyzer.
+	for _, a := range analyzers {
+		if cfg, ok := configs[a.Name]; ok {
+			if err := setAnalyzerFlags(a, cfg.analyzerFlags); err != nil {
+				return err
+			}
+		}
+		for _, ra := range a.Requires {
+			if cfg, ok := configs[ra.Name]; ok {
+				if err := setAnalyzerFlags(ra, cfg.analyzerFlags); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // checkPackage runs all the given analyzers on the specified package and
-// returns the source code diagnostics that the must be printed in the build log.
-// It returns an empty string if no source code diagnostics need to be printed.
+// returns the source code diagnostics that the must be printed in the build log
+// as well as the diagnostics that were suppressed by nolint directives or the
+// nogo configuration.
 //
 // This implementation was adapted from that of golang.org/x/tools/go/checker/internal/checker.
-func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string, packageFile, importMap, factMap map[string]string, factsOnly bool, filenames, ignoreFiles []string) ([]diagnosticEntry, *goPackage, error) {
+func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string, imp *importer, factsOnly bool, filenames, ignoreFiles []string, profile *nogoProfile) ([]diagnosticEntry, []diagnosticEntry, *goPackage, error) {
 	// Register fact types and establish dependencies between analyzers.
 	actions := make(map[*analysis.Analyzer]*action)
 	var visit func(a *analysis.Analyzer) *action
@@ -257,21 +470,9 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string,
 		return act
 	}
 
-	// We populate flags for analyzers and their subanalyzers to depth of one. Some analyzers require to provide
-	// flags to their dependencies e.g. nilaway has specific nilaway_config subanalyzer.
-	for _, a := range analyzers {
-		if cfg, ok := configs[a.Name]; ok {
-			if err := setAnalyzerFlags(a, cfg.analyzerFlags); err != nil {
-				return nil, nil, err
-			}
-		}
-		for _, ra := range a.Requires {
-			if cfg, ok := configs[ra.Name]; ok {
-				if err := setAnalyzerFlags(ra, cfg.analyzerFlags); err != nil {
-					return nil, nil, err
-				}
-			}
-		}
+	analyzerFlagsOnce.Do(func() { analyzerFlagsErr = setAllAnalyzerFlags(analyzers) })
+	if analyzerFlagsErr != nil {
+		return nil, nil, nil, analyzerFlagsErr
 	}
 
 	// In facts-only mode diagnostics are discarded, so we only need to run
@@ -292,20 +493,24 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string,
 	}
 	if len(roots) == 0 {
 		// No analyzers to run, return early.
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 
 	// Load the package, including AST, types, and facts.
-	imp := newImporter(importMap, packageFile, factMap)
-	pkg, err := load(packagePath, goVersion, imp, filenames)
+	pkg, err := load(packagePath, goVersion, imp, filenames, profile)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error loading package: %v", err)
+		return nil, nil, nil, fmt.Errorf("error loading package: %v", err)
+	}
+	if imp.conflict || imp.hiddenFacts {
+		// The package is analyzed again without the cache, see run.
+		return nil, nil, nil, nil
 	}
 
 	for _, act := range actions {
 		act.pkg = pkg
 	}
 
+	var directives []*nolintDirective
 	ignoreFilesSet := map[string]struct{}{}
 	for _, ignore := range ignoreFiles {
 		ignoreFilesSet[ignore] = struct{}{}
@@ -327,16 +532,24 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string,
 		// assignment and will apply the comment to the entire assignment.
 		commentMap := ast.NewCommentMap(pkg.fset, f, f.Comments)
 		for node, groups := range commentMap {
-			rng := &Range{
-				from: pkg.fset.Position(node.Pos()),
-				to:   pkg.fset.Position(node.End()).Line,
-			}
 			for _, group := range groups {
 				for _, comm := range group.List {
 					linters, ok := parseNolint(comm.Text)
 					if !ok {
 						continue
 					}
+					directive := &nolintDirective{
+						pos:     comm.Pos(),
+						text:    comm.Text,
+						linters: linters,
+						used:    make(map[string]bool),
+					}
+					directives = append(directives, directive)
+					rng := &Range{
+						from:      pkg.fset.Position(node.Pos()),
+						to:        pkg.fset.Position(node.End()).Line,
+						directive: directive,
+					}
 					for analyzer, act := range actions {
 						if linters == nil || linters[analyzer.Name] {
 							act.nolint = append(act.nolint, rng)
@@ -349,14 +562,117 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath, goVersion string,
 
 	// Execute the analyzers.
 	execAll(roots)
+	for a, act := range actions {
+		profile.Analyzers = append(profile.Analyzers, analyzerProfile{Name: a.Name, WallTime: act.duration})
+	}
+
+	// strictNolint and requireNolintJustification are defined by the template
+	// in generate_nogo_main.go.
+	if (strictNolint || requireNolintJustification) && !factsOnly {
+		roots = append(roots, checkNolint(analyzers, roots, actions, directives, pkg))
+	}
+
+	diagnostics, suppressed, err := checkAnalysisResults(roots, pkg)
+	diagnostics, suppressed = applyBaseline(baseline, diagnostics, suppressed, pkg.fset)
+	return diagnostics, suppressed, pkg, err
+}
+
+// nolintAnalyzer is the pseudo-analyzer under which problems with nolint
+// directives are reported. Its findings can be configured like those of any
+// other analyzer.
+var nolintAnalyzer = &analysis.Analyzer{
+	Name:             "nolint",
+	Doc:              "check that nolint directives are known, used and justified\n\nThis check is enabled by the strict_nolint and require_nolint_justification attributes of the nogo rule.",
+	RunDespiteErrors: true,
+}
+
+// checkNolint returns an already executed action for nolintAnalyzer whose
+// diagnostics are the problems with the nolint directives of the package.
+func checkNolint(analyzers []*analysis.Analyzer, roots []*action, actions map[*analysis.Analyzer]*action, directives []*nolintDirective, pkg *goPackage) *action {
+	known := make(map[string]bool)
+	for _, a := range analyzers {
+		known[a.Name] = true
+	}
+	for a, act := range actions {
+		for d := range act.usedNolint {
+			d.used[a.Name] = true
+		}
+	}
+	// A directive may only appear unused because an analyzer didn't run.
+	checkUnused := strictNolint
+	for _, act := range roots {
+		if act.err != nil || (pkg.illTyped && !act.a.RunDespiteErrors) {
+			checkUnused = false
+		}
+	}
+	if !strictNolint {
+		// Only check justifications.
+		known = nil
+	}
 
-	diagnostics, err := checkAnalysisResults(roots, pkg)
-	return diagnostics, pkg, err
+	act := &action{a: nolintAnalyzer, pkg: pkg}
+	for _, issue := range checkNolintDirectives(directives, known, checkUnused, requireNolintJustification) {
+		act.diagnostics = append(act.diagnostics, analysis.Diagnostic{Pos: issue.pos, Message: issue.message})
+	}
+	return act
+}
+
+// applyBaseline computes the fingerprints of all diagnostics and moves the
+// ones that match an entry in the baseline to the suppressed diagnostics.
+func applyBaseline(entries []baselineEntry, diagnostics, suppressed []diagnosticEntry, fset *token.FileSet) ([]diagnosticEntry, []diagnosticEntry) {
+	cwd, _ := os.Getwd()
+	fileLines := make(map[string][]string)
+	fingerprint := func(entry *diagnosticEntry) string {
+		p := fset.Position(entry.Pos)
+		var line string
+		if p.IsValid() {
+			lines, ok := fileLines[p.Filename]
+			if !ok {
+				if content, err := os.ReadFile(p.Filename); err == nil {
+					lines = strings.Split(string(content), "\n")
+				}
+				fileLines[p.Filename] = lines
+			}
+			if p.Line <= len(lines) {
+				line = lines[p.Line-1]
+			}
+		}
+		entry.fingerprint = baselineFingerprint(entry.Message, line)
+		filename := p.Filename
+		if cwd != "" {
+			if relname, err := filepath.Rel(cwd, filename); err == nil {
+				filename = relname
+			}
+		}
+		return filepath.ToSlash(filename)
+	}
+
+	for i := range suppressed {
+		fingerprint(&suppressed[i])
+	}
+	matcher := newBaselineMatcher(entries)
+	var reported []diagnosticEntry
+	for _, d := range diagnostics {
+		filename := fingerprint(&d)
+		if matcher.match(d.analyzerName, filename, d.fingerprint) {
+			d.suppressedBy = suppressedByBaseline
+			suppressed = append(suppressed, d)
+		} else {
+			reported = append(reported, d)
+		}
+	}
+	sort.Slice(suppressed, func(i, j int) bool {
+		return suppressed[i].Pos < suppressed[j].Pos
+	})
+	return reported, suppressed
 }
 
 type Range struct {
 	from token.Position
 	to   int
+	// directive is the nolint directive this range originates from. It is nil
+	// for ranges covering files whose diagnostics are ignored entirely.
+	directive *nolintDirective
 }
 
 // factProducers returns the set of analyzers that declare facts among the
@@ -396,9 +712,21 @@ type action struct {
 	inputs      map[*analysis.Analyzer]interface{}
 	result      interface{}
 	diagnostics []analysis.Diagnostic
-	usesFacts   bool
-	err         error
-	nolint      []*Range
+	// nolintDiagnostics are the diagnostics suppressed by nolint directives.
+	nolintDiagnostics []nolintDiagnostic
+	// duration is the wall time spent in the analyzer's Run function.
+	duration time.Duration
+	// usedNolint are the nolint directives that suppressed diagnostics.
+	usedNolint map[*nolintDirective]bool
+	usesFacts  bool
+	err        error
+	nolint     []*Range
+}
+
+// nolintDiagnostic is a diagnostic suppressed by a nolint directive.
+type nolintDiagnostic struct {
+	analysis.Diagnostic
+	directive *nolintDirective
 }
 
 func (act *action) String() string {
@@ -457,7 +785,15 @@ func (act *action) execOnce() {
 			if pos.Line < rng.from.Line || pos.Line > rng.to {
 				continue
 			}
-			// Found a nolint range. Ignore the issue.
+			// Found a nolint range. Ignore the issue, but keep track of it for
+			// the diagnostics reports unless the whole file is ignored.
+			if rng.directive != nil {
+				act.nolintDiagnostics = append(act.nolintDiagnostics, nolintDiagnostic{d, rng.directive})
+				if act.usedNolint == nil {
+					act.usedNolint = make(map[*nolintDirective]bool)
+				}
+				act.usedNolint[rng.directive] = true
+			}
 			return
 		}
 		act.diagnostics = append(act.diagnostics, d)
@@ -494,7 +830,9 @@ func (act *action) execOnce() {
 		}
 	}()
 	if !act.pkg.illTyped || pass.Analyzer.RunDespiteErrors {
+		runStart := time.Now()
 		act.result, err = pass.Analyzer.Run(pass)
+		act.duration = time.Since(runStart)
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -507,11 +845,13 @@ func (act *action) execOnce() {
 }
 
 // load parses and type checks the source code in each file in filenames.
-// load also deserializes facts stored for imported packages.
-func load(packagePath, goVersion string, imp *importer, filenames []string) (*goPackage, error) {
+// load also deserializes facts stored for imported packages. The time spent
+// in each step is recorded in profile.
+func load(packagePath, goVersion string, imp *importer, filenames []string, profile *nogoProfile) (*goPackage, error) {
 	if len(filenames) == 0 {
 		return nil, errors.New("no filenames")
 	}
+	start := time.Now()
 	var syntax []*ast.File
 	for _, file := range filenames {
 		s, err := parser.ParseFile(imp.fset, file, nil, parser.ParseComments)
@@ -519,8 +859,10 @@ func load(packagePath, goVersion string, imp *importer, filenames []string) (*go
 			return nil, err
 		}
 		syntax = append(syntax, s)
+		imp.parsed = append(imp.parsed, imp.fset.File(s.Pos()))
 	}
 	pkg := &goPackage{fset: imp.fset, syntax: syntax}
+	profile.Parse = time.Since(start)
 
 	config := types.Config{Importer: imp}
 	initGoVersionConfig(&config, goVersion)
@@ -536,16 +878,23 @@ func load(packagePath, goVersion string, imp *importer, filenames []string) (*go
 	initFileVersions(info)
 	initInstanceInfo(info)
 
+	start = time.Now()
 	types, err := config.Check(packagePath, pkg.fset, syntax, info)
 	if err != nil {
 		pkg.illTyped, pkg.typeCheckError = true, err
 	}
 	pkg.types, pkg.typesInfo = types, info
+	profile.TypeCheck = time.Since(start)
 
+	start = time.Now()
 	pkg.facts, err = facts.NewDecoder(pkg.types).Decode(imp.readFacts)
+	profile.FactsDecode = time.Since(start)
 	if err != nil {
 		return nil, fmt.Errorf("internal error decoding facts: %v", err)
 	}
+	if imp.cache != nil {
+		imp.checkFacts(pkg.facts)
+	}
 
 	return pkg, nil
 }
@@ -579,10 +928,10 @@ func (g *goPackage) String() string {
 }
 
 // checkAnalysisResults checks the analysis diagnostics in the given actions
-// and returns a string containing all the diagnostics that should be printed
-// to the build log.
-func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry, error) {
-	var diagnostics []diagnosticEntry
+// and returns all the diagnostics that should be printed to the build log as
+// well as the diagnostics that were suppressed.
+func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry, []diagnosticEntry, error) {
+	var diagnostics, suppressed []diagnosticEntry
 	var errs []error
 	cwd, err := os.Getwd()
 	if cwd == "" || err != nil {
@@ -600,9 +949,6 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 			errs = append(errs, fmt.Errorf("analyzer %q failed: %v", act.a.Name, act.err))
 			continue
 		}
-		if len(act.diagnostics) == 0 {
-			continue
-		}
 		var currentConfig config
 		// Use the base config if it exists.
 		if baseConfig, ok := configs[nogoBaseConfigName]; ok {
@@ -620,19 +966,21 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 			if actionConfig.excludeFiles != nil {
 				currentConfig.excludeFiles = actionConfig.excludeFiles
 			}
-		}
-
-		if currentConfig.onlyFiles == nil && currentConfig.excludeFiles == nil {
-			for _, diag := range act.diagnostics {
-				diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: diag, analyzerName: act.a.Name})
+			if actionConfig.severity != "" {
+				currentConfig.severity = actionConfig.severity
+			}
+			if actionConfig.severityOverrides != nil {
+				currentConfig.severityOverrides = actionConfig.severityOverrides
+			}
+			if actionConfig.fixPolicy.mode != "" {
+				currentConfig.fixPolicy = actionConfig.fixPolicy
 			}
-			continue
 		}
-		// Discard diagnostics based on the analyzer configuration.
-		for _, d := range act.diagnostics {
+
+		relativeFilename := func(pos token.Pos) string {
 			// NOTE(golang.org/issue/31008): nilness does not set positions,
 			// so don't assume the position is valid.
-			p := pkg.fset.Position(d.Pos)
+			p := pkg.fset.Position(pos)
 			filename := "-"
 			if p.IsValid() {
 				filename = p.Filename
@@ -642,10 +990,29 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 					filename = relname
 				}
 			}
+			return filename
+		}
+
+		for _, d := range act.nolintDiagnostics {
+			severity := currentConfig.severityFor(relativeFilename(d.Pos))
+			suppressed = append(suppressed, diagnosticEntry{
+				Diagnostic:    d.Diagnostic,
+				analyzerName:  act.a.Name,
+				severity:      severity,
+				suppressedBy:  suppressedByNolint,
+				justification: nolintJustification(d.directive.text),
+			})
+		}
+		// Discard diagnostics based on the analyzer configuration.
+		for _, d := range act.diagnostics {
+			filename := relativeFilename(d.Pos)
+			severity := currentConfig.severityFor(filename)
 			include := true
+			suppressedBy := ""
 			if len(currentConfig.onlyFiles) > 0 {
 				// This analyzer emits diagnostics for only a set of files.
 				include = false
+				suppressedBy = suppressedByOnlyFiles
 				for _, pattern := range currentConfig.onlyFiles {
 					if pattern.MatchString(filename) {
 						include = true
@@ -657,12 +1024,15 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 				for _, pattern := range currentConfig.excludeFiles {
 					if pattern.MatchString(filename) {
 						include = false
+						suppressedBy = suppressedByExcludeFiles
 						break
 					}
 				}
 			}
 			if include {
-				diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: d, analyzerName: act.a.Name})
+				diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: d, analyzerName: act.a.Name, severity: severity, fixPolicy: currentConfig.fixPolicy})
+			} else {
+				suppressed = append(suppressed, diagnosticEntry{Diagnostic: d, analyzerName: act.a.Name, severity: severity, suppressedBy: suppressedBy})
 			}
 		}
 	}
@@ -672,9 +1042,12 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 	sort.Slice(diagnostics, func(i, j int) bool {
 		return diagnostics[i].Pos < diagnostics[j].Pos
 	})
+	sort.Slice(suppressed, func(i, j int) bool {
+		return suppressed[i].Pos < suppressed[j].Pos
+	})
 
 	if len(errs) == 0 {
-		return diagnostics, nil
+		return diagnostics, suppressed, nil
 	}
 
 	errMsg := &bytes.Buffer{}
@@ -684,7 +1057,7 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 		sep = "\n"
 		errMsg.WriteString(err.Error())
 	}
-	return diagnostics, errors.New(errMsg.String())
+	return diagnostics, suppressed, errors.New(errMsg.String())
 }
 
 // config determines which source files an analyzer will emit diagnostics for.
@@ -704,6 +1077,55 @@ type config struct {
 	// to Analyzer.Flags. Note that no leading '-' should be present in a flag
 	// name
 	analyzerFlags map[string]string
+
+	// severity is the severity of the diagnostics an analyzer emits. Only
+	// diagnostics with severityError fail the build. When empty, the severity
+	// is severityError.
+	severity string
+
+	// severityOverrides changes the severity of diagnostics in files matching
+	// a regular expression.
+	severityOverrides []severityOverride
+
+	// fixPolicy selects which of the alternative suggested fixes of a
+	// diagnostic is applied.
+	fixPolicy fixPolicy
+}
+
+type severityOverride struct {
+	pattern  *regexp.Regexp
+	severity string
+}
+
+// severityFor returns the severity of diagnostics in the given file. If
+// several overrides match the file, the most severe one applies.
+func (c config) severityFor(filename string) string {
+	severity := c.severity
+	if severity == "" {
+		severity = severityError
+	}
+	overridden := false
+	for _, o := range c.severityOverrides {
+		if !o.pattern.MatchString(filename) {
+			continue
+		}
+		if !overridden || severityRank(o.severity) > severityRank(severity) {
+			severity = o.severity
+		}
+		overridden = true
+	}
+	return severity
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case severityInfo:
+		return 0
+	case severityWarning:
+		return 1
+	default:
+		return 2
+	}
 }
 
 // importer is an implementation of go/types.Importer that imports type
@@ -714,6 +1136,16 @@ type importer struct {
 	packageCache map[string]*types.Package // cache of previously imported packages
 	packageFile  map[string]string         // map package path to .a file with export data
 	factMap      map[string]string         // map import path in source code to file containing serialized facts
+
+	// The fields below are only set in a persistent worker, see nogo_worker.go.
+	cache    *exportDataCache
+	shared   bool            // whether fset and packageCache are those of cache
+	imported map[string]bool // packages imported by the type checker
+	parsed   []*token.File   // files of the analyzed package
+	conflict bool            // whether cache was found to be inconsistent
+	// hiddenFacts is whether facts were decoded about objects that are only
+	// known because the type information of cache is shared.
+	hiddenFacts bool
 }
 
 func newImporter(importMap, packageFile map[string]string, factMap map[string]string) *importer {
@@ -736,7 +1168,7 @@ func (i *importer) Import(path string) (*types.Package, error) {
 		// See https://github.com/golang/go/issues/13882.
 		return types.Unsafe, nil
 	}
-	if pkg, ok := i.packageCache[path]; ok && pkg.Complete() {
+	if pkg, ok := i.packageCache[path]; ok && pkg.Complete() && !i.shared {
 		return pkg, nil // cache hit
 	}
 
@@ -744,6 +1176,9 @@ func (i *importer) Import(path string) (*types.Package, error) {
 	if !ok {
 		return nil, fmt.Errorf("could not import %q", path)
 	}
+	if i.shared {
+		return i.importShared(path, archive)
+	}
 	// open file
 	f, err := os.Open(archive)
 	if err != nil {
@@ -777,6 +1212,9 @@ func (i *importer) readFacts(pkgPath string) ([]byte, error) {
 		// fmt.Printf accepts a format string.
 		return nil, nil
 	}
+	if i.cache != nil {
+		return i.readCachedFacts(facts)
+	}
 	return os.ReadFile(facts)
 }
 
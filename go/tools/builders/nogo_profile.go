@@ -0,0 +1,82 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains the format of the profiles nogo writes for each package
+// when profiling is enabled. Note that this file is shared between the nogo
+// binary and the builder.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// nogoProfile records where nogo spent time and memory while analyzing a
+// package. Durations are serialized in nanoseconds.
+type nogoProfile struct {
+	Package string `json:"package"`
+	// FactsOnly is set if only analyzers producing facts were run.
+	FactsOnly bool `json:"facts_only,omitempty"`
+	// Total is the wall time of the whole nogo run.
+	Total time.Duration `json:"total_ns"`
+	// Parse is the time spent parsing the source files.
+	Parse time.Duration `json:"parse_ns"`
+	// TypeCheck is the time spent type checking the package, including
+	// reading the export data of its dependencies.
+	TypeCheck time.Duration `json:"type_check_ns"`
+	// FactsDecode and FactsEncode are the time spent reading the facts of
+	// dependencies and writing the facts of the package.
+	FactsDecode time.Duration `json:"facts_decode_ns"`
+	FactsEncode time.Duration `json:"facts_encode_ns"`
+	// Analyzers are the wall times of the analyzers, excluding the time spent
+	// in the analyzers they require. Analyzers run in parallel, so their sum
+	// may exceed Total.
+	Analyzers []analyzerProfile `json:"analyzers"`
+	// PeakHeapBytes is the largest heap size observed during the run and
+	// TotalAllocBytes the cumulative size of all heap allocations.
+	PeakHeapBytes   uint64 `json:"peak_heap_bytes"`
+	TotalAllocBytes uint64 `json:"total_alloc_bytes"`
+}
+
+type analyzerProfile struct {
+	Name     string        `json:"name"`
+	WallTime time.Duration `json:"wall_time_ns"`
+}
+
+// sortAnalyzers orders the analyzers by decreasing wall time.
+func (p *nogoProfile) sortAnalyzers() {
+	sort.Slice(p.Analyzers, func(i, j int) bool {
+		a, b := p.Analyzers[i], p.Analyzers[j]
+		if a.WallTime != b.WallTime {
+			return a.WallTime > b.WallTime
+		}
+		return a.Name < b.Name
+	})
+}
+
+func readProfileFile(path string) (*nogoProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profile nogoProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &profile, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Aggregates the profiles written by nogo across a build into a ranked report.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// analyzerTotals aggregates the profiles of one analyzer across packages.
+type analyzerTotals struct {
+	name       string
+	total      time.Duration
+	packages   int
+	max        time.Duration
+	maxPackage string
+}
+
+func nogoProfileReport(args []string) error {
+	flags := flag.NewFlagSet("nogoprofile", flag.ExitOnError)
+	out := flags.String("output", "", "file to write the report to (defaults to stdout)")
+	reportsFile := flags.String("reports", "", "file listing nogo output directories or nogo_profile.json files, one per line")
+	top := flags.Int("top", 20, "number of analyzers and packages to list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	paths, err := nogoOutputFiles(flags.Args(), *reportsFile, nogoProfileBasename)
+	if err != nil {
+		return err
+	}
+	var profiles []*nogoProfile
+	for _, path := range paths {
+		profile, err := readProfileFile(path)
+		if os.IsNotExist(err) {
+			// nogo did not run on this package or profiling is disabled.
+			continue
+		} else if err != nil {
+			return err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(workingDirPath(*out))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeProfileReport(w, profiles, *top)
+}
+
+// writeProfileReport writes a summary of the given profiles, followed by the
+// analyzers ranked by total wall time and the packages ranked by wall time
+// and peak heap size. At most top analyzers and packages are listed.
+func writeProfileReport(w io.Writer, profiles []*nogoProfile, top int) error {
+	var total, parse, typeCheck, factsDecode, factsEncode time.Duration
+	analyzers := make(map[string]*analyzerTotals)
+	for _, p := range profiles {
+		total += p.Total
+		parse += p.Parse
+		typeCheck += p.TypeCheck
+		factsDecode += p.FactsDecode
+		factsEncode += p.FactsEncode
+		for _, a := range p.Analyzers {
+			t, ok := analyzers[a.Name]
+			if !ok {
+				t = &analyzerTotals{name: a.Name}
+				analyzers[a.Name] = t
+			}
+			t.total += a.WallTime
+			t.packages++
+			if a.WallTime > t.max {
+				t.max, t.maxPackage = a.WallTime, p.Package
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "nogo profile of %d packages\n\n", len(profiles))
+	fmt.Fprintf(tw, "PHASE\tTOTAL\tSHARE\n")
+	for _, phase := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"total", total},
+		{"parse", parse},
+		{"type check", typeCheck},
+		{"facts decode", factsDecode},
+		{"facts encode", factsEncode},
+	} {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", phase.name, formatProfileDuration(phase.d), formatShare(phase.d, total))
+	}
+
+	byTime := make([]*analyzerTotals, 0, len(analyzers))
+	for _, t := range analyzers {
+		byTime = append(byTime, t)
+	}
+	sort.Slice(byTime, func(i, j int) bool {
+		if byTime[i].total != byTime[j].total {
+			return byTime[i].total > byTime[j].total
+		}
+		return byTime[i].name < byTime[j].name
+	})
+	fmt.Fprintf(tw, "\nRANK\tANALYZER\tTOTAL\tPACKAGES\tMAX\tMAX PACKAGE\n")
+	for i, t := range byTime {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n", i+1, t.name, formatProfileDuration(t.total), t.packages, formatProfileDuration(t.max), t.maxPackage)
+	}
+
+	packages := append([]*nogoProfile(nil), profiles...)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].Total > packages[j].Total
+	})
+	fmt.Fprintf(tw, "\nRANK\tPACKAGE\tTOTAL\tTYPE CHECK\tSLOWEST ANALYZER\n")
+	for i, p := range packages {
+		if i == top {
+			break
+		}
+		slowest := "-"
+		if len(p.Analyzers) > 0 {
+			p.sortAnalyzers()
+			slowest = fmt.Sprintf("%s (%s)", p.Analyzers[0].Name, formatProfileDuration(p.Analyzers[0].WallTime))
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, p.Package, formatProfileDuration(p.Total), formatProfileDuration(p.TypeCheck), slowest)
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].PeakHeapBytes > packages[j].PeakHeapBytes
+	})
+	fmt.Fprintf(tw, "\nRANK\tPACKAGE\tPEAK HEAP\tTOTAL ALLOC\n")
+	for i, p := range packages {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", i+1, p.Package, formatProfileBytes(p.PeakHeapBytes), formatProfileBytes(p.TotalAllocBytes))
+	}
+	return tw.Flush()
+}
+
+func formatProfileDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+func formatShare(d, total time.Duration) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+}
+
+func formatProfileBytes(b uint64) string {
+	return fmt.Sprintf("%.1fMiB", float64(b)/(1<<20))
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNogoProfileReport(t *testing.T) {
+	dir := t.TempDir()
+	profiles := []nogoProfile{
+		{
+			Package:   "example.com/a",
+			Total:     3 * time.Second,
+			TypeCheck: time.Second,
+			Analyzers: []analyzerProfile{
+				{Name: "printf", WallTime: 500 * time.Millisecond},
+				{Name: "nilness", WallTime: 1500 * time.Millisecond},
+			},
+			PeakHeapBytes:   64 << 20,
+			TotalAllocBytes: 128 << 20,
+		},
+		{
+			Package:   "example.com/b",
+			Total:     time.Second,
+			TypeCheck: 250 * time.Millisecond,
+			Analyzers: []analyzerProfile{
+				{Name: "printf", WallTime: 750 * time.Millisecond},
+			},
+			PeakHeapBytes:   256 << 20,
+			TotalAllocBytes: 512 << 20,
+		},
+	}
+	var reportDirs []string
+	for i, p := range profiles {
+		reportDir := filepath.Join(dir, string(rune('a'+i))+"_nogo")
+		if err := os.Mkdir(reportDir, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(reportDir, nogoProfileBasename), data, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		reportDirs = append(reportDirs, reportDir)
+	}
+	// Output directories without a profile are skipped.
+	reportDirs = append(reportDirs, filepath.Join(dir, "missing_nogo"))
+	reportsFile := filepath.Join(dir, "reports.txt")
+	if err := os.WriteFile(reportsFile, []byte(strings.Join(reportDirs, "\n")+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "report.txt")
+	if err := nogoProfileReport([]string{"-reports", reportsFile, "-output", out}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		`nogo profile of 2 packages`,
+		`total +4\.000s +100\.0%`,
+		`type check +1\.250s +31\.2%`,
+		`1 +nilness +1\.500s +1 +1\.500s +example\.com/a`,
+		`2 +printf +1\.250s +2 +0\.750s +example\.com/b`,
+		`1 +example\.com/a +3\.000s +1\.000s +nilness \(1\.500s\)`,
+		`1 +example\.com/b +256\.0MiB +512\.0MiB`,
+	} {
+		if !regexp.MustCompile(want).MatchString(report) {
+			t.Errorf("report does not match %q:\n%s", want, report)
+		}
+	}
+}
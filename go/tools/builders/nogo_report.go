@@ -0,0 +1,410 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains the machine-readable diagnostics reports written by nogo
+// next to the plain text log, in nogo's own JSON format as well as in SARIF
+// 2.1.0.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	nogoDocURL   = "https://github.com/bazel-contrib/rules_go/blob/master/go/nogo.rst"
+)
+
+// nogoReport is the JSON report of all diagnostics found in a package.
+type nogoReport struct {
+	Package     string             `json:"package"`
+	Diagnostics []reportDiagnostic `json:"diagnostics"`
+}
+
+type reportDiagnostic struct {
+	Analyzer       string          `json:"analyzer"`
+	Severity       string          `json:"severity"`
+	Category       string          `json:"category,omitempty"`
+	Message        string          `json:"message"`
+	URL            string          `json:"url,omitempty"`
+	Range          reportRange     `json:"range"`
+	Related        []reportRelated `json:"related,omitempty"`
+	SuggestedFixes []reportFix     `json:"suggested_fixes,omitempty"`
+	// Fingerprint identifies the diagnostic in baseline files.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// SuppressedBy is empty for diagnostics that are reported to the user
+	// and otherwise names the mechanism that suppressed the diagnostic.
+	SuppressedBy string `json:"suppressed_by,omitempty"`
+	// Justification is the explanation given in the nolint directive that
+	// suppressed the diagnostic, if any.
+	Justification string `json:"justification,omitempty"`
+}
+
+// reportRange is a range of source code. Lines and columns are 1-based,
+// columns are measured in bytes. The end position is exclusive.
+type reportRange struct {
+	File        string `json:"file"`
+	StartLine   int    `json:"start_line"`
+	StartColumn int    `json:"start_column"`
+	EndLine     int    `json:"end_line"`
+	EndColumn   int    `json:"end_column"`
+}
+
+type reportRelated struct {
+	Message string      `json:"message"`
+	Range   reportRange `json:"range"`
+}
+
+type reportFix struct {
+	Message string       `json:"message"`
+	Edits   []reportEdit `json:"edits"`
+}
+
+type reportEdit struct {
+	Range   reportRange `json:"range"`
+	NewText string      `json:"new_text"`
+}
+
+// newNogoReport converts diagnostics and suppressed diagnostics into a report.
+// File names are made relative to cwd if possible.
+func newNogoReport(packagePath, cwd string, diagnostics, suppressed []diagnosticEntry, fset *token.FileSet) *nogoReport {
+	report := &nogoReport{
+		Package:     packagePath,
+		Diagnostics: []reportDiagnostic{},
+	}
+	toRange := func(pos, end token.Pos) reportRange {
+		if !end.IsValid() {
+			end = pos
+		}
+		start, stop := fset.Position(pos), fset.Position(end)
+		return reportRange{
+			File:        relativeFilename(cwd, start.Filename),
+			StartLine:   start.Line,
+			StartColumn: start.Column,
+			EndLine:     stop.Line,
+			EndColumn:   stop.Column,
+		}
+	}
+	for _, entries := range [][]diagnosticEntry{diagnostics, suppressed} {
+		for _, entry := range entries {
+			d := reportDiagnostic{
+				Analyzer:      entry.analyzerName,
+				Severity:      entry.severity,
+				Category:      entry.Category,
+				Message:       entry.Message,
+				URL:           entry.URL,
+				Range:         toRange(entry.Pos, entry.End),
+				Fingerprint:   entry.fingerprint,
+				SuppressedBy:  entry.suppressedBy,
+				Justification: entry.justification,
+			}
+			for _, related := range entry.Related {
+				d.Related = append(d.Related, reportRelated{
+					Message: related.Message,
+					Range:   toRange(related.Pos, related.End),
+				})
+			}
+			for _, sf := range entry.SuggestedFixes {
+				fix := reportFix{Message: sf.Message, Edits: []reportEdit{}}
+				for _, edit := range sf.TextEdits {
+					fix.Edits = append(fix.Edits, reportEdit{
+						Range:   toRange(edit.Pos, edit.End),
+						NewText: string(edit.NewText),
+					})
+				}
+				d.SuggestedFixes = append(d.SuggestedFixes, fix)
+			}
+			report.Diagnostics = append(report.Diagnostics, d)
+		}
+	}
+	sort.SliceStable(report.Diagnostics, func(i, j int) bool {
+		a, b := report.Diagnostics[i], report.Diagnostics[j]
+		if a.Range.File != b.Range.File {
+			return a.Range.File < b.Range.File
+		}
+		if a.Range.StartLine != b.Range.StartLine {
+			return a.Range.StartLine < b.Range.StartLine
+		}
+		if a.Range.StartColumn != b.Range.StartColumn {
+			return a.Range.StartColumn < b.Range.StartColumn
+		}
+		if a.Analyzer != b.Analyzer {
+			return a.Analyzer < b.Analyzer
+		}
+		return a.Message < b.Message
+	})
+	return report
+}
+
+// The following types model the subset of SARIF 2.1.0 used by nogo. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+	FullDescription  *sarifMessage `json:"fullDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string             `json:"ruleId"`
+	Level            string             `json:"level"`
+	Message          sarifMessage       `json:"message"`
+	Locations        []sarifLocation    `json:"locations"`
+	RelatedLocations []sarifLocation    `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix         `json:"fixes,omitempty"`
+	Suppressions     []sarifSuppression `json:"suppressions,omitempty"`
+	Properties       map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// sarif converts the report into a SARIF log. analyzerDocs maps analyzer
+// names to their documentation and is used to describe the rules. readFile
+// reads the source files, whose byte columns are converted to the code point
+// columns of SARIF.
+func (r *nogoReport) sarif(analyzerDocs map[string]string, readFile func(string) ([]byte, error)) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "nogo",
+			InformationURI: nogoDocURL,
+			Rules:          []sarifRule{},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	sources := &sourceLines{readFile: readFile, files: make(map[string][][]byte)}
+	seenRules := make(map[string]bool)
+	for _, d := range r.Diagnostics {
+		if !seenRules[d.Analyzer] {
+			seenRules[d.Analyzer] = true
+			rule := sarifRule{ID: d.Analyzer}
+			if doc := analyzerDocs[d.Analyzer]; doc != "" {
+				short := doc
+				if i := strings.Index(doc, "\n\n"); i >= 0 {
+					short = doc[:i]
+				}
+				rule.ShortDescription = &sarifMessage{Text: strings.TrimSpace(short)}
+				rule.FullDescription = &sarifMessage{Text: doc}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		result := sarifResult{
+			RuleID:    d.Analyzer,
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: d.Range.sarif(sources)}},
+		}
+		for i, related := range d.Related {
+			id := i
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID:               &id,
+				PhysicalLocation: related.Range.sarif(sources),
+				Message:          &sarifMessage{Text: related.Message},
+			})
+		}
+		for _, fix := range d.SuggestedFixes {
+			sf := sarifFix{Description: sarifMessage{Text: fix.Message}}
+			changes := make(map[string]int)
+			for _, edit := range fix.Edits {
+				idx, ok := changes[edit.Range.File]
+				if !ok {
+					idx = len(sf.ArtifactChanges)
+					changes[edit.Range.File] = idx
+					sf.ArtifactChanges = append(sf.ArtifactChanges, sarifArtifactChange{
+						ArtifactLocation: edit.Range.sarif(sources).ArtifactLocation,
+					})
+				}
+				replacement := sarifReplacement{DeletedRegion: edit.Range.sarif(sources).Region}
+				if edit.NewText != "" {
+					replacement.InsertedContent = &sarifMessage{Text: edit.NewText}
+				}
+				sf.ArtifactChanges[idx].Replacements = append(sf.ArtifactChanges[idx].Replacements, replacement)
+			}
+			result.Fixes = append(result.Fixes, sf)
+		}
+		if d.Category != "" || d.URL != "" {
+			result.Properties = make(map[string]string)
+			if d.Category != "" {
+				result.Properties["category"] = d.Category
+			}
+			if d.URL != "" {
+				result.Properties["url"] = d.URL
+			}
+		}
+		switch d.SuppressedBy {
+		case "":
+		case suppressedByNolint:
+			result.Suppressions = []sarifSuppression{{Kind: "inSource", Justification: d.Justification}}
+		default:
+			result.Suppressions = []sarifSuppression{{Kind: "external"}}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+}
+
+// sarifLevel maps a nogo severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case severityWarning:
+		return "warning"
+	case severityInfo:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func (r reportRange) sarif(sources *sourceLines) sarifPhysicalLocation {
+	loc := sarifPhysicalLocation{
+		Region: sarifRegion{
+			StartLine:   r.StartLine,
+			StartColumn: sources.column(r.File, r.StartLine, r.StartColumn),
+			EndLine:     r.EndLine,
+			EndColumn:   sources.column(r.File, r.EndLine, r.EndColumn),
+		},
+	}
+	if filepath.IsAbs(r.File) {
+		path := filepath.ToSlash(r.File)
+		if !strings.HasPrefix(path, "/") {
+			// Windows paths start with a drive letter.
+			path = "/" + path
+		}
+		loc.ArtifactLocation.URI = (&url.URL{Scheme: "file", Path: path}).String()
+	} else {
+		// Paths are relative to the execution root, which corresponds to the
+		// workspace root for source files.
+		loc.ArtifactLocation.URI = (&url.URL{Path: filepath.ToSlash(r.File)}).String()
+		loc.ArtifactLocation.URIBaseID = "%SRCROOT%"
+	}
+	return loc
+}
+
+// sourceLines holds the lines of the source files that diagnostics refer to.
+type sourceLines struct {
+	readFile func(string) ([]byte, error)
+	files    map[string][][]byte
+}
+
+// column converts the 1-based byte column on a line of file to a 1-based
+// column in Unicode code points. It returns 0, which omits the column, if the
+// line cannot be read.
+func (s *sourceLines) column(file string, line, column int) int {
+	lines, ok := s.files[file]
+	if !ok {
+		if data, err := s.readFile(file); err == nil {
+			lines = bytes.Split(data, []byte("\n"))
+		}
+		s.files[file] = lines
+	}
+	if line < 1 || line > len(lines) || column < 1 || column-1 > len(lines[line-1]) {
+		return 0
+	}
+	return utf8.RuneCount(lines[line-1][:column-1]) + 1
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", filepath.Base(path), err)
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(path, data, 0o666); err != nil {
+		return fmt.Errorf("writing %q: %w", path, err)
+	}
+	return nil
+}
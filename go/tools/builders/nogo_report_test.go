@@ -0,0 +1,271 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func newReportTestFileSet() (*token.FileSet, *token.File) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("/execroot/pkg/file.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(20)
+	f.AddLine(40)
+	return fset, f
+}
+
+func TestNewNogoReport(t *testing.T) {
+	fset, f := newReportTestFileSet()
+	diagnostics := []diagnosticEntry{
+		{
+			analyzerName: "printf",
+			Diagnostic: analysis.Diagnostic{
+				Pos:      f.Pos(25),
+				End:      f.Pos(30),
+				Category: "format",
+				Message:  "bad format",
+				Related: []analysis.RelatedInformation{
+					{Pos: f.Pos(2), Message: "declared here"},
+				},
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						Message: "use %s",
+						TextEdits: []analysis.TextEdit{
+							{Pos: f.Pos(26), End: f.Pos(28), NewText: []byte("%s")},
+						},
+					},
+				},
+			},
+		},
+	}
+	suppressed := []diagnosticEntry{
+		{
+			analyzerName:  "bools",
+			suppressedBy:  suppressedByNolint,
+			justification: "intended",
+			Diagnostic:    analysis.Diagnostic{Pos: f.Pos(5), Message: "redundant or"},
+		},
+	}
+
+	got := newNogoReport("example.com/pkg", "/execroot", diagnostics, suppressed, fset)
+	want := &nogoReport{
+		Package: "example.com/pkg",
+		Diagnostics: []reportDiagnostic{
+			{
+				Analyzer:      "bools",
+				Message:       "redundant or",
+				Range:         reportRange{File: "pkg/file.go", StartLine: 1, StartColumn: 6, EndLine: 1, EndColumn: 6},
+				SuppressedBy:  suppressedByNolint,
+				Justification: "intended",
+			},
+			{
+				Analyzer: "printf",
+				Category: "format",
+				Message:  "bad format",
+				Range:    reportRange{File: "pkg/file.go", StartLine: 2, StartColumn: 6, EndLine: 2, EndColumn: 11},
+				Related: []reportRelated{
+					{
+						Message: "declared here",
+						Range:   reportRange{File: "pkg/file.go", StartLine: 1, StartColumn: 3, EndLine: 1, EndColumn: 3},
+					},
+				},
+				SuggestedFixes: []reportFix{
+					{
+						Message: "use %s",
+						Edits: []reportEdit{
+							{
+								Range:   reportRange{File: "pkg/file.go", StartLine: 2, StartColumn: 7, EndLine: 2, EndColumn: 9},
+								NewText: "%s",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newNogoReport() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestNogoReportSARIF(t *testing.T) {
+	fset, f := newReportTestFileSet()
+	diagnostics := []diagnosticEntry{
+		{
+			analyzerName: "printf",
+			Diagnostic: analysis.Diagnostic{
+				Pos:     f.Pos(25),
+				Message: "bad format",
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						Message: "remove",
+						TextEdits: []analysis.TextEdit{
+							{Pos: f.Pos(26), End: f.Pos(28)},
+						},
+					},
+				},
+			},
+		},
+	}
+	suppressed := []diagnosticEntry{
+		{
+			analyzerName: "printf",
+			suppressedBy: suppressedByExcludeFiles,
+			Diagnostic:   analysis.Diagnostic{Pos: f.Pos(45), Message: "other format"},
+		},
+		{
+			analyzerName:  "printf",
+			suppressedBy:  suppressedByNolint,
+			justification: "intended",
+			Diagnostic:    analysis.Diagnostic{Pos: f.Pos(46), Message: "other format"},
+		},
+	}
+	report := newNogoReport("example.com/pkg", "/execroot", diagnostics, suppressed, fset)
+	// The second line starts with a two-byte code point.
+	source := strings.Repeat("a", 19) + "\n" + "h\u00e9llo" + strings.Repeat("b", 13) + "\n" + strings.Repeat("c", 59) + "\n"
+	readFile := func(name string) ([]byte, error) {
+		if name != "pkg/file.go" {
+			t.Errorf("read unexpected file %q", name)
+		}
+		return []byte(source), nil
+	}
+	log := report.sarif(map[string]string{"printf": "check printf calls\n\nLonger description."}, readFile)
+
+	if log.Version != sarifVersion || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %+v", log)
+	}
+	run := log.Runs[0]
+	if run.ColumnKind != "unicodeCodePoints" {
+		t.Errorf("got column kind %q, want unicodeCodePoints", run.ColumnKind)
+	}
+	wantRules := []sarifRule{{
+		ID:               "printf",
+		ShortDescription: &sarifMessage{Text: "check printf calls"},
+		FullDescription:  &sarifMessage{Text: "check printf calls\n\nLonger description."},
+	}}
+	if !reflect.DeepEqual(run.Tool.Driver.Rules, wantRules) {
+		t.Errorf("got rules %+v, want %+v", run.Tool.Driver.Rules, wantRules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(run.Results))
+	}
+	first := run.Results[0]
+	if first.Suppressions != nil {
+		t.Errorf("unexpected suppressions on reported diagnostic: %+v", first.Suppressions)
+	}
+	wantLoc := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: "pkg/file.go", URIBaseID: "%SRCROOT%"},
+		Region:           sarifRegion{StartLine: 2, StartColumn: 5, EndLine: 2, EndColumn: 5},
+	}
+	if !reflect.DeepEqual(first.Locations[0].PhysicalLocation, wantLoc) {
+		t.Errorf("got location %+v, want %+v", first.Locations[0].PhysicalLocation, wantLoc)
+	}
+	if len(first.Fixes) != 1 || len(first.Fixes[0].ArtifactChanges) != 1 {
+		t.Fatalf("unexpected fixes: %+v", first.Fixes)
+	}
+	r := first.Fixes[0].ArtifactChanges[0].Replacements[0]
+	if r.InsertedContent != nil {
+		t.Errorf("deletion should not insert content: %+v", r)
+	}
+	if want := (sarifRegion{StartLine: 2, StartColumn: 6, EndLine: 2, EndColumn: 8}); r.DeletedRegion != want {
+		t.Errorf("got deleted region %+v, want %+v", r.DeletedRegion, want)
+	}
+	wantSuppressions := []sarifSuppression{{Kind: "external"}}
+	if !reflect.DeepEqual(run.Results[1].Suppressions, wantSuppressions) {
+		t.Errorf("got suppressions %+v, want %+v", run.Results[1].Suppressions, wantSuppressions)
+	}
+	wantSuppressions = []sarifSuppression{{Kind: "inSource", Justification: "intended"}}
+	if !reflect.DeepEqual(run.Results[2].Suppressions, wantSuppressions) {
+		t.Errorf("got suppressions %+v, want %+v", run.Results[2].Suppressions, wantSuppressions)
+	}
+}
+
+func TestReportRangeSARIF(t *testing.T) {
+	sources := &sourceLines{
+		readFile: func(name string) ([]byte, error) { return nil, os.ErrNotExist },
+		files:    make(map[string][][]byte),
+	}
+	for _, tt := range []struct {
+		file string
+		want sarifPhysicalLocation
+	}{
+		{
+			file: filepath.Join("pkg", "a b.go"),
+			want: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "pkg/a%20b.go", URIBaseID: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: 1, EndLine: 2},
+			},
+		},
+		{
+			file: "/tmp/a.go",
+			want: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "file:///tmp/a.go"},
+				Region:           sarifRegion{StartLine: 1, EndLine: 2},
+			},
+		},
+	} {
+		if runtime.GOOS == "windows" && tt.want.ArtifactLocation.URIBaseID == "" {
+			// Absolute paths start with a drive letter.
+			continue
+		}
+		// Columns are omitted if the file cannot be read.
+		r := reportRange{File: tt.file, StartLine: 1, StartColumn: 3, EndLine: 2, EndColumn: 4}
+		if got := r.sarif(sources); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got location %+v for %s, want %+v", got, tt.file, tt.want)
+		}
+	}
+}
+
+func TestSARIFLevel(t *testing.T) {
+	for severity, want := range map[string]string{
+		severityError:   "error",
+		severityWarning: "warning",
+		severityInfo:    "note",
+		"":              "error",
+	} {
+		if got := sarifLevel(severity); got != want {
+			t.Errorf("sarifLevel(%q) = %q, want %q", severity, got, want)
+		}
+	}
+}
+
+func TestWriteJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nogo.json")
+	report := &nogoReport{Package: "pkg", Diagnostics: []reportDiagnostic{}}
+	if err := writeJSONFile(path, report); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got nogoReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, report) {
+		t.Errorf("got %+v, want %+v", got, report)
+	}
+}
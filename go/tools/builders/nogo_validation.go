@@ -17,6 +17,19 @@ func nogoValidation(args []string) error {
 	}
 	defer out.Close()
 
+	// Warnings are printed and recorded in the validation output, but don't
+	// fail the build.
+	warnings, err := os.ReadFile(filepath.Join(args[1], nogoWarningsBasename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading nogo warnings file: %w", err)
+	}
+	if len(warnings) > 0 {
+		if _, err := out.Write(warnings); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "\n%s", warnings)
+	}
+
 	logFile := filepath.Join(args[1], nogoLogBasename)
 	logContent, err := os.ReadFile(logFile)
 	if os.IsNotExist(err) {
@@ -45,9 +58,7 @@ $ patch -p1 < %s
 	}
 	// Separate nogo output from Bazel's --sandbox_debug message via an
 	// empty line.
-	// Don't return to avoid printing the "nogovalidation:" prefix.
+	// Return an exitError to avoid printing the "nogovalidation:" prefix.
 	_, _ = fmt.Fprintf(os.Stderr, "\n%s%s\n", logContent, fixMessage)
-	os.Exit(1)
-	// Not reached
-	return nil
+	return &exitError{code: 1}
 }
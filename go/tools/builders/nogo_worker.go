@@ -0,0 +1,167 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the persistent worker mode of nogo, which is used by
+// the builder when it runs as a persistent worker itself. Requests are
+// analyzed in this process one after another and share the caches in
+// nogo_cache.go. The builder only sends singleplex requests to nogo workers,
+// see nogoWorkerSet.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"runtime/debug"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/internal/facts"
+)
+
+// runNogoWorker handles work requests read from r until r is closed.
+func runNogoWorker(r io.Reader, w io.Writer, startupArgs []string, protocol string) error {
+	conn, err := newWorkerConn(protocol, r, w)
+	if err != nil {
+		return err
+	}
+	// Analyzers must not write to the stream used for responses.
+	os.Stdout = os.Stderr
+
+	cache := newExportDataCache(nogoWorkerCacheSize)
+	return serveWorkRequests(conn, func(req *workRequest) *workResponse {
+		return handleNogoWorkRequest(cache, startupArgs, req)
+	}, nil)
+}
+
+// handleNogoWorkRequest analyzes a package as if nogo was started with the
+// arguments of the request and returns its exit code and output.
+func handleNogoWorkRequest(cache *exportDataCache, startupArgs []string, req *workRequest) (resp *workResponse) {
+	var out bytes.Buffer
+	logger := log.New(&out, "nogo: ", 0)
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(&out, "panic: %v\n\n%s", r, debug.Stack())
+			resp = &workResponse{ExitCode: 2, Output: out.String()}
+		}
+	}()
+
+	args, err := workerRequestArgs(startupArgs, req)
+	if err != nil {
+		logger.Print(err)
+		return &workResponse{ExitCode: int32(nogoError), Output: out.String()}
+	}
+	if err, exitCode := run(args, cache); err != nil {
+		logger.Print(err)
+		return &workResponse{ExitCode: int32(exitCode), Output: out.String()}
+	}
+	return &workResponse{}
+}
+
+// useCache makes the importer read type information and facts through
+// cache. releaseCache must be called once the analysis of the package is
+// done.
+func (i *importer) useCache(cache *exportDataCache) {
+	i.cache = cache
+	cache.acquire(i.packageFile)
+	i.shared = true
+	i.fset, i.packageCache = cache.fset, cache.packages
+	i.imported = make(map[string]bool)
+}
+
+func (i *importer) releaseCache() {
+	if !i.shared {
+		return
+	}
+	if i.conflict {
+		i.cache.stale = true
+	}
+	i.cache.release(i.parsed)
+}
+
+// importShared imports the package with the given path from archive into the
+// type information of the cache.
+func (i *importer) importShared(path, archive string) (*types.Package, error) {
+	digest, err := i.cache.digest(archive)
+	if err != nil {
+		// Report the same error as without the cache.
+		_, err = readExportData(archive)
+		return nil, err
+	}
+	i.imported[path] = true
+	if pkg := i.cache.lookup(path, digest); pkg != nil {
+		return pkg, nil
+	}
+
+	data, err := readExportData(archive)
+	if err != nil {
+		return nil, err
+	}
+	// Import the package on its own as well to find out which objects a new
+	// process would know about.
+	privateFset := token.NewFileSet()
+	private, err := gcexportdata.Read(bytes.NewReader(data), privateFset, make(map[string]*types.Package), path)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := gcexportdata.Read(bytes.NewReader(data), i.fset, i.packageCache, path)
+	if err != nil || !i.cache.consistent(private, privateFset) {
+		// The package is analyzed again without the cache, see run.
+		i.conflict = true
+		return private, nil
+	}
+	i.cache.add(digest, pkg, private)
+	return pkg, nil
+}
+
+// readExportData returns the export data in archive.
+func readExportData(archive string) ([]byte, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gcexportdata.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// readCachedFacts returns the content of the facts file at path.
+func (i *importer) readCachedFacts(path string) ([]byte, error) {
+	return i.cache.readFacts(path)
+}
+
+// checkFacts records whether set holds facts about packages or objects that a
+// new process would not have created, in which case the package is analyzed
+// again without the cache, see run.
+func (i *importer) checkFacts(set *facts.Set) {
+	if !i.shared {
+		return
+	}
+	factTypes := make(map[reflect.Type]bool)
+	for _, a := range factProducers(analyzers) {
+		for _, f := range a.FactTypes {
+			factTypes[reflect.TypeOf(f)] = true
+		}
+	}
+	i.hiddenFacts = i.cache.hiddenFacts(set, factTypes, i.imported)
+}
@@ -14,7 +14,12 @@
 
 package main
 
-import "strings"
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+)
 
 // Parse nolint directives and return the applicable linters. If all linters
 // apply, returns (nil, true).
@@ -42,3 +47,77 @@ func parseNolint(text string) (map[string]bool, bool) {
 	}
 	return result, true
 }
+
+// nolintJustification returns the explanation that follows a nolint
+// directive, e.g. "reason" for "//nolint:foo // reason".
+func nolintJustification(text string) string {
+	text = strings.TrimLeft(text, "/ ")
+	i := strings.Index(text, "//")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(text[i+len("//"):])
+}
+
+// nolintDirective is a nolint comment found in the analyzed package.
+type nolintDirective struct {
+	pos  token.Pos
+	text string
+	// linters are the analyzers the directive applies to. It is nil if the
+	// directive applies to all analyzers.
+	linters map[string]bool
+	// used records the analyzers whose diagnostics were suppressed by the
+	// directive.
+	used map[string]bool
+}
+
+// nolintIssue is a problem with a nolint directive found in strict mode.
+type nolintIssue struct {
+	pos     token.Pos
+	message string
+}
+
+// checkNolintDirectives reports nolint directives that name analyzers not in
+// known, that did not suppress any diagnostic and, if requireJustification
+// is set, that lack a justification. Unknown analyzers are not reported if
+// known is nil. Unused directives are only reported if checkUnused is set,
+// which requires that all analyzers ran successfully.
+func checkNolintDirectives(directives []*nolintDirective, known map[string]bool, checkUnused, requireJustification bool) []nolintIssue {
+	var issues []nolintIssue
+	for _, d := range directives {
+		report := func(format string, args ...interface{}) {
+			issues = append(issues, nolintIssue{pos: d.pos, message: fmt.Sprintf(format, args...)})
+		}
+		linters := make([]string, 0, len(d.linters))
+		for linter := range d.linters {
+			linters = append(linters, linter)
+		}
+		sort.Strings(linters)
+
+		var knownLinters []string
+		for _, linter := range linters {
+			if known == nil || known[linter] {
+				knownLinters = append(knownLinters, linter)
+			} else {
+				report("nolint directive names unknown analyzer %q", linter)
+			}
+		}
+		if checkUnused {
+			if d.linters == nil {
+				if len(d.used) == 0 {
+					report("nolint directive is unused")
+				}
+			} else {
+				for _, linter := range knownLinters {
+					if !d.used[linter] {
+						report("nolint directive is unused for analyzer %q", linter)
+					}
+				}
+			}
+		}
+		if requireJustification && nolintJustification(d.text) == "" {
+			report("nolint directive has no justification, add one after \"//\"")
+		}
+	}
+	return issues
+}
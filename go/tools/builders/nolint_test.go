@@ -89,3 +89,68 @@ func TestParseNolint(t *testing.T) {
 		})
 	}
 }
+
+func TestNolintJustification(t *testing.T) {
+	for comment, want := range map[string]string{
+		"//nolint":                       "",
+		"//nolint:foo //":                "",
+		"//nolint:foo // some reason":    "some reason",
+		"// nolint:a,b //  some reason ": "some reason",
+	} {
+		if got := nolintJustification(comment); got != want {
+			t.Errorf("nolintJustification(%q) = %q, want %q", comment, got, want)
+		}
+	}
+}
+
+func TestCheckNolintDirectives(t *testing.T) {
+	directives := []*nolintDirective{
+		{pos: 1, text: "//nolint:foo // reason", linters: map[string]bool{"foo": true}, used: map[string]bool{"foo": true}},
+		{pos: 2, text: "//nolint:foo,bar // reason", linters: map[string]bool{"foo": true, "bar": true}, used: map[string]bool{"foo": true}},
+		{pos: 3, text: "//nolint:unknown // reason", linters: map[string]bool{"unknown": true}, used: map[string]bool{}},
+		{pos: 4, text: "//nolint", used: map[string]bool{}},
+		{pos: 5, text: "//nolint", used: map[string]bool{"foo": true}},
+	}
+	known := map[string]bool{"foo": true, "bar": true}
+
+	tests := []struct {
+		name                              string
+		known                             map[string]bool
+		checkUnused, requireJustification bool
+		want                              []nolintIssue
+	}{
+		{
+			name:        "strict",
+			known:       known,
+			checkUnused: true,
+			want: []nolintIssue{
+				{pos: 2, message: `nolint directive is unused for analyzer "bar"`},
+				{pos: 3, message: `nolint directive names unknown analyzer "unknown"`},
+				{pos: 4, message: "nolint directive is unused"},
+			},
+		},
+		{
+			name:  "analyzers failed",
+			known: known,
+			want: []nolintIssue{
+				{pos: 3, message: `nolint directive names unknown analyzer "unknown"`},
+			},
+		},
+		{
+			name:                 "justification only",
+			requireJustification: true,
+			want: []nolintIssue{
+				{pos: 4, message: `nolint directive has no justification, add one after "//"`},
+				{pos: 5, message: `nolint directive has no justification, add one after "//"`},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := checkNolintDirectives(directives, tc.known, tc.checkUnused, tc.requireJustification)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements Bazel's persistent worker protocol for the builder.
+// See https://bazel.build/remote/persistent for an overview.
+//
+// Actions run in the builder process and modify process-wide state such as
+// environment variables and the standard streams, so a worker only runs one
+// request at a time. Multiplex requests are forwarded to a pool of at most
+// GOMAXPROCS child workers, each of which runs one request at a time. nogo is run as a
+// persistent worker as well, which keeps the type information of imported
+// packages in memory between requests.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// runWorker handles work requests read from r until r is closed. Requests
+// with a request ID of zero are run in this process one after another.
+// Requests with other IDs are multiplex requests and are run concurrently by
+// child workers.
+func runWorker(r io.Reader, w io.Writer, startupArgs []string, protocol string) error {
+	conn, err := newWorkerConn(protocol, r, w)
+	if err != nil {
+		return err
+	}
+	// Actions must not write to the stream used for responses.
+	os.Stdout = os.Stderr
+
+	pool := &workerPool{start: func() (*workerChild, error) {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		return startWorkerChild(exe, startupArgs, "", workerEnviron)
+	}}
+	defer pool.close()
+	nogoWorkers = &nogoWorkerSet{start: func(nogoPath, dir string, env []string) (*workerChild, error) {
+		return startWorkerChild(nogoPath, nil, dir, env)
+	}}
+	defer nogoWorkers.close()
+
+	return serveWorkRequests(conn, func(req *workRequest) *workResponse {
+		return handleWorkRequest(startupArgs, req)
+	}, pool.do)
+}
+
+// handleWorkRequest runs the builder with the given request in this process.
+func handleWorkRequest(startupArgs []string, req *workRequest) *workResponse {
+	args, err := workerRequestArgs(startupArgs, req)
+	if err == nil && req.SandboxDir != "" {
+		// Actions resolve paths against the working directory of the
+		// worker, so they would use the wrong files.
+		err = fmt.Errorf("multiplex sandboxing is not supported, but the request has sandbox directory %q", req.SandboxDir)
+	}
+	if err != nil {
+		return &workResponse{
+			ExitCode:  1,
+			Output:    fmt.Sprintf("builder: %v\n", err),
+			RequestID: req.RequestID,
+		}
+	}
+	exitCode, output := runIsolated(args)
+	return &workResponse{
+		ExitCode:  int32(exitCode),
+		Output:    output,
+		RequestID: req.RequestID,
+	}
+}
+
+// runIsolated runs the builder with the given arguments as if it was started
+// as a new process and returns its exit code and output. State of the process
+// that actions may modify is restored afterwards.
+func runIsolated(args []string) (exitCode int, output string) {
+	environ := os.Environ()
+	wd, err := os.Getwd()
+	if err != nil {
+		return 1, fmt.Sprintf("builder: %v\n", err)
+	}
+	buildContext := build.Default
+	buildTags := append([]string{}, build.Default.BuildTags...)
+	stdout, stderr := os.Stdout, os.Stderr
+
+	// Capture the output of the action and its subprocesses in a file so that
+	// subprocesses can write to it directly.
+	out, err := ioutil.TempFile("", "rules_go_worker-")
+	if err != nil {
+		return 1, fmt.Sprintf("builder: %v\n", err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	os.Stdout, os.Stderr = out, out
+	log.SetOutput(out)
+	log.SetFlags(0)
+	log.SetPrefix("builder: ")
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(out, "panic: %v\n\n%s", r, debug.Stack())
+			exitCode = 2
+		}
+
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(stderr)
+		log.SetPrefix("builder: ")
+		build.Default = buildContext
+		build.Default.BuildTags = buildTags
+		if err := restoreEnviron(environ); err != nil && exitCode == 0 {
+			fmt.Fprintf(out, "builder: restoring environment: %v\n", err)
+			exitCode = 1
+		}
+		if err := os.Chdir(wd); err != nil && exitCode == 0 {
+			fmt.Fprintf(out, "builder: restoring working directory: %v\n", err)
+			exitCode = 1
+		}
+
+		data, err := ioutil.ReadFile(out.Name())
+		if err != nil && exitCode == 0 {
+			data = []byte(fmt.Sprintf("builder: reading output: %v\n", err))
+			exitCode = 1
+		}
+		output = string(data)
+	}()
+
+	if err := runBuilder(args); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			return exitErr.code, ""
+		}
+		log.Print(err)
+		return 1, ""
+	}
+	return 0, ""
+}
+
+// restoreEnviron replaces the environment of the process with environ, as
+// returned by os.Environ.
+func restoreEnviron(environ []string) error {
+	os.Clearenv()
+	for _, kv := range environ {
+		// Skip the first character to handle Windows variables such as "=C:".
+		i := 1
+		for i < len(kv) && kv[i] != '=' {
+			i++
+		}
+		if i == len(kv) {
+			continue
+		}
+		if err := os.Setenv(kv[:i], kv[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workerChild is a child worker that runs one request at a time.
+type workerChild struct {
+	enc  *json.Encoder
+	dec  *json.Decoder
+	stop func() error
+}
+
+// workerEnviron is the environment the worker was started with, which is
+// passed on to child workers.
+var workerEnviron = os.Environ()
+
+// workerStderr is the standard error stream of the worker, to which child
+// workers write their logs.
+var workerStderr = os.Stderr
+
+// startWorkerChild starts the binary at path as a persistent worker that
+// communicates with this process using JSON. dir is its working directory,
+// or that of this process if empty.
+func startWorkerChild(path string, startupArgs []string, dir string, env []string) (*workerChild, error) {
+	args := append([]string{workerProtocolFlag + workerProtocolJSON}, startupArgs...)
+	cmd := exec.Command(path, append(args, persistentWorkerFlag)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stderr = workerStderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &workerChild{
+		enc: json.NewEncoder(stdin),
+		dec: json.NewDecoder(stdout),
+		stop: func() error {
+			stdin.Close()
+			return cmd.Wait()
+		},
+	}, nil
+}
+
+func (c *workerChild) do(req *workRequest) (*workResponse, error) {
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+	var resp workResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// workerPool runs multiplex requests on idle child workers, starting new ones
+// as needed. Requests wait once max requests are running, so that a burst of
+// requests doesn't start an unbounded number of processes.
+type workerPool struct {
+	start func() (*workerChild, error)
+	// max is the maximum number of child workers, or runtime.GOMAXPROCS(0)
+	// if zero.
+	max int
+
+	initOnce sync.Once
+	slots    chan struct{} // holds a value for each running request
+
+	mu   sync.Mutex
+	idle []*workerChild
+}
+
+func (p *workerPool) do(req *workRequest) *workResponse {
+	failed := func(err error) *workResponse {
+		return &workResponse{
+			ExitCode:  1,
+			Output:    fmt.Sprintf("builder: running request in child worker: %v\n", err),
+			RequestID: req.RequestID,
+		}
+	}
+
+	p.initOnce.Do(func() {
+		max := p.max
+		if max <= 0 {
+			max = runtime.GOMAXPROCS(0)
+		}
+		p.slots = make(chan struct{}, max)
+	})
+	// Every running request holds a child worker, so there are never more
+	// child workers than slots.
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	var child *workerChild
+	if n := len(p.idle); n > 0 {
+		child, p.idle = p.idle[n-1], p.idle[:n-1]
+	}
+	p.mu.Unlock()
+	if child == nil {
+		var err error
+		if child, err = p.start(); err != nil {
+			return failed(err)
+		}
+	}
+
+	// Child workers only run singleplex requests.
+	childReq := *req
+	childReq.RequestID = 0
+	resp, err := child.do(&childReq)
+	if err != nil {
+		child.stop()
+		return failed(err)
+	}
+	p.mu.Lock()
+	p.idle = append(p.idle, child)
+	p.mu.Unlock()
+
+	resp.RequestID = req.RequestID
+	return resp
+}
+
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, child := range p.idle {
+		child.stop()
+	}
+	p.idle = nil
+}
+
+// nogoWorkers holds the nogo workers started by runNogo when the builder runs
+// as a persistent worker. It is nil otherwise.
+var nogoWorkers *nogoWorkerSet
+
+// nogoWorkerSet holds a nogo worker for each nogo binary, working directory
+// and environment that nogo is run with, so that it is only run with the
+// same state as a new process would be. The builder runs one request at a
+// time, so each nogo worker is only sent singleplex requests.
+type nogoWorkerSet struct {
+	start func(nogoPath, dir string, env []string) (*workerChild, error)
+
+	mu      sync.Mutex
+	workers map[string]*workerChild
+}
+
+// run runs the nogo binary at nogoPath with the given arguments in a worker
+// and returns its exit code and output.
+func (s *nogoWorkerSet) run(nogoPath string, args []string) (int, []byte, error) {
+	nogoPath = abs(nogoPath)
+	dir, err := os.Getwd()
+	if err != nil {
+		return 0, nil, err
+	}
+	env := os.Environ()
+	key := strings.Join(append([]string{nogoPath, dir}, env...), "\x00")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	child, ok := s.workers[key]
+	if !ok {
+		if child, err = s.start(nogoPath, dir, env); err != nil {
+			return 0, nil, err
+		}
+		if s.workers == nil {
+			s.workers = make(map[string]*workerChild)
+		}
+		s.workers[key] = child
+	}
+	resp, err := child.do(&workRequest{Arguments: args})
+	if err != nil {
+		child.stop()
+		delete(s.workers, key)
+		return 0, nil, err
+	}
+	return int(resp.ExitCode), []byte(resp.Output), nil
+}
+
+func (s *nogoWorkerSet) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, child := range s.workers {
+		child.stop()
+		delete(s.workers, key)
+	}
+}
@@ -0,0 +1,522 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the encodings of Bazel's persistent worker protocol.
+// See https://bazel.build/remote/persistent for an overview. Note that this
+// file is shared between the nogo binary and the builder.
+//
+// The builder only depends on the standard library, so the few protocol
+// buffer messages involved are encoded by hand. Each message is preceded by
+// its length as a varint, as done by writeDelimitedTo in Java.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	// persistentWorkerFlag is passed by Bazel to start a persistent worker.
+	persistentWorkerFlag = "--persistent_worker"
+
+	// workerProtocolFlag selects the encoding of work requests and responses
+	// if it is the first argument of the builder or nogo.
+	workerProtocolFlag  = "-worker_protocol="
+	workerProtocolProto = "proto"
+	workerProtocolJSON  = "json"
+)
+
+// workRequest and workResponse mirror the messages of the same name in
+// Bazel's worker_protocol.proto. The JSON field names follow the proto3 JSON
+// mapping used by Bazel.
+type workRequest struct {
+	Arguments []string    `json:"arguments,omitempty"`
+	Inputs    []workInput `json:"inputs,omitempty"`
+	RequestID int32       `json:"requestId,omitempty"`
+	Cancel    bool        `json:"cancel,omitempty"`
+	Verbosity int32       `json:"verbosity,omitempty"`
+	// SandboxDir is only set with multiplex sandboxing, which the builder
+	// doesn't support: requests that set it are rejected.
+	SandboxDir string `json:"sandboxDir,omitempty"`
+}
+
+type workInput struct {
+	Path   string `json:"path,omitempty"`
+	Digest []byte `json:"digest,omitempty"`
+}
+
+type workResponse struct {
+	ExitCode     int32  `json:"exitCode"`
+	Output       string `json:"output,omitempty"`
+	RequestID    int32  `json:"requestId,omitempty"`
+	WasCancelled bool   `json:"wasCancelled,omitempty"`
+}
+
+// workerConn reads work requests and writes work responses in one of the
+// encodings supported by Bazel.
+type workerConn interface {
+	readRequest() (*workRequest, error)
+	writeResponse(*workResponse) error
+}
+
+type jsonWorkerConn struct {
+	dec *json.Decoder
+	enc *json.Encoder
+}
+
+func (c *jsonWorkerConn) readRequest() (*workRequest, error) {
+	var req workRequest
+	if err := c.dec.Decode(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (c *jsonWorkerConn) writeResponse(resp *workResponse) error {
+	return c.enc.Encode(resp)
+}
+
+func newWorkerConn(protocol string, r io.Reader, w io.Writer) (workerConn, error) {
+	switch protocol {
+	case workerProtocolProto:
+		return newProtoWorkerConn(r, w), nil
+	case workerProtocolJSON:
+		return &jsonWorkerConn{dec: json.NewDecoder(r), enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown worker protocol %q", protocol)
+	}
+}
+
+// persistentWorkerArgs reports whether the process was started as a
+// persistent worker and returns the arguments it was started with, which
+// precede the arguments of every request.
+func persistentWorkerArgs(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == persistentWorkerFlag {
+			startupArgs := append([]string{}, args[:i]...)
+			return append(startupArgs, args[i+1:]...), true
+		}
+	}
+	return nil, false
+}
+
+// workerRequestArgs returns the arguments to run a work request with.
+func workerRequestArgs(startupArgs []string, req *workRequest) ([]string, error) {
+	args := append([]string{}, startupArgs...)
+	if len(req.Arguments) == 0 {
+		return args, nil
+	}
+	// Bazel passes each line of the flag files as an argument without
+	// removing the quoting of the "shell" params file format.
+	reqArgs, err := parseParams([]byte(strings.Join(req.Arguments, "\n") + "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing work request arguments: %v", err)
+	}
+	return append(args, reqArgs...), nil
+}
+
+// serveWorkRequests handles the work requests read from conn until it is
+// closed. Requests with a request ID of zero are passed to singleplex one
+// after another. Other requests are multiplex requests and are passed to
+// multiplex concurrently, or rejected if multiplex is nil.
+func serveWorkRequests(conn workerConn, singleplex, multiplex func(*workRequest) *workResponse) error {
+	var writeMu sync.Mutex
+	writeResponse := func(resp *workResponse) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.writeResponse(resp)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		req, err := conn.readRequest()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading work request: %v", err)
+		}
+		if req.Cancel {
+			// Cancellation is not supported, the request will complete
+			// normally.
+			continue
+		}
+		if req.RequestID == 0 {
+			if err := writeResponse(singleplex(req)); err != nil {
+				return fmt.Errorf("writing work response: %v", err)
+			}
+			continue
+		}
+		if multiplex == nil {
+			resp := &workResponse{
+				ExitCode:  1,
+				Output:    "multiplex work requests are not supported\n",
+				RequestID: req.RequestID,
+			}
+			if err := writeResponse(resp); err != nil {
+				return fmt.Errorf("writing work response: %v", err)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(req *workRequest) {
+			defer wg.Done()
+			resp := multiplex(req)
+			resp.RequestID = req.RequestID
+			if err := writeResponse(resp); err != nil {
+				log.Printf("writing work response: %v", err)
+			}
+		}(req)
+	}
+}
+
+// Field numbers from Bazel's worker_protocol.proto.
+const (
+	workRequestArguments  = 1
+	workRequestInputs     = 2
+	workRequestRequestID  = 3
+	workRequestCancel     = 4
+	workRequestVerbosity  = 5
+	workRequestSandboxDir = 6
+
+	inputPath   = 1
+	inputDigest = 2
+
+	workResponseExitCode     = 1
+	workResponseOutput       = 2
+	workResponseRequestID    = 3
+	workResponseWasCancelled = 4
+)
+
+// Wire types used by the worker protocol.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+// maxWorkMessageSize limits the size of a single message to detect corrupt
+// input early.
+const maxWorkMessageSize = 1 << 30
+
+type protoWorkerConn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func newProtoWorkerConn(r io.Reader, w io.Writer) *protoWorkerConn {
+	return &protoWorkerConn{r: bufio.NewReader(r), w: w}
+}
+
+func (c *protoWorkerConn) readRequest() (*workRequest, error) {
+	data, err := readDelimited(c.r)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalWorkRequest(data)
+}
+
+func (c *protoWorkerConn) writeResponse(resp *workResponse) error {
+	return writeDelimited(c.w, marshalWorkResponse(resp))
+}
+
+// readDelimited reads a length-prefixed message. It returns io.EOF only if r
+// ends before the first byte of the message.
+func readDelimited(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading message size: %v", err)
+	}
+	if n > maxWorkMessageSize {
+		return nil, fmt.Errorf("message size %d exceeds limit", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeDelimited(w io.Writer, data []byte) error {
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(data)))
+	if _, err := w.Write(append(size[:n:n], data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.buf = append(e.buf, b[:n]...)
+}
+
+func (e *protoEncoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *protoEncoder) int32Field(field int, v int32) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	// Negative values are sign extended to 64 bits.
+	e.varint(uint64(int64(v)))
+}
+
+func (e *protoEncoder) boolField(field int, v bool) {
+	if !v {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(1)
+}
+
+func (e *protoEncoder) bytesField(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *protoEncoder) stringField(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytesField(field, []byte(v))
+}
+
+type protoDecoder struct {
+	data []byte
+}
+
+var errTruncatedMessage = errors.New("truncated message")
+
+func (d *protoDecoder) done() bool {
+	return len(d.data) == 0
+}
+
+func (d *protoDecoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, errTruncatedMessage
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *protoDecoder) tag() (field, wireType int, err error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 7), nil
+}
+
+func (d *protoDecoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		return nil, errTruncatedMessage
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v, nil
+}
+
+// skip discards the value of a field that is not known to the builder.
+func (d *protoDecoder) skip(wireType int) error {
+	var n int
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireI64:
+		n = 8
+	case wireI32:
+		n = 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	if len(d.data) < n {
+		return errTruncatedMessage
+	}
+	d.data = d.data[n:]
+	return nil
+}
+
+func unmarshalWorkRequest(data []byte) (*workRequest, error) {
+	req := &workRequest{}
+	d := &protoDecoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case (field == workRequestArguments || field == workRequestInputs || field == workRequestSandboxDir) && wireType == wireBytes:
+			v, err := d.bytes()
+			if err != nil {
+				return nil, err
+			}
+			switch field {
+			case workRequestArguments:
+				req.Arguments = append(req.Arguments, string(v))
+			case workRequestInputs:
+				input, err := unmarshalWorkInput(v)
+				if err != nil {
+					return nil, err
+				}
+				req.Inputs = append(req.Inputs, input)
+			case workRequestSandboxDir:
+				req.SandboxDir = string(v)
+			}
+		case (field == workRequestRequestID || field == workRequestCancel || field == workRequestVerbosity) && wireType == wireVarint:
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			switch field {
+			case workRequestRequestID:
+				req.RequestID = int32(v)
+			case workRequestCancel:
+				req.Cancel = v != 0
+			case workRequestVerbosity:
+				req.Verbosity = int32(v)
+			}
+		default:
+			if err := d.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return req, nil
+}
+
+func unmarshalWorkInput(data []byte) (workInput, error) {
+	var input workInput
+	d := &protoDecoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.tag()
+		if err != nil {
+			return input, err
+		}
+		if (field != inputPath && field != inputDigest) || wireType != wireBytes {
+			if err := d.skip(wireType); err != nil {
+				return input, err
+			}
+			continue
+		}
+		v, err := d.bytes()
+		if err != nil {
+			return input, err
+		}
+		if field == inputPath {
+			input.Path = string(v)
+		} else {
+			input.Digest = append([]byte(nil), v...)
+		}
+	}
+	return input, nil
+}
+
+func marshalWorkRequest(req *workRequest) []byte {
+	e := &protoEncoder{}
+	for _, arg := range req.Arguments {
+		e.bytesField(workRequestArguments, []byte(arg))
+	}
+	for _, input := range req.Inputs {
+		ie := &protoEncoder{}
+		ie.stringField(inputPath, input.Path)
+		if len(input.Digest) > 0 {
+			ie.bytesField(inputDigest, input.Digest)
+		}
+		e.bytesField(workRequestInputs, ie.buf)
+	}
+	e.int32Field(workRequestRequestID, req.RequestID)
+	e.boolField(workRequestCancel, req.Cancel)
+	e.int32Field(workRequestVerbosity, req.Verbosity)
+	e.stringField(workRequestSandboxDir, req.SandboxDir)
+	return e.buf
+}
+
+func marshalWorkResponse(resp *workResponse) []byte {
+	e := &protoEncoder{}
+	e.int32Field(workResponseExitCode, resp.ExitCode)
+	e.stringField(workResponseOutput, resp.Output)
+	e.int32Field(workResponseRequestID, resp.RequestID)
+	e.boolField(workResponseWasCancelled, resp.WasCancelled)
+	return e.buf
+}
+
+func unmarshalWorkResponse(data []byte) (*workResponse, error) {
+	resp := &workResponse{}
+	d := &protoDecoder{data: data}
+	for !d.done() {
+		field, wireType, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case field == workResponseOutput && wireType == wireBytes:
+			v, err := d.bytes()
+			if err != nil {
+				return nil, err
+			}
+			resp.Output = string(v)
+		case (field == workResponseExitCode || field == workResponseRequestID || field == workResponseWasCancelled) && wireType == wireVarint:
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			switch field {
+			case workResponseExitCode:
+				resp.ExitCode = int32(v)
+			case workResponseRequestID:
+				resp.RequestID = int32(v)
+			case workResponseWasCancelled:
+				resp.WasCancelled = v != 0
+			}
+		default:
+			if err := d.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWorkRequestProtoRoundTrip(t *testing.T) {
+	want := &workRequest{
+		Arguments: []string{"compilepkg", "-p", "example.com/a", ""},
+		Inputs: []workInput{
+			{Path: "a.go", Digest: []byte{1, 2, 3}},
+			{Path: "b.go"},
+		},
+		RequestID:  -7,
+		Cancel:     true,
+		Verbosity:  10,
+		SandboxDir: "sandbox/1",
+	}
+	var buf bytes.Buffer
+	if err := writeDelimited(&buf, marshalWorkRequest(want)); err != nil {
+		t.Fatal(err)
+	}
+	conn := newProtoWorkerConn(&buf, io.Discard)
+	got, err := conn.readRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if _, err := conn.readRequest(); err != io.EOF {
+		t.Errorf("got error %v after last request, want io.EOF", err)
+	}
+}
+
+func TestWorkResponseProtoRoundTrip(t *testing.T) {
+	for _, want := range []*workResponse{
+		{},
+		{ExitCode: 1, Output: "compilepkg: error\n", RequestID: 3},
+		{ExitCode: -1, RequestID: 1 << 30, WasCancelled: true},
+	} {
+		var buf bytes.Buffer
+		if err := newProtoWorkerConn(nil, &buf).writeResponse(want); err != nil {
+			t.Fatal(err)
+		}
+		data, err := readDelimited(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := unmarshalWorkResponse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestWorkRequestProtoUnknownFields(t *testing.T) {
+	e := &protoEncoder{}
+	e.bytesField(workRequestArguments, []byte("link"))
+	e.tag(20, wireVarint)
+	e.varint(300)
+	e.tag(21, wireI32)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	e.tag(22, wireI64)
+	e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	e.bytesField(23, []byte("ignored"))
+	e.int32Field(workRequestRequestID, 5)
+
+	got, err := unmarshalWorkRequest(e.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &workRequest{Arguments: []string{"link"}, RequestID: 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	if _, err := unmarshalWorkRequest(e.buf[:len(e.buf)-1]); err == nil {
+		t.Error("unexpected success decoding a truncated request")
+	}
+}
+
+func TestPersistentWorkerArgs(t *testing.T) {
+	if _, ok := persistentWorkerArgs([]string{"compilepkg", "-p", "a"}); ok {
+		t.Error("got worker mode without --persistent_worker")
+	}
+	got, ok := persistentWorkerArgs([]string{"compilepkg", "--persistent_worker", "-v"})
+	if !ok {
+		t.Fatal("got no worker mode with --persistent_worker")
+	}
+	if want := []string{"compilepkg", "-v"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got startup args %q, want %q", got, want)
+	}
+}
+
+func TestRunIsolated(t *testing.T) {
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outDir, nogoLogBasename), []byte("a.go:1:1: finding\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	validation := filepath.Join(outDir, "validation")
+	const envKey = "RULES_GO_WORKER_TEST"
+	os.Setenv(envKey, "before")
+	defer os.Unsetenv(envKey)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+
+	exitCode, output := runIsolated([]string{"nogovalidation", validation, outDir})
+	if exitCode != 1 {
+		t.Errorf("got exit code %d, want 1", exitCode)
+	}
+	if !strings.Contains(output, "a.go:1:1: finding") {
+		t.Errorf("output does not contain the nogo findings:\n%s", output)
+	}
+	if _, err := os.Stat(validation); err != nil {
+		t.Errorf("validation output was not written: %v", err)
+	}
+
+	exitCode, output = runIsolated([]string{"nosuchverb"})
+	if exitCode != 1 {
+		t.Errorf("got exit code %d, want 1", exitCode)
+	}
+	if want := "builder: unknown action: nosuchverb\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+
+	if got := os.Getenv(envKey); got != "before" {
+		t.Errorf("environment was not restored: got %s=%q", envKey, got)
+	}
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("working directory was not restored: got %s, want %s", got, wd)
+	}
+	if os.Stdout != stdout || os.Stderr != stderr {
+		t.Error("standard streams were not restored")
+	}
+}
+
+func TestRestoreEnviron(t *testing.T) {
+	environ := os.Environ()
+	defer restoreEnviron(environ)
+
+	os.Setenv("RULES_GO_WORKER_ADDED", "1")
+	if err := restoreEnviron(append(environ, "RULES_GO_WORKER_VALUE=a=b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := os.LookupEnv("RULES_GO_WORKER_ADDED"); ok {
+		t.Error("variable set after the snapshot was not removed")
+	}
+	if got := os.Getenv("RULES_GO_WORKER_VALUE"); got != "a=b" {
+		t.Errorf("got RULES_GO_WORKER_VALUE=%q, want %q", got, "a=b")
+	}
+}
+
+// fakeWorkerChild returns a child that echoes the arguments of each request.
+// Requests fail if they are not singleplex requests.
+func fakeWorkerChild() *workerChild {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go func() {
+		dec, enc := json.NewDecoder(reqR), json.NewEncoder(respW)
+		for {
+			var req workRequest
+			if err := dec.Decode(&req); err != nil {
+				respW.CloseWithError(err)
+				return
+			}
+			resp := &workResponse{Output: strings.Join(req.Arguments, " ")}
+			if req.RequestID != 0 {
+				resp.ExitCode = 1
+			}
+			enc.Encode(resp)
+		}
+	}()
+	return &workerChild{
+		enc:  json.NewEncoder(reqW),
+		dec:  json.NewDecoder(respR),
+		stop: reqW.Close,
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	var mu sync.Mutex
+	started := 0
+	pool := &workerPool{start: func() (*workerChild, error) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		return fakeWorkerChild(), nil
+	}}
+	defer pool.close()
+
+	const n = 4
+	run := func() {
+		var wg sync.WaitGroup
+		for i := 1; i <= n; i++ {
+			wg.Add(1)
+			go func(id int32) {
+				defer wg.Done()
+				resp := pool.do(&workRequest{Arguments: []string{"link", fmt.Sprint(id)}, RequestID: id})
+				want := &workResponse{Output: fmt.Sprintf("link %d", id), RequestID: id}
+				if !reflect.DeepEqual(resp, want) {
+					t.Errorf("got %#v, want %#v", resp, want)
+				}
+			}(int32(i))
+		}
+		wg.Wait()
+	}
+	run()
+	run()
+	if started < 1 || started > n {
+		t.Errorf("started %d child workers, want between 1 and %d", started, n)
+	}
+	if len(pool.idle) != started {
+		t.Errorf("got %d idle child workers, want %d", len(pool.idle), started)
+	}
+}
+
+func TestWorkerPool_max(t *testing.T) {
+	var mu sync.Mutex
+	started := 0
+	pool := &workerPool{max: 2, start: func() (*workerChild, error) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		return fakeWorkerChild(), nil
+	}}
+	defer pool.close()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 16; i++ {
+		wg.Add(1)
+		go func(id int32) {
+			defer wg.Done()
+			if resp := pool.do(&workRequest{Arguments: []string{"link"}, RequestID: id}); resp.ExitCode != 0 {
+				t.Errorf("got %#v, want success", resp)
+			}
+		}(int32(i))
+	}
+	wg.Wait()
+	if started > 2 {
+		t.Errorf("started %d child workers, want at most 2", started)
+	}
+}
+
+func TestNogoWorkerSet(t *testing.T) {
+	started := 0
+	set := &nogoWorkerSet{start: func(nogoPath, dir string, env []string) (*workerChild, error) {
+		started++
+		return fakeWorkerChild(), nil
+	}}
+	defer set.close()
+
+	run := func() {
+		t.Helper()
+		exitCode, out, err := set.run("nogo", []string{"-param=nogo.param"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exitCode != 0 || string(out) != "-param=nogo.param" {
+			t.Errorf("got exit code %d and output %q, want 0 and %q", exitCode, out, "-param=nogo.param")
+		}
+	}
+	run()
+	run()
+	if started != 1 {
+		t.Errorf("started %d nogo workers for the same environment, want 1", started)
+	}
+
+	const envKey = "RULES_GO_NOGO_WORKER_TEST"
+	os.Setenv(envKey, "1")
+	defer os.Unsetenv(envKey)
+	run()
+	if started != 2 {
+		t.Errorf("started %d nogo workers for two environments, want 2", started)
+	}
+}
+
+func TestRunWorkerJSON(t *testing.T) {
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+
+	var in, out bytes.Buffer
+	enc := json.NewEncoder(&in)
+	enc.Encode(&workRequest{Arguments: []string{"'no such verb'"}})
+	enc.Encode(&workRequest{Arguments: []string{"ignored"}, Cancel: true, RequestID: 1})
+	if err := runWorker(&in, &out, nil, workerProtocolJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&out)
+	var resp workResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := workResponse{ExitCode: 1, Output: "builder: unknown action: no such verb\n"}
+	if resp != want {
+		t.Errorf("got %#v, want %#v", resp, want)
+	}
+	if dec.More() {
+		t.Error("got a response to a cancel request")
+	}
+}
+
+func TestHandleWorkRequest_sandboxDir(t *testing.T) {
+	resp := handleWorkRequest(nil, &workRequest{Arguments: []string{"link"}, RequestID: 1, SandboxDir: "sandbox/1"})
+	want := &workResponse{
+		ExitCode:  1,
+		Output:    "builder: multiplex sandboxing is not supported, but the request has sandbox directory \"sandbox/1\"\n",
+		RequestID: 1,
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %#v, want %#v", resp, want)
+	}
+}
+
+func TestServeWorkRequests_singleplexOnly(t *testing.T) {
+	var in, out bytes.Buffer
+	json.NewEncoder(&in).Encode(&workRequest{Arguments: []string{"a"}, RequestID: 1})
+	conn, err := newWorkerConn(workerProtocolJSON, &in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	singleplex := func(req *workRequest) *workResponse {
+		t.Errorf("got singleplex request %#v", req)
+		return &workResponse{}
+	}
+	if err := serveWorkRequests(conn, singleplex, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp workResponse
+	if err := json.NewDecoder(&out).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := workResponse{ExitCode: 1, Output: "multiplex work requests are not supported\n", RequestID: 1}
+	if resp != want {
+		t.Errorf("got %#v, want %#v", resp, want)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// benchmarkConfigKeys are the configuration lines printed by the testing
+// package before the results of benchmarks.
+var benchmarkConfigKeys = []string{"goos", "goarch", "pkg", "cpu"}
+
+// benchmarkResult is a benchmark result line like
+// "BenchmarkEncode/small-8  1000  1234 ns/op  56 B/op  2 allocs/op".
+type benchmarkResult struct {
+	name       string // the name without the -GOMAXPROCS suffix
+	procs      int
+	iterations int
+	metrics    []benchmarkMetric
+}
+
+type benchmarkMetric struct {
+	value float64
+	unit  string
+}
+
+// parseBenchmarkResult parses a benchmark result line in the format
+// understood by benchstat, or returns ok == false if line isn't one.
+func parseBenchmarkResult(line string) (r benchmarkResult, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !isBenchmarkName([]byte(fields[0])) {
+		return benchmarkResult{}, false
+	}
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil || iterations <= 0 {
+		return benchmarkResult{}, false
+	}
+	r = benchmarkResult{name: fields[0], procs: 1, iterations: iterations}
+	if i := strings.LastIndex(r.name, "-"); i >= 0 {
+		if procs, err := strconv.Atoi(r.name[i+1:]); err == nil && procs > 0 {
+			r.name, r.procs = r.name[:i], procs
+		}
+	}
+	for i := 2; i < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return benchmarkResult{}, false
+		}
+		r.metrics = append(r.metrics, benchmarkMetric{value: value, unit: fields[i+1]})
+	}
+	return r, true
+}
+
+// benchmarkOutput collects the benchmark results printed by a test.
+type benchmarkOutput struct {
+	pkg     string
+	config  map[string]string
+	text    bytes.Buffer // the results in the format understood by benchstat
+	results []benchmarkResult
+}
+
+// parseBenchmarks collects the benchmark results and configuration lines
+// from the test2json output of the runs of a test binary.
+func parseBenchmarks(attempts []io.Reader, pkg string) (*benchmarkOutput, error) {
+	b := &benchmarkOutput{pkg: pkg, config: make(map[string]string)}
+	for _, r := range attempts {
+		// Benchmark result lines may be split over several output events,
+		// since the name is printed before the benchmark runs.
+		var output strings.Builder
+		dec := json.NewDecoder(r)
+		for {
+			var e jsonEvent
+			if err := dec.Decode(&e); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("error decoding test2json output: %s", err)
+			}
+			if e.Action == "output" {
+				output.WriteString(e.Output)
+			}
+		}
+		for _, line := range strings.Split(output.String(), "\n") {
+			b.addLine(strings.TrimRight(strings.TrimLeft(line, string(marker)), "\r"))
+		}
+	}
+	return b, nil
+}
+
+func (b *benchmarkOutput) addLine(line string) {
+	for _, key := range benchmarkConfigKeys {
+		if strings.HasPrefix(line, key+": ") {
+			b.setConfig(key, strings.TrimSpace(line[len(key)+2:]))
+			return
+		}
+	}
+	r, ok := parseBenchmarkResult(line)
+	if !ok {
+		return
+	}
+	if _, ok := b.config["pkg"]; !ok {
+		// The testing package only prints the package when run by go test.
+		b.setConfig("pkg", b.pkg)
+	}
+	b.text.WriteString(line)
+	b.text.WriteByte('\n')
+	b.results = append(b.results, r)
+}
+
+// setConfig sets a configuration value, which applies to the results that
+// follow it.
+func (b *benchmarkOutput) setConfig(key, value string) {
+	if old, ok := b.config[key]; ok && old == value {
+		return
+	}
+	b.config[key] = value
+	fmt.Fprintf(&b.text, "%s: %s\n", key, value)
+}
+
+type benchmarkSummary struct {
+	Config     map[string]string        `json:"config"`
+	Benchmarks []benchmarkSummaryResult `json:"benchmarks"`
+}
+
+type benchmarkSummaryResult struct {
+	Name       string                   `json:"name"`
+	Procs      int                      `json:"procs"`
+	Runs       int                      `json:"runs"`
+	Iterations []int                    `json:"iterations"`
+	Metrics    []benchmarkSummaryMetric `json:"metrics"`
+}
+
+type benchmarkSummaryMetric struct {
+	Unit   string    `json:"unit"`
+	Values []float64 `json:"values"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Mean   float64   `json:"mean"`
+	Median float64   `json:"median"`
+}
+
+// summary groups the results of runs of the same benchmark, in the order
+// in which the benchmarks first ran.
+func (b *benchmarkOutput) summary() benchmarkSummary {
+	s := benchmarkSummary{Config: b.config, Benchmarks: []benchmarkSummaryResult{}}
+	index := make(map[string]int)
+	for _, r := range b.results {
+		key := r.name + "-" + strconv.Itoa(r.procs)
+		i, ok := index[key]
+		if !ok {
+			i = len(s.Benchmarks)
+			index[key] = i
+			s.Benchmarks = append(s.Benchmarks, benchmarkSummaryResult{Name: r.name, Procs: r.procs})
+		}
+		sr := &s.Benchmarks[i]
+		sr.Runs++
+		sr.Iterations = append(sr.Iterations, r.iterations)
+	metrics:
+		for _, m := range r.metrics {
+			for j := range sr.Metrics {
+				if sr.Metrics[j].Unit == m.unit {
+					sr.Metrics[j].Values = append(sr.Metrics[j].Values, m.value)
+					continue metrics
+				}
+			}
+			sr.Metrics = append(sr.Metrics, benchmarkSummaryMetric{Unit: m.unit, Values: []float64{m.value}})
+		}
+	}
+	for i := range s.Benchmarks {
+		for j := range s.Benchmarks[i].Metrics {
+			m := &s.Benchmarks[i].Metrics[j]
+			sorted := append([]float64(nil), m.Values...)
+			sort.Float64s(sorted)
+			var sum float64
+			for _, v := range sorted {
+				sum += v
+			}
+			m.Min, m.Max = sorted[0], sorted[len(sorted)-1]
+			m.Mean = sum / float64(len(sorted))
+			if n := len(sorted); n%2 == 1 {
+				m.Median = sorted[n/2]
+			} else {
+				m.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+			}
+		}
+	}
+	return s
+}
+
+// benchmarkOutputDir returns the directory the benchmark results of the test
+// are written to, or "" if they should not be written. By default, they are
+// written to the undeclared outputs of the test.
+func benchmarkOutputDir() string {
+	if dir, ok := os.LookupEnv("GO_TEST_WRAP_BENCHMARK_OUTPUT_DIR"); ok {
+		return dir
+	}
+	return os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR")
+}
+
+// writeBenchmarks writes the benchmark results of all runs of the test
+// binary to benchmarks.txt, in the format understood by benchstat, and a
+// summary of them to benchmarks.json in dir. Nothing is written if no
+// benchmarks ran.
+func writeBenchmarks(attempts []*bytes.Buffer, pkg string, dir string) error {
+	readers := make([]io.Reader, len(attempts))
+	for i, a := range attempts {
+		readers[i] = bytes.NewReader(a.Bytes())
+	}
+	b, err := parseBenchmarks(readers, pkg)
+	if err != nil {
+		return err
+	}
+	if len(b.results) == 0 {
+		return nil
+	}
+	summary, err := json.MarshalIndent(b.summary(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "benchmarks.txt"), b.text.Bytes(), 0664); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "benchmarks.json"), append(summary, '\n'), 0664)
+}
@@ -0,0 +1,176 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseBenchmarkResult(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want *benchmarkResult
+	}{
+		{
+			line: "BenchmarkEncode/small-8   \t    1000\t      1234 ns/op\t      56 B/op\t       2 allocs/op",
+			want: &benchmarkResult{name: "BenchmarkEncode/small", procs: 8, iterations: 1000, metrics: []benchmarkMetric{
+				{1234, "ns/op"}, {56, "B/op"}, {2, "allocs/op"},
+			}},
+		},
+		{
+			line: "BenchmarkA \t     100\t         4.390 ns/op\t 1.5 MB/s",
+			want: &benchmarkResult{name: "BenchmarkA", procs: 1, iterations: 100, metrics: []benchmarkMetric{
+				{4.39, "ns/op"}, {1.5, "MB/s"},
+			}},
+		},
+		{line: "BenchmarkA"},
+		{line: "BenchmarkA \t     100"},
+		{line: "BenchmarkA \t     100\t 4.390"},
+		{line: "Benchmarking \t     100\t 4.390 ns/op"},
+		{line: "BenchmarkA \t     many\t 4.390 ns/op"},
+		{line: "BenchmarkA \t     100\t NaN ns/op"},
+		{line: "    bench_test.go:12: BenchmarkA 100 4.390 ns/op"},
+	} {
+		got, ok := parseBenchmarkResult(tc.line)
+		if tc.want == nil {
+			if ok {
+				t.Errorf("parseBenchmarkResult(%q) = %+v, want no result", tc.line, got)
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(got, *tc.want) {
+			t.Errorf("parseBenchmarkResult(%q) = %+v, %v, want %+v", tc.line, got, ok, *tc.want)
+		}
+	}
+}
+
+// convert returns the test2json events of output.
+func convert(t *testing.T, output ...string) *bytes.Buffer {
+	t.Helper()
+	var events bytes.Buffer
+	c := NewConverter(&events, "example.com/bench", 0)
+	for _, o := range output {
+		if _, err := c.Write([]byte(o)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &events
+}
+
+func TestWriteBenchmarks(t *testing.T) {
+	attempts := []*bytes.Buffer{
+		convert(t,
+			"goos: linux\ngoarch: amd64\n",
+			"=== RUN   TestA\n--- PASS: TestA (0.00s)\n",
+			// The name of a benchmark is printed before it runs.
+			"BenchmarkEncode-8   \t",
+			"    1000\t      1200 ns/op\t      56 B/op\t       2 allocs/op\n",
+			"--- BENCH: BenchmarkEncode-8\n    bench_test.go:12: log\n",
+			"BenchmarkEncode-8   \t    1000\t      1000 ns/op\t      56 B/op\t       2 allocs/op\n",
+			"BenchmarkFail-8   \t--- FAIL: BenchmarkFail-8\n",
+			"FAIL\n"),
+		convert(t,
+			"goos: linux\ngoarch: amd64\n",
+			"BenchmarkFail-8   \t    10\t      5 ns/op\t  3.000 things/op\n",
+			"PASS\n"),
+	}
+	dir := filepath.Join(t.TempDir(), "outputs")
+	if err := writeBenchmarks(attempts, "example.com/bench", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "benchmarks.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `goos: linux
+goarch: amd64
+pkg: example.com/bench
+BenchmarkEncode-8   	    1000	      1200 ns/op	      56 B/op	       2 allocs/op
+BenchmarkEncode-8   	    1000	      1000 ns/op	      56 B/op	       2 allocs/op
+BenchmarkFail-8   	    10	      5 ns/op	  3.000 things/op
+`
+	if string(got) != want {
+		t.Errorf("got benchmarks.txt:\n%s\nwant:\n%s", got, want)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "benchmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary benchmarkSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := benchmarkSummary{
+		Config: map[string]string{"goos": "linux", "goarch": "amd64", "pkg": "example.com/bench"},
+		Benchmarks: []benchmarkSummaryResult{
+			{
+				Name: "BenchmarkEncode", Procs: 8, Runs: 2, Iterations: []int{1000, 1000},
+				Metrics: []benchmarkSummaryMetric{
+					{Unit: "ns/op", Values: []float64{1200, 1000}, Min: 1000, Max: 1200, Mean: 1100, Median: 1100},
+					{Unit: "B/op", Values: []float64{56, 56}, Min: 56, Max: 56, Mean: 56, Median: 56},
+					{Unit: "allocs/op", Values: []float64{2, 2}, Min: 2, Max: 2, Mean: 2, Median: 2},
+				},
+			},
+			{
+				Name: "BenchmarkFail", Procs: 8, Runs: 1, Iterations: []int{10},
+				Metrics: []benchmarkSummaryMetric{
+					{Unit: "ns/op", Values: []float64{5}, Min: 5, Max: 5, Mean: 5, Median: 5},
+					{Unit: "things/op", Values: []float64{3}, Min: 3, Max: 3, Mean: 3, Median: 3},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(summary, wantSummary) {
+		t.Errorf("got summary:\n%s\nwant:\n%+v", data, wantSummary)
+	}
+}
+
+func TestWriteBenchmarksWithoutBenchmarks(t *testing.T) {
+	dir := t.TempDir()
+	attempts := []*bytes.Buffer{convert(t, "=== RUN   TestA\n--- PASS: TestA (0.00s)\nPASS\n")}
+	if err := writeBenchmarks(attempts, "example.com/bench", dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "benchmarks.txt")); !os.IsNotExist(err) {
+		t.Errorf("got %v, want benchmarks.txt to not exist", err)
+	}
+}
+
+func TestBenchmarkOutputDir(t *testing.T) {
+	t.Setenv("TEST_UNDECLARED_OUTPUTS_DIR", "/outputs")
+	os.Unsetenv("GO_TEST_WRAP_BENCHMARK_OUTPUT_DIR")
+	if got := benchmarkOutputDir(); got != "/outputs" {
+		t.Errorf("got %q, want /outputs", got)
+	}
+	t.Setenv("GO_TEST_WRAP_BENCHMARK_OUTPUT_DIR", "/bench")
+	if got := benchmarkOutputDir(); got != "/bench" {
+		t.Errorf("got %q, want /bench", got)
+	}
+	t.Setenv("GO_TEST_WRAP_BENCHMARK_OUTPUT_DIR", "")
+	if got := benchmarkOutputDir(); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
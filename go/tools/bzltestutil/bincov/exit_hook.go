@@ -19,9 +19,14 @@ import (
 	"cmd/internal/cov"
 	"fmt"
 	"internal/runtime/exithook"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime/coverage"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/bazelbuild/rules_go/go/tools/bzltestutil"
 )
@@ -29,9 +34,22 @@ import (
 // Lock in the COVERAGE_DIR during test setup in case the test uses e.g. os.Clearenv.
 var coverageDir = os.Getenv("COVERAGE_DIR")
 
+// binaryCoverageDir is the directory coverage is written to by binaries that
+// run outside of `bazel coverage`, for example servers started by an
+// integration test.
+var binaryCoverageDir = os.Getenv("GO_BINARY_COVERAGE_DIR")
+
+// processID identifies the coverage files of this process. Every snapshot
+// overwrites the files of the previous one, so that merging all files in a
+// directory counts each process once.
+var processID = strconv.Itoa(os.Getpid()) + "_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// writeMu serializes snapshots taken by signal handlers and the exit hook.
+var writeMu sync.Mutex
+
 func AddExitHook() {
-	if coverageDir == "" {
-		log.Printf("Not collecting coverage: COVERAGE_DIR is not set")
+	if coverageDir == "" && binaryCoverageDir == "" {
+		log.Printf("Not collecting coverage: neither COVERAGE_DIR nor GO_BINARY_COVERAGE_DIR is set")
 		return
 	}
 
@@ -49,37 +67,103 @@ func AddExitHook() {
 
 	exithook.Add(exithook.Hook{
 		F: func() {
-			dir, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "coverage")
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "create temp dir for coverage: %v\n", err)
-				return
+			if err := WriteCoverage(); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
 			}
+		},
+		RunOnFailure: true,
+	})
+	// Binaries under `bazel coverage` run as tests, which don't need to
+	// handle signals.
+	if binaryCoverageDir != "" {
+		handleSignals()
+	}
+}
 
-			if err := coverage.WriteMetaDir(dir); err != nil {
-				fmt.Fprintf(os.Stderr, "write meta: %v\n", err)
-				return
-			}
-			if err := coverage.WriteCountersDir(dir); err != nil {
-				fmt.Fprintf(os.Stderr, "write counters: %v\n", err)
-				return
-			}
+// WriteCoverage writes a snapshot of the coverage counters of the binary.
+// It may be called at any time, for example from an HTTP handler, and
+// replaces the previous snapshot of the process.
+//
+// Under `bazel coverage`, the snapshot is written to COVERAGE_DIR as lcov.
+// If GO_BINARY_COVERAGE_DIR is set, it is also written there both as lcov
+// (go_coverage.<id>.dat) and as a go coverprofile (go_coverage.<id>.out).
+// These files can be merged with the covmerge tool.
+func WriteCoverage() error {
+	if coverageDir == "" && binaryCoverageDir == "" {
+		return nil
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
 
-			buf := new(bytes.Buffer)
-			visitor := makeVisitor(buf)
+	dir, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "coverage")
+	if err != nil {
+		return fmt.Errorf("create temp dir for coverage: %v", err)
+	}
+	defer os.RemoveAll(dir)
 
-			verbosityLevel := 0
-			var flags cov.CovDataReaderFlags
-			reader := cov.MakeCovDataReader(visitor, []string{dir}, verbosityLevel, flags, nil)
-			if err := reader.Visit(); err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				return
-			}
+	if err := coverage.WriteMetaDir(dir); err != nil {
+		return fmt.Errorf("write meta: %v", err)
+	}
+	if err := coverage.WriteCountersDir(dir); err != nil {
+		return fmt.Errorf("write counters: %v", err)
+	}
 
-			if err := bzltestutil.ConvertCoverFromReaderToLcov(buf); err != nil {
-				fmt.Fprintf(os.Stderr, "converting to lcov: %v\n", err)
-				return
-			}
-		},
-		RunOnFailure: true,
-	})
+	profile, err := readProfile(dir)
+	if err != nil {
+		return err
+	}
+
+	lcov := new(bytes.Buffer)
+	if err := bzltestutil.WriteLcov(bytes.NewReader(profile.Bytes()), lcov); err != nil {
+		return fmt.Errorf("converting to lcov: %v", err)
+	}
+
+	name := "go_coverage." + processID
+	if coverageDir != "" {
+		// All *.dat files in $COVERAGE_DIR will be merged by Bazel's lcov_merger tool.
+		if err := writeFileAtomically(filepath.Join(coverageDir, name+".dat"), lcov.Bytes()); err != nil {
+			return err
+		}
+	}
+	if binaryCoverageDir != "" {
+		if err := os.MkdirAll(binaryCoverageDir, 0777); err != nil {
+			return fmt.Errorf("create coverage dir: %v", err)
+		}
+		if err := writeFileAtomically(filepath.Join(binaryCoverageDir, name+".dat"), lcov.Bytes()); err != nil {
+			return err
+		}
+		if err := writeFileAtomically(filepath.Join(binaryCoverageDir, name+".out"), profile.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProfile converts the coverage data files in dir to a go coverprofile.
+func readProfile(dir string) (*bytes.Buffer, error) {
+	profile := new(bytes.Buffer)
+	visitor := makeVisitor(profile)
+
+	verbosityLevel := 0
+	var flags cov.CovDataReaderFlags
+	reader := cov.MakeCovDataReader(visitor, []string{dir}, verbosityLevel, flags, nil)
+	if err := reader.Visit(); err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	return profile, nil
+}
+
+// writeFileAtomically replaces the file at path with data, so that a
+// process that is killed while writing a snapshot leaves the previous one
+// intact.
+func writeFileAtomically(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return fmt.Errorf("write coverage: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write coverage: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,218 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bincov
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"internal/coverage/cfile"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/coverage"
+	"strconv"
+	"strings"
+
+	"github.com/bazelbuild/rules_go/go/tools/bzltestutil"
+)
+
+// perTestCoverageFile is the file in the undeclared outputs of a test that
+// the coverage of its individual tests is written to.
+const perTestCoverageFile = "per_test_coverage.dat"
+
+// perTestState tracks the coverage of the individual tests of a test binary.
+// Between tests, the counters are written to the directory that the testing
+// package merges into the coverage report of the whole binary, and then
+// cleared, so that the counters of a test only contain its own coverage.
+var perTestState struct {
+	coverDir string   // the -test.gocoverdir of the binary
+	tmpDir   string   // holds a subdirectory with the coverage of each test
+	tests    []string // the tests in the order in which they ran
+}
+
+// PerTestCoverage reports whether the coverage of each top-level test should
+// be recorded separately, which is requested with GO_TEST_PER_TEST_COVERAGE.
+func PerTestCoverage() (bool, error) {
+	env, ok := os.LookupEnv("GO_TEST_PER_TEST_COVERAGE")
+	if !ok {
+		return false, nil
+	}
+	perTest, err := strconv.ParseBool(env)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for GO_TEST_PER_TEST_COVERAGE: %q", env)
+	}
+	return perTest, nil
+}
+
+// BeginTest is called before a top-level test runs. It moves the coverage
+// collected so far, for example by init functions, out of the counters.
+func BeginTest() error {
+	return snapshotTest("")
+}
+
+// EndTest is called after the top-level test name returns and records the
+// coverage collected since BeginTest as its coverage.
+//
+// Top-level tests that call t.Parallel run alongside each other, so their
+// coverage is attributed to the test that ends first.
+func EndTest(name string) error {
+	return snapshotTest(name)
+}
+
+func snapshotTest(name string) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	s := &perTestState
+	if s.coverDir == "" {
+		// Tests only run after the flags have been parsed, so this is the
+		// directory used by the testing package unless it is unset.
+		f := flag.Lookup("test.gocoverdir")
+		if f == nil {
+			return fmt.Errorf("per-test coverage: -test.gocoverdir is not defined")
+		}
+		s.coverDir = f.Value.String()
+		if s.coverDir == "" {
+			dir, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "gocoverdir")
+			if err != nil {
+				return fmt.Errorf("per-test coverage: %v", err)
+			}
+			if err := f.Value.Set(dir); err != nil {
+				return fmt.Errorf("per-test coverage: %v", err)
+			}
+			s.coverDir = dir
+		}
+		dir, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "pertestcoverage")
+		if err != nil {
+			return fmt.Errorf("per-test coverage: %v", err)
+		}
+		s.tmpDir = dir
+		// Test binaries only prepare the coverage meta-data when they exit,
+		// and the counters can't be written before. Processing a scratch
+		// directory prepares it like at the end of the tests.
+		if err := cfile.ProcessCoverTestDir(dir, "", "atomic", "", io.Discard, nil); err != nil {
+			return fmt.Errorf("per-test coverage: %v", err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("per-test coverage: %v", err)
+		}
+		if err := os.Mkdir(dir, 0777); err != nil {
+			return fmt.Errorf("per-test coverage: %v", err)
+		}
+	}
+
+	if err := coverage.WriteCountersDir(s.coverDir); err != nil {
+		return fmt.Errorf("per-test coverage: write counters: %v", err)
+	}
+	if name != "" {
+		dir := filepath.Join(s.tmpDir, strconv.Itoa(len(s.tests)))
+		if err := os.Mkdir(dir, 0777); err != nil {
+			return fmt.Errorf("per-test coverage: %v", err)
+		}
+		if err := coverage.WriteMetaDir(dir); err != nil {
+			return fmt.Errorf("per-test coverage: write meta: %v", err)
+		}
+		if err := coverage.WriteCountersDir(dir); err != nil {
+			return fmt.Errorf("per-test coverage: write counters: %v", err)
+		}
+		s.tests = append(s.tests, name)
+	}
+	if err := coverage.ClearCounters(); err != nil {
+		return fmt.Errorf("per-test coverage: clear counters: %v", err)
+	}
+	return nil
+}
+
+// WritePerTestCoverage appends the coverage of the tests that ran to
+// per_test_coverage.dat in the undeclared outputs of the test, as an lcov
+// report with a TN record naming the test before each source file. Only the
+// source files covered by a test are listed for it.
+func WritePerTestCoverage() error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	s := &perTestState
+	if s.tmpDir == "" {
+		return nil
+	}
+	defer os.RemoveAll(s.tmpDir)
+	outputsDir := os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR")
+	if outputsDir == "" {
+		return nil
+	}
+
+	var lcov bytes.Buffer
+	for i, name := range s.tests {
+		profile, err := readProfile(filepath.Join(s.tmpDir, strconv.Itoa(i)))
+		if err != nil {
+			return fmt.Errorf("per-test coverage of %s: %v", name, err)
+		}
+		var testLcov bytes.Buffer
+		if err := bzltestutil.WriteLcov(coveredFiles(profile), &testLcov); err != nil {
+			return fmt.Errorf("per-test coverage of %s: %v", name, err)
+		}
+		for _, line := range strings.SplitAfter(testLcov.String(), "\n") {
+			if strings.HasPrefix(line, "SF:") {
+				fmt.Fprintf(&lcov, "TN:%s\n", name)
+			}
+			lcov.WriteString(line)
+		}
+	}
+
+	if err := os.MkdirAll(outputsDir, 0777); err != nil {
+		return err
+	}
+	// The file is appended to since the test binary runs again when the
+	// test wrapper retries failed tests.
+	f, err := os.OpenFile(filepath.Join(outputsDir, perTestCoverageFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lcov.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// coveredFiles returns the lines of a go coverprofile that belong to files
+// with at least one covered block.
+func coveredFiles(profile *bytes.Buffer) *bytes.Buffer {
+	covered := make(map[string]bool)
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(profile.Bytes()))
+	for s.Scan() {
+		line := s.Text()
+		lines = append(lines, line)
+		i := strings.LastIndexByte(line, ':')
+		if i < 0 || strings.HasPrefix(line, "mode: ") {
+			continue
+		}
+		if !strings.HasSuffix(line, " 0") {
+			covered[line[:i]] = true
+		}
+	}
+	out := new(bytes.Buffer)
+	for _, line := range lines {
+		i := strings.LastIndexByte(line, ':')
+		if strings.HasPrefix(line, "mode: ") || i >= 0 && covered[line[:i]] {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package bincov
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames maps the names of the signals that can be used with
+// GO_BINARY_COVERAGE_SIGNALS and GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL, without
+// the "SIG" prefix, to the signals.
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// parseSignal parses a signal name like "SIGTERM" or "TERM", or a signal
+// number.
+func parseSignal(s string) (syscall.Signal, error) {
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// handleSignals writes coverage to GO_BINARY_COVERAGE_DIR on the signals
+// configured in the environment. Signals are only handled if asked for, since
+// programs may use them for other purposes or handle them themselves.
+//
+// GO_BINARY_COVERAGE_SIGNALS is a comma-separated list of signals, like
+// "SIGINT,SIGTERM", that would otherwise terminate the binary without running
+// the exit hook. After writing coverage, they are raised again without this
+// handler, so they terminate the binary as before. Programs that handle these
+// signals themselves exit normally and don't need this: they would receive
+// them a second time.
+//
+// GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL is a signal, like "SIGUSR1", on which a
+// snapshot is written without exiting.
+func handleSignals() {
+	terminating := make(map[os.Signal]bool)
+	var sigs []os.Signal
+	if names := os.Getenv("GO_BINARY_COVERAGE_SIGNALS"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			sig, err := parseSignal(strings.TrimSpace(name))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "GO_BINARY_COVERAGE_SIGNALS: %v\n", err)
+				continue
+			}
+			terminating[sig] = true
+			sigs = append(sigs, sig)
+		}
+	}
+	if name := os.Getenv("GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL"); name != "" {
+		if sig, err := parseSignal(name); err != nil {
+			fmt.Fprintf(os.Stderr, "GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL: %v\n", err)
+		} else if !terminating[sig] {
+			sigs = append(sigs, sig)
+		}
+	}
+	if len(sigs) == 0 {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		for sig := range c {
+			if err := WriteCoverage(); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+			if !terminating[sig] {
+				continue
+			}
+			signal.Stop(c)
+			syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+			return
+		}
+	}()
+}
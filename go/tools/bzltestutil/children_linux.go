@@ -0,0 +1,106 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// procStat is the part of /proc/<pid>/stat used to find child processes.
+type procStat struct {
+	pid, ppid, pgrp int
+	state           string
+	comm            string
+}
+
+// childProcesses returns the processes that are alive and are descendants of
+// the test binary or in its process group, except the test binary and its
+// ancestors.
+func childProcesses() ([]process, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[int]procStat)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// The process may have exited since /proc was read.
+		if s, ok := readProcStat(pid); ok {
+			stats[pid] = s
+		}
+	}
+
+	self := os.Getpid()
+	ancestors := make(map[int]bool)
+	for pid := os.Getppid(); pid > 0 && !ancestors[pid]; pid = stats[pid].ppid {
+		ancestors[pid] = true
+	}
+	var isDescendant func(pid int, depth int) bool
+	isDescendant = func(pid int, depth int) bool {
+		ppid := stats[pid].ppid
+		if ppid == self {
+			return true
+		}
+		// The depth guards against cycles from reused pids.
+		return ppid > 0 && depth < len(stats) && isDescendant(ppid, depth+1)
+	}
+	pgrp := syscall.Getpgrp()
+	var processes []process
+	for pid, s := range stats {
+		if pid == self || ancestors[pid] || s.state == "Z" {
+			continue
+		}
+		if s.pgrp != pgrp && !isDescendant(pid, 0) {
+			continue
+		}
+		command := s.comm
+		if cmdline, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline")); err == nil && len(cmdline) > 0 {
+			command = strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+		}
+		processes = append(processes, process{pid: pid, command: command})
+	}
+	return processes, nil
+}
+
+// readProcStat parses /proc/<pid>/stat and reports whether it succeeded.
+func readProcStat(pid int) (procStat, bool) {
+	data, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, false
+	}
+	// The command name is in parentheses and may contain spaces and
+	// parentheses itself, like "1234 (my (cmd)) S 1 1234 ...".
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return procStat{}, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 3 {
+		return procStat{}, false
+	}
+	s := procStat{pid: pid, state: fields[0], comm: "[" + stat[open+1:end] + "]"}
+	s.ppid, _ = strconv.Atoi(fields[1])
+	s.pgrp, _ = strconv.Atoi(fields[2])
+	return s, true
+}
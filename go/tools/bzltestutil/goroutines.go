@@ -0,0 +1,304 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goroutineDumpFile is the file in the undeclared outputs of a test that the
+// goroutines are written to when the test times out.
+const goroutineDumpFile = "goroutines.txt"
+
+// maxGroupIDs is the number of goroutine IDs listed for a group of goroutines
+// with the same stack.
+const maxGroupIDs = 20
+
+// timeoutDump is the output of a test binary after it timed out.
+type timeoutDump struct {
+	panic string // like "panic: test timed out after 8s"
+	// The tests that were running, with their durations, like
+	// "TestReport/test_3 (2s)".
+	running []string
+	// The names of the tests in running.
+	tests []string
+	text  strings.Builder // the goroutine dump
+}
+
+// goroutine is a goroutine in a goroutine dump.
+type goroutine struct {
+	id      int
+	state   string // like "chan receive", without the wait duration
+	minutes int    // how long the goroutine has been blocked
+	frames  []stackFrame
+}
+
+// stackFrame is a function call in the stack of a goroutine, or the
+// "created by" line that ends it.
+type stackFrame struct {
+	fn   string // the function with "(...)" in place of its arguments
+	file string // the file and line, without the PC offset
+}
+
+var (
+	goroutineHeaderRegexp = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[(.*)\]:$`)
+	waitMinutesRegexp     = regexp.MustCompile(`^(\d+) minutes?$`)
+	pcOffsetRegexp        = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+)
+
+// parseGoroutines returns the goroutines in a goroutine dump like the one
+// printed by the runtime when a test panics. Lines that are not part of a
+// goroutine's stack are ignored.
+func parseGoroutines(dump string) []goroutine {
+	var goroutines []goroutine
+	lines := strings.Split(dump, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := goroutineHeaderRegexp.FindStringSubmatch(strings.TrimRight(lines[i], "\r"))
+		if m == nil {
+			continue
+		}
+		g := goroutine{}
+		g.id, _ = strconv.Atoi(m[1])
+		var states []string
+		for _, s := range strings.Split(m[2], ", ") {
+			if wm := waitMinutesRegexp.FindStringSubmatch(s); wm != nil {
+				g.minutes, _ = strconv.Atoi(wm[1])
+			} else {
+				states = append(states, s)
+			}
+		}
+		g.state = strings.Join(states, ", ")
+		for i+1 < len(lines) {
+			line := strings.TrimRight(lines[i+1], "\r")
+			if strings.HasPrefix(line, "...") {
+				// Like "...additional frames elided...".
+				g.frames = append(g.frames, stackFrame{fn: line})
+				i++
+				continue
+			}
+			if line == "" || strings.HasPrefix(line, "\t") || i+2 >= len(lines) || !strings.HasPrefix(lines[i+2], "\t") {
+				break
+			}
+			file := pcOffsetRegexp.ReplaceAllString(strings.TrimSpace(lines[i+2]), "")
+			g.frames = append(g.frames, stackFrame{fn: frameFunction(line), file: file})
+			i += 2
+		}
+		goroutines = append(goroutines, g)
+	}
+	return goroutines
+}
+
+// frameFunction returns the function of a line of a stack, replacing its
+// arguments with "..." and removing the goroutine that created it from a
+// "created by" line.
+func frameFunction(line string) string {
+	if strings.HasPrefix(line, "created by ") {
+		if i := strings.Index(line, " in goroutine "); i >= 0 {
+			return line[:i]
+		}
+		return line
+	}
+	if strings.HasSuffix(line, ")") && !strings.HasSuffix(line, "()") {
+		if i := strings.LastIndex(line, "("); i >= 0 {
+			return line[:i] + "(...)"
+		}
+	}
+	return line
+}
+
+// runsTest reports whether g is running a test, as opposed to waiting for
+// its subtests or for the parallel tests to start.
+func (g goroutine) runsTest() bool {
+	runsTest := false
+	for _, f := range g.frames {
+		switch {
+		case strings.HasPrefix(f.fn, "testing.(*T).Run("), strings.HasPrefix(f.fn, "testing.(*T).Parallel("):
+			return false
+		case strings.HasPrefix(f.fn, "testing.tRunner("):
+			runsTest = true
+		}
+	}
+	return runsTest
+}
+
+// goroutineGroup is a set of goroutines with the same state and stack.
+type goroutineGroup struct {
+	state                  string
+	minMinutes, maxMinutes int
+	ids                    []int
+	frames                 []stackFrame
+}
+
+// groupGoroutines groups the goroutines with the same state and stack,
+// ignoring the arguments of the functions and how long they have been
+// blocked, like panicparse does. The largest groups come first.
+func groupGoroutines(goroutines []goroutine) []*goroutineGroup {
+	var groups []*goroutineGroup
+	byKey := make(map[string]*goroutineGroup)
+	for _, g := range goroutines {
+		var key strings.Builder
+		key.WriteString(g.state)
+		for _, f := range g.frames {
+			fmt.Fprintf(&key, "\n%s\n%s", f.fn, f.file)
+		}
+		group, ok := byKey[key.String()]
+		if !ok {
+			group = &goroutineGroup{state: g.state, minMinutes: g.minutes, maxMinutes: g.minutes, frames: g.frames}
+			byKey[key.String()] = group
+			groups = append(groups, group)
+		}
+		group.ids = append(group.ids, g.id)
+		if g.minutes < group.minMinutes {
+			group.minMinutes = g.minutes
+		}
+		if g.minutes > group.maxMinutes {
+			group.maxMinutes = g.minutes
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].ids) > len(groups[j].ids) })
+	return groups
+}
+
+func (g *goroutineGroup) format(w io.Writer) {
+	state := g.state
+	switch {
+	case g.maxMinutes == 0:
+	case g.minMinutes == g.maxMinutes:
+		state += fmt.Sprintf(", %d minutes", g.maxMinutes)
+	default:
+		state += fmt.Sprintf(", %d-%d minutes", g.minMinutes, g.maxMinutes)
+	}
+	ids := make([]string, 0, maxGroupIDs+1)
+	for i, id := range g.ids {
+		if i == maxGroupIDs {
+			ids = append(ids, "...")
+			break
+		}
+		ids = append(ids, strconv.Itoa(id))
+	}
+	fmt.Fprintf(w, "%s [%s]: %s\n", plural(len(g.ids), "goroutine"), state, strings.Join(ids, ", "))
+	for _, f := range g.frames {
+		fmt.Fprintln(w, f.fn)
+		if f.file != "" {
+			fmt.Fprintf(w, "\t%s\n", f.file)
+		}
+	}
+}
+
+// plural returns n followed by noun, in the plural unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// header returns the panic message and the tests that were running.
+func (d *timeoutDump) header() string {
+	var b strings.Builder
+	b.WriteString(d.panic + "\n")
+	if len(d.running) > 0 {
+		b.WriteString("running tests:\n")
+		for _, r := range d.running {
+			fmt.Fprintf(&b, "\t%s\n", r)
+		}
+	}
+	return b.String()
+}
+
+// summary returns the message added to the tests that timed out instead of
+// the goroutine dump: the number of goroutines and the stacks of those that
+// run tests.
+func (d *timeoutDump) summary() string {
+	goroutines := parseGoroutines(d.text.String())
+	var b strings.Builder
+	b.WriteString(d.panic + "\n")
+	fmt.Fprintf(&b, "%s with %s are listed in %s in the undeclared test outputs.\n",
+		plural(len(goroutines), "goroutine"), plural(len(groupGoroutines(goroutines)), "different stack"), goroutineDumpFile)
+	var testGoroutines []goroutine
+	for _, g := range goroutines {
+		if g.runsTest() {
+			testGoroutines = append(testGoroutines, g)
+		}
+	}
+	if len(testGoroutines) > 0 {
+		b.WriteString("\nGoroutines running tests:\n")
+		for _, group := range groupGoroutines(testGoroutines) {
+			b.WriteString("\n")
+			group.format(&b)
+		}
+	}
+	return b.String()
+}
+
+// timedOutTests returns the tests that were running when the test binary
+// timed out and have no running subtests.
+func (d *timeoutDump) timedOutTests() []string {
+	var leaves []string
+	for _, t := range d.tests {
+		leaf := true
+		for _, other := range d.tests {
+			if strings.HasPrefix(other, t+"/") {
+				leaf = false
+				break
+			}
+		}
+		if leaf {
+			leaves = append(leaves, t)
+		}
+	}
+	return leaves
+}
+
+// writeGoroutineDump writes the goroutines of the last run of the test binary
+// that timed out to goroutines.txt in dir, grouped by stack. Nothing is
+// written if no run timed out.
+func writeGoroutineDump(attempts []*bytes.Buffer, dir string) error {
+	var dump *timeoutDump
+	for _, a := range attempts {
+		_, d, err := parseTestCases(bytes.NewReader(a.Bytes()))
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			dump = d
+		}
+	}
+	if dump == nil {
+		return nil
+	}
+	goroutines := parseGoroutines(dump.text.String())
+	groups := groupGoroutines(goroutines)
+	var b bytes.Buffer
+	b.WriteString(dump.header())
+	fmt.Fprintf(&b, "\n%s with %s:\n", plural(len(goroutines), "goroutine"), plural(len(groups), "different stack"))
+	for _, g := range groups {
+		b.WriteString("\n")
+		g.format(&b)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, goroutineDumpFile), b.Bytes(), 0664)
+}
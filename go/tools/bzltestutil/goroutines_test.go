@@ -0,0 +1,155 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const goroutineDump = `goroutine 1 gp=0xc000002380 m=0 mp=0x5f1c60 [chan receive]:
+testing.(*T).Run(0xc000102b60, {0x5545a4?, 0x7ef?}, 0x6d49c0)
+	/usr/local/go/src/testing/testing.go:2266 +0x4f2
+main.main()
+	_testmain.go:46 +0x9b
+
+goroutine 7 [chan receive, 3 minutes]:
+example.com/to.waitForever(0xc000020060)
+	/tmp/to/to_test.go:8 +0x1d
+created by example.com/to.TestReport in goroutine 6
+	/tmp/to/to_test.go:13 +0x3a
+
+goroutine 8 [chan receive, 5 minutes]:
+example.com/to.waitForever(0xc000020060)
+	/tmp/to/to_test.go:8 +0x1d
+created by example.com/to.TestReport in goroutine 6
+	/tmp/to/to_test.go:13 +0x3a
+
+goroutine 11 [sleep]:
+time.Sleep(0x34630b8a000)
+	/usr/local/go/src/runtime/time.go:368 +0x165
+example.com/to.TestReport.func1(0xc0001026c8?)
+	/tmp/to/to_test.go:18 +0x39
+testing.tRunner(0xc0001026c8, 0xc0000323a8)
+	/usr/local/go/src/testing/testing.go:2193 +0xea
+...additional frames elided...
+created by testing.(*T).Run in goroutine 6
+	/usr/local/go/src/testing/testing.go:2258 +0x4d4
+FAIL	example.com/to	2.006s
+`
+
+func TestParseGoroutines(t *testing.T) {
+	got := parseGoroutines(goroutineDump)
+	want := []goroutine{
+		{id: 1, state: "chan receive", frames: []stackFrame{
+			{"testing.(*T).Run(...)", "/usr/local/go/src/testing/testing.go:2266"},
+			{"main.main()", "_testmain.go:46"},
+		}},
+		{id: 7, state: "chan receive", minutes: 3, frames: []stackFrame{
+			{"example.com/to.waitForever(...)", "/tmp/to/to_test.go:8"},
+			{"created by example.com/to.TestReport", "/tmp/to/to_test.go:13"},
+		}},
+		{id: 8, state: "chan receive", minutes: 5, frames: []stackFrame{
+			{"example.com/to.waitForever(...)", "/tmp/to/to_test.go:8"},
+			{"created by example.com/to.TestReport", "/tmp/to/to_test.go:13"},
+		}},
+		{id: 11, state: "sleep", frames: []stackFrame{
+			{"time.Sleep(...)", "/usr/local/go/src/runtime/time.go:368"},
+			{"example.com/to.TestReport.func1(...)", "/tmp/to/to_test.go:18"},
+			{"testing.tRunner(...)", "/usr/local/go/src/testing/testing.go:2193"},
+			{"...additional frames elided...", ""},
+			{"created by testing.(*T).Run", "/usr/local/go/src/testing/testing.go:2258"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	for i, wantRunsTest := range []bool{false, false, false, true} {
+		if got[i].runsTest() != wantRunsTest {
+			t.Errorf("goroutine %d: got runsTest() = %v, want %v", got[i].id, !wantRunsTest, wantRunsTest)
+		}
+	}
+}
+
+func TestWriteGoroutineDump(t *testing.T) {
+	attempts := []*bytes.Buffer{
+		convert(t, "=== RUN   TestA\n--- FAIL: TestA (0.00s)\nFAIL\n"),
+		convert(t,
+			"=== RUN   TestReport\n",
+			"=== RUN   TestReport/test_1\n",
+			"panic: test timed out after 2s\n",
+			"\trunning tests:\n",
+			"\t\tTestReport (2s)\n",
+			"\t\tTestReport/test_1 (2s)\n",
+			"\n",
+			goroutineDump),
+	}
+	dir := t.TempDir()
+	if err := writeGoroutineDump(attempts, dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, "goroutines.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `panic: test timed out after 2s
+running tests:
+	TestReport (2s)
+	TestReport/test_1 (2s)
+
+4 goroutines with 3 different stacks:
+
+2 goroutines [chan receive, 3-5 minutes]: 7, 8
+example.com/to.waitForever(...)
+	/tmp/to/to_test.go:8
+created by example.com/to.TestReport
+	/tmp/to/to_test.go:13
+
+1 goroutine [chan receive]: 1
+testing.(*T).Run(...)
+	/usr/local/go/src/testing/testing.go:2266
+main.main()
+	_testmain.go:46
+
+1 goroutine [sleep]: 11
+time.Sleep(...)
+	/usr/local/go/src/runtime/time.go:368
+example.com/to.TestReport.func1(...)
+	/tmp/to/to_test.go:18
+testing.tRunner(...)
+	/usr/local/go/src/testing/testing.go:2193
+...additional frames elided...
+created by testing.(*T).Run
+	/usr/local/go/src/testing/testing.go:2258
+`
+	if string(got) != want {
+		t.Errorf("got goroutines.txt:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteGoroutineDumpWithoutTimeout(t *testing.T) {
+	dir := t.TempDir()
+	attempts := []*bytes.Buffer{convert(t, "=== RUN   TestA\n--- PASS: TestA (0.00s)\nPASS\n")}
+	if err := writeGoroutineDump(attempts, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "goroutines.txt")); !os.IsNotExist(err) {
+		t.Errorf("got %v, want goroutines.txt to not exist", err)
+	}
+}
@@ -32,13 +32,19 @@ import (
 // Lock in the COVERAGE_DIR during test setup in case the test uses e.g. os.Clearenv.
 var coverageDir = os.Getenv("COVERAGE_DIR")
 
+// Also lock in whether branch coverage should be reported.
+var branchCoverageEnv, branchCoverageSet = os.LookupEnv("GO_LCOV_BRANCH_COVERAGE")
+
 // Also lock in the test flag set in case test overwrites it.
 var testFlags = flag.CommandLine
 
 // ConvertCoverToLcov converts the go coverprofile file coverage.dat.cover to
 // the expectedLcov format and stores it in coverage.dat, where it is picked up by
 // Bazel.
-// The conversion emits line and branch coverage, but not function coverage.
+// The conversion emits line and function coverage, and branch coverage if
+// GO_LCOV_BRANCH_COVERAGE is set to true. Functions are only reported for
+// files whose functions were registered with coverdata.RegisterFuncs when they
+// were instrumented.
 func ConvertCoverToLcov() error {
 	inPath := testFlags.Lookup("test.coverprofile").Value.String()
 	in, err := os.Open(inPath)
@@ -64,24 +70,51 @@ func ConvertCoverFromReaderToLcov(in io.Reader) error {
 	}
 	defer out.Close()
 
-	return convertCoverToLcov(in, out)
+	return WriteLcov(in, out)
+}
+
+// WriteLcov converts the go coverprofile read from in to lcov and writes it
+// to out, including branch records if GO_LCOV_BRANCH_COVERAGE is set.
+func WriteLcov(in io.Reader, out io.Writer) error {
+	branches := false
+	if branchCoverageSet {
+		var err error
+		branches, err = strconv.ParseBool(branchCoverageEnv)
+		if err != nil {
+			return fmt.Errorf("invalid value for GO_LCOV_BRANCH_COVERAGE: %q", branchCoverageEnv)
+		}
+	}
+	return convertCoverToLcov(in, out, branches)
 }
 
 var _coverLinePattern = regexp.MustCompile(`^(?P<path>.+):(?P<startLine>\d+)\.(?P<startColumn>\d+),(?P<endLine>\d+)\.(?P<endColumn>\d+) (?P<numStmt>\d+) (?P<count>\d+)$`)
 
 const (
-	_pathIdx      = 1
-	_startLineIdx = 2
-	_endLineIdx   = 4
-	_countIdx     = 7
+	_pathIdx        = 1
+	_startLineIdx   = 2
+	_startColumnIdx = 3
+	_endLineIdx     = 4
+	_endColumnIdx   = 5
+	_countIdx       = 7
 )
 
-func convertCoverToLcov(coverReader io.Reader, lcovWriter io.Writer) error {
+// coverBlock is a block in a go coverprofile.
+type coverBlock struct {
+	startLine, startColumn uint32
+	endLine, endColumn     uint32
+	count                  uint32
+}
+
+// convertCoverToLcov converts a go coverprofile to lcov. If branches is true,
+// each block of the coverprofile is reported as a branch of the line it
+// starts on.
+func convertCoverToLcov(coverReader io.Reader, lcovWriter io.Writer, branches bool) error {
 	cover := bufio.NewScanner(coverReader)
 	lcov := bufio.NewWriter(lcovWriter)
 	defer lcov.Flush()
 	currentPath := ""
 	var lineCounts map[uint32]uint32
+	var blocks []coverBlock
 	for cover.Scan() {
 		l := cover.Text()
 		m := _coverLinePattern.FindStringSubmatch(l)
@@ -94,22 +127,31 @@ func convertCoverToLcov(coverReader io.Reader, lcovWriter io.Writer) error {
 
 		if m[_pathIdx] != currentPath {
 			if currentPath != "" {
-				if err := emitLcovLines(lcov, currentPath, lineCounts); err != nil {
+				if err := emitLcovLines(lcov, currentPath, lineCounts, blocks, branches); err != nil {
 					return err
 				}
 			}
 			currentPath = m[_pathIdx]
 			lineCounts = make(map[uint32]uint32)
+			blocks = nil
 		}
 
 		startLine, err := strconv.ParseUint(m[_startLineIdx], 10, 32)
 		if err != nil {
 			return err
 		}
+		startColumn, err := strconv.ParseUint(m[_startColumnIdx], 10, 32)
+		if err != nil {
+			return err
+		}
 		endLine, err := strconv.ParseUint(m[_endLineIdx], 10, 32)
 		if err != nil {
 			return err
 		}
+		endColumn, err := strconv.ParseUint(m[_endColumnIdx], 10, 32)
+		if err != nil {
+			return err
+		}
 		count, err := strconv.ParseUint(m[_countIdx], 10, 32)
 		if err != nil {
 			return err
@@ -120,16 +162,17 @@ func convertCoverToLcov(coverReader io.Reader, lcovWriter io.Writer) error {
 				lineCounts[line] = uint32(count)
 			}
 		}
+		blocks = append(blocks, coverBlock{uint32(startLine), uint32(startColumn), uint32(endLine), uint32(endColumn), uint32(count)})
 	}
 	if currentPath != "" {
-		if err := emitLcovLines(lcov, currentPath, lineCounts); err != nil {
+		if err := emitLcovLines(lcov, currentPath, lineCounts, blocks, branches); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func emitLcovLines(lcov io.StringWriter, path string, lineCounts map[uint32]uint32) error {
+func emitLcovLines(lcov io.StringWriter, path string, lineCounts map[uint32]uint32, blocks []coverBlock, branches bool) error {
 	srcName, ok := coverdata.SrcPathMapping[path]
 	if !ok {
 		srcName = path
@@ -139,6 +182,15 @@ func emitLcovLines(lcov io.StringWriter, path string, lineCounts map[uint32]uint
 		return err
 	}
 
+	if err := emitLcovFunctions(lcov, coverdata.Funcs[path], blocks); err != nil {
+		return err
+	}
+	if branches {
+		if err := emitLcovBranches(lcov, blocks); err != nil {
+			return err
+		}
+	}
+
 	// Emit the coverage counters for the individual source lines.
 	sortedLines := make([]uint32, 0, len(lineCounts))
 	for line := range lineCounts {
@@ -163,3 +215,114 @@ func emitLcovLines(lcov io.StringWriter, path string, lineCounts map[uint32]uint
 	}
 	return nil
 }
+
+// emitLcovFunctions emits the function coverage of a source file.
+func emitLcovFunctions(lcov io.StringWriter, funcs []coverdata.Func, blocks []coverBlock) error {
+	if len(funcs) == 0 {
+		return nil
+	}
+	for _, fn := range funcs {
+		if _, err := lcov.WriteString(fmt.Sprintf("FN:%d,%s\n", fn.Line, fn.Name)); err != nil {
+			return err
+		}
+	}
+	numHit := 0
+	for _, fn := range funcs {
+		count := funcCount(fn, blocks)
+		if count > 0 {
+			numHit++
+		}
+		if _, err := lcov.WriteString(fmt.Sprintf("FNDA:%d,%s\n", count, fn.Name)); err != nil {
+			return err
+		}
+	}
+	_, err := lcov.WriteString(fmt.Sprintf("FNF:%d\nFNH:%d\n", len(funcs), numHit))
+	return err
+}
+
+// funcCount returns the number of calls of a function, which is the count of
+// the first block in its body.
+func funcCount(fn coverdata.Func, blocks []coverBlock) uint32 {
+	before := func(line1, col1, line2, col2 uint32) bool {
+		return line1 < line2 || line1 == line2 && col1 < col2
+	}
+	var first *coverBlock
+	for i := range blocks {
+		b := &blocks[i]
+		if before(b.startLine, b.startColumn, fn.StartLine, fn.StartCol) || before(fn.EndLine, fn.EndCol, b.startLine, b.startColumn) {
+			continue
+		}
+		if first == nil || before(b.startLine, b.startColumn, first.startLine, first.startColumn) {
+			first = b
+		} else if b.startLine == first.startLine && b.startColumn == first.startColumn && b.count > first.count {
+			// The same block may be listed more than once.
+			first = b
+		}
+	}
+	if first == nil {
+		return 0
+	}
+	return first.count
+}
+
+// emitLcovBranches emits the blocks of a source file that start on the same
+// line as branches of that line, in the order of their columns. Lines on which
+// a single block starts have no branches. A block listed more than once is
+// reported once with its highest count.
+func emitLcovBranches(lcov io.StringWriter, blocks []coverBlock) error {
+	sorted := append([]coverBlock(nil), blocks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.startLine != b.startLine {
+			return a.startLine < b.startLine
+		}
+		if a.startColumn != b.startColumn {
+			return a.startColumn < b.startColumn
+		}
+		if a.endLine != b.endLine {
+			return a.endLine < b.endLine
+		}
+		return a.endColumn < b.endColumn
+	})
+	var merged []coverBlock
+	for _, b := range sorted {
+		if n := len(merged); n > 0 && sameBlock(merged[n-1], b) {
+			if b.count > merged[n-1].count {
+				merged[n-1].count = b.count
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	numBranches := 0
+	numHit := 0
+	group := 0
+	for start := 0; start < len(merged); {
+		end := start + 1
+		for end < len(merged) && merged[end].startLine == merged[start].startLine {
+			end++
+		}
+		if end-start > 1 {
+			for branch, b := range merged[start:end] {
+				if b.count > 0 {
+					numHit++
+				}
+				if _, err := lcov.WriteString(fmt.Sprintf("BRDA:%d,%d,%d,%d\n", b.startLine, group, branch, b.count)); err != nil {
+					return err
+				}
+			}
+			numBranches += end - start
+			group++
+		}
+		start = end
+	}
+	if numBranches == 0 {
+		return nil
+	}
+	_, err := lcov.WriteString(fmt.Sprintf("BRF:%d\nBRH:%d\n", numBranches, numHit))
+	return err
+}
+
+func sameBlock(a, b coverBlock) bool {
+	return a.startLine == b.startLine && a.startColumn == b.startColumn && a.endLine == b.endLine && a.endColumn == b.endColumn
+}
@@ -3,6 +3,8 @@ package bzltestutil
 import (
 	"strings"
 	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/coverdata"
 )
 
 func TestConvertCoverToLcov(t *testing.T) {
@@ -58,7 +60,7 @@ end_of_record
 		t.Run(tt.name, func(t *testing.T) {
 			in := strings.NewReader(tt.goCover)
 			var out strings.Builder
-			err := convertCoverToLcov(in, &out)
+			err := convertCoverToLcov(in, &out, false)
 			if err != nil {
 				t.Errorf("convertCoverToLcov returned unexpected error: %+v", err)
 			}
@@ -69,3 +71,129 @@ end_of_record
 		})
 	}
 }
+
+func TestConvertCoverToLcovFunctions(t *testing.T) {
+	// The profile of:
+	//
+	//	5 func (t *T) M() int {
+	//	6 	return 1
+	//	7 }
+	//	8
+	//	9 func Empty() {}
+	//	10
+	//	11 func F(x int) int {
+	//	12 	f := func() int {
+	//	13 		return x
+	//	14 	}
+	//	15 	if x > 0 {
+	//	16 		return f()
+	//	17 	}
+	//	18 	return 0
+	//	19 }
+	coverdata.RegisterFuncs("example.com/p/p.go", []string{"(*T).M", "Empty", "F"}, []uint32{
+		5, 5, 21, 7, 1,
+		9, 9, 14, 9, 15,
+		11, 11, 19, 19, 1,
+	})
+	defer delete(coverdata.Funcs, "example.com/p/p.go")
+	in := strings.NewReader(`mode: count
+example.com/p/p.go:6.2,7.1 1 0
+example.com/p/p.go:9.15,9.15 0 1
+example.com/p/p.go:12.2,12.18 1 3
+example.com/p/p.go:13.3,14.1 1 2
+example.com/p/p.go:15.2,15.11 1 3
+example.com/p/p.go:16.3,17.1 1 2
+example.com/p/p.go:18.2,18.10 1 1
+`)
+	var out strings.Builder
+	if err := convertCoverToLcov(in, &out, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `SF:example.com/p/p.go
+FN:5,(*T).M
+FN:9,Empty
+FN:11,F
+FNDA:0,(*T).M
+FNDA:1,Empty
+FNDA:3,F
+FNF:3
+FNH:2
+DA:6,0
+DA:7,0
+DA:9,1
+DA:12,3
+DA:13,2
+DA:14,2
+DA:15,3
+DA:16,2
+DA:17,2
+DA:18,1
+LH:8
+LF:10
+end_of_record
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConvertCoverToLcovBranches(t *testing.T) {
+	in := strings.NewReader(`mode: count
+example.com/p/q.go:3.14,4.9 1 2
+example.com/p/q.go:4.20,6.2 1 0
+example.com/p/q.go:4.9,4.20 1 1
+example.com/p/q.go:4.9,4.20 1 3
+example.com/p/q.go:7.2,7.30 1 2
+example.com/p/q.go:7.30,7.40 1 2
+example.com/p/q.go:8.2,8.10 1 2
+`)
+	var out strings.Builder
+	if err := convertCoverToLcov(in, &out, true); err != nil {
+		t.Fatal(err)
+	}
+	want := `SF:example.com/p/q.go
+BRDA:4,0,0,3
+BRDA:4,0,1,0
+BRDA:7,1,0,2
+BRDA:7,1,1,2
+BRF:4
+BRH:3
+DA:3,2
+DA:4,3
+DA:5,0
+DA:6,0
+DA:7,2
+DA:8,2
+LH:4
+LF:6
+end_of_record
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConvertCoverToLcovBranches_singleBlocks(t *testing.T) {
+	in := strings.NewReader(`mode: count
+example.com/p/q.go:3.14,4.9 1 1
+example.com/p/q.go:4.9,5.3 1 0
+example.com/p/q.go:6.2,6.10 1 1
+`)
+	var out strings.Builder
+	if err := convertCoverToLcov(in, &out, true); err != nil {
+		t.Fatal(err)
+	}
+	// Every block starts on its own line, so there are no branches.
+	want := `SF:example.com/p/q.go
+DA:3,1
+DA:4,1
+DA:5,0
+DA:6,1
+LH:3
+LF:4
+end_of_record
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
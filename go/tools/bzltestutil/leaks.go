@@ -0,0 +1,201 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// leakPrefix starts the report of the goroutines and child processes
+	// still running after the tests when GO_TEST_LEAK_CHECK is "fail".
+	leakPrefix = "LEAK: "
+	// leakWarningPrefix starts the report when GO_TEST_LEAK_CHECK is "warn".
+	leakWarningPrefix = "LEAK WARNING: "
+	// leakCheckName is the name of the test case that reports leaks in the
+	// XML report. It can't be the name of a test, fuzz target or example.
+	leakCheckName = "LeakCheck"
+	// leakCheckTimeout is how long goroutines and child processes started by
+	// the tests are given to exit before they are reported.
+	leakCheckTimeout = time.Second
+)
+
+// ignoredGoroutineFunctions are functions whose goroutines are started by the
+// runtime or the standard library once and are never reported as leaks.
+var ignoredGoroutineFunctions = []string{
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+// LeakCheck records the goroutines and child processes that run before the
+// tests, to report those that are still running after them.
+type LeakCheck struct {
+	fail       bool
+	goroutines map[int]bool
+	processes  map[int]bool
+	out        io.Writer
+}
+
+// StartLeakCheck records the goroutines and child processes that are running
+// if GO_TEST_LEAK_CHECK is set, and returns nil otherwise. GO_TEST_LEAK_CHECK
+// may be "warn" to only report leaks or "fail" to also fail the test.
+func StartLeakCheck() (*LeakCheck, error) {
+	mode := os.Getenv("GO_TEST_LEAK_CHECK")
+	if mode == "" {
+		return nil, nil
+	}
+	if mode != "warn" && mode != "fail" {
+		return nil, fmt.Errorf("invalid value for GO_TEST_LEAK_CHECK: %q", mode)
+	}
+	l := &LeakCheck{
+		fail:       mode == "fail",
+		goroutines: make(map[int]bool),
+		processes:  make(map[int]bool),
+		out:        os.Stdout,
+	}
+	for _, g := range currentGoroutines() {
+		l.goroutines[g.id] = true
+	}
+	processes, err := childProcesses()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range processes {
+		l.processes[p.pid] = true
+	}
+	return l, nil
+}
+
+// Finish reports the goroutines and child processes that were started after
+// StartLeakCheck and are still running, and returns the exit code of the test
+// binary given the exit code of the tests. Leaks are given some time to exit
+// first. Finish returns code if l is nil.
+func (l *LeakCheck) Finish(code int) int {
+	if l == nil {
+		return code
+	}
+	var goroutines []goroutine
+	var processes []process
+	var err error
+	for deadline, delay := time.Now().Add(leakCheckTimeout), time.Millisecond; ; delay *= 2 {
+		goroutines, processes, err = l.leaks()
+		if err != nil {
+			fmt.Fprintf(l.out, "error while checking for leaks: %s\n", err)
+			return code
+		}
+		if len(goroutines) == 0 && len(processes) == 0 {
+			return code
+		}
+		if time.Now().Add(delay).After(deadline) {
+			break
+		}
+		time.Sleep(delay)
+	}
+
+	prefix := leakWarningPrefix
+	if l.fail {
+		prefix = leakPrefix
+	}
+	var leaked []string
+	if len(goroutines) > 0 {
+		leaked = append(leaked, plural(len(goroutines), "goroutine"))
+	}
+	if len(processes) > 0 {
+		leaked = append(leaked, plural(len(processes), "child process"))
+	}
+	verb := "are"
+	if len(goroutines)+len(processes) == 1 {
+		verb = "is"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s %s still running after the tests\n", prefix, strings.Join(leaked, " and "), verb)
+	for _, group := range groupGoroutines(goroutines) {
+		b.WriteString("\n")
+		group.format(&b)
+	}
+	if len(processes) > 0 {
+		b.WriteString("\n")
+		for _, p := range processes {
+			fmt.Fprintf(&b, "process %d: %s\n", p.pid, p.command)
+		}
+	}
+	io.WriteString(l.out, b.String())
+	if l.fail && code == 0 {
+		return 1
+	}
+	return code
+}
+
+// leaks returns the goroutines and child processes that are running and were
+// not when l was started.
+func (l *LeakCheck) leaks() ([]goroutine, []process, error) {
+	var goroutines []goroutine
+	for _, g := range currentGoroutines() {
+		if !l.goroutines[g.id] && !g.ignored() {
+			goroutines = append(goroutines, g)
+		}
+	}
+	current, err := childProcesses()
+	if err != nil {
+		return nil, nil, err
+	}
+	var processes []process
+	for _, p := range current {
+		if !l.processes[p.pid] {
+			processes = append(processes, p)
+		}
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].pid < processes[j].pid })
+	return goroutines, processes, nil
+}
+
+// currentGoroutines returns the goroutines of the test binary.
+func currentGoroutines() []goroutine {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parseGoroutines(string(buf[:n]))
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// ignored reports whether g is started by the runtime or the standard library
+// and should not be reported as a leak.
+func (g goroutine) ignored() bool {
+	for _, f := range g.frames {
+		fn := strings.TrimSuffix(strings.TrimSuffix(f.fn, "(...)"), "()")
+		for _, ignored := range ignoredGoroutineFunctions {
+			if fn == ignored {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// process is a child process of the test binary.
+type process struct {
+	pid     int
+	command string
+}
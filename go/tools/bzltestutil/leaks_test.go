@@ -0,0 +1,135 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLeakCheck(t *testing.T) {
+	for _, test := range []struct {
+		mode     string
+		prefix   string
+		wantCode int
+	}{
+		{mode: "fail", prefix: leakPrefix, wantCode: 1},
+		{mode: "warn", prefix: leakWarningPrefix, wantCode: 0},
+	} {
+		t.Run(test.mode, func(t *testing.T) {
+			t.Setenv("GO_TEST_LEAK_CHECK", test.mode)
+			l, err := StartLeakCheck()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			l.out = &out
+
+			block := make(chan struct{})
+			go leakGoroutine(block)
+			code := l.Finish(0)
+			close(block)
+
+			if code != test.wantCode {
+				t.Errorf("got exit code %d, want %d", code, test.wantCode)
+			}
+			want := test.prefix + "1 goroutine is still running after the tests\n"
+			if !strings.HasPrefix(out.String(), want) || !strings.Contains(out.String(), "bzltestutil.leakGoroutine(") {
+				t.Errorf("got report:\n%s\nwant the leaked goroutine after %q", out.String(), want)
+			}
+			if code := l.Finish(1); code != 1 {
+				t.Errorf("got exit code %d for failed tests, want 1", code)
+			}
+		})
+	}
+}
+
+func TestLeakCheckWithoutLeaks(t *testing.T) {
+	t.Setenv("GO_TEST_LEAK_CHECK", "fail")
+	l, err := StartLeakCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	l.out = &out
+
+	// Goroutines that exit soon after the tests are not leaks.
+	block := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		leakGoroutine(block)
+		close(done)
+	}()
+	go close(block)
+	if code := l.Finish(0); code != 0 || out.Len() > 0 {
+		t.Errorf("got exit code %d and report:\n%s\nwant no leaks", code, out.String())
+	}
+	<-done
+}
+
+func TestLeakCheckDisabled(t *testing.T) {
+	t.Setenv("GO_TEST_LEAK_CHECK", "")
+	l, err := StartLeakCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != nil {
+		t.Fatalf("got a leak check without GO_TEST_LEAK_CHECK")
+	}
+	if code := l.Finish(3); code != 3 {
+		t.Errorf("got exit code %d, want 3", code)
+	}
+
+	t.Setenv("GO_TEST_LEAK_CHECK", "yes")
+	if _, err := StartLeakCheck(); err == nil {
+		t.Error("got no error for an invalid GO_TEST_LEAK_CHECK")
+	}
+}
+
+func TestLeakCheckChildProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("child processes are only checked on Linux")
+	}
+	t.Setenv("GO_TEST_LEAK_CHECK", "fail")
+	l, err := StartLeakCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	l.out = &out
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("could not start a child process: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	// cmd.Wait runs in the test goroutine, so only the process leaks.
+	if code := l.Finish(0); code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	want := leakPrefix + "1 child process is still running after the tests\n"
+	if !strings.HasPrefix(out.String(), want) || !strings.Contains(out.String(), "sleep 60\n") {
+		t.Errorf("got report:\n%s\nwant the child process after %q", out.String(), want)
+	}
+}
+
+func leakGoroutine(block chan struct{}) {
+	<-block
+}
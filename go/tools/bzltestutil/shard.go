@@ -0,0 +1,158 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Strategies used to assign tests to the shards of a test with shard_count.
+const (
+	// ShardRoundRobin assigns the i-th test to shard i modulo the number of
+	// shards.
+	ShardRoundRobin = "round_robin"
+	// ShardHash assigns tests by a stable hash of their names, so adding or
+	// removing a test doesn't move other tests between shards.
+	ShardHash = "hash"
+	// ShardDuration balances the expected durations of tests, read from a
+	// timing file, over the shards.
+	ShardDuration = "duration"
+)
+
+// ShardStrategy is the strategy used by TestsInShard. It's set by the
+// generated test main before Wrap is called, so that it can be recorded in
+// the XML report.
+var ShardStrategy = ShardRoundRobin
+
+// shardFromEnv returns the index of the current shard and the number of
+// shards, or ok == false if the test is not sharded.
+func shardFromEnv() (index, total int, ok bool) {
+	total, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS"))
+	if err != nil || total <= 1 {
+		return 0, 0, false
+	}
+	index, err = strconv.Atoi(os.Getenv("TEST_SHARD_INDEX"))
+	if err != nil || index < 0 {
+		return 0, 0, false
+	}
+	return index, total, true
+}
+
+// TestsInShard returns the set of names run by the current shard, or nil if
+// the test is not sharded and all names should run. names lists the tests,
+// fuzz targets, examples and benchmarks of the test in a stable order, and
+// timings maps names to their expected durations in seconds for
+// ShardDuration.
+func TestsInShard(names []string, timings map[string]float64) map[string]bool {
+	if total, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS")); err != nil || total <= 1 {
+		return nil
+	}
+	// Tell Bazel that sharding is supported.
+	file, err := os.Create(os.Getenv("TEST_SHARD_STATUS_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to touch TEST_SHARD_STATUS_FILE: %v", err)
+	}
+	_ = file.Close()
+	index, total, ok := shardFromEnv()
+	if !ok {
+		return nil
+	}
+	shards := assignShards(ShardStrategy, names, timings, total)
+	inShard := make(map[string]bool)
+	for i, name := range names {
+		if shards[i] == index {
+			inShard[name] = true
+		}
+	}
+	return inShard
+}
+
+// assignShards returns the shard of each name.
+func assignShards(strategy string, names []string, timings map[string]float64, total int) []int {
+	shards := make([]int, len(names))
+	switch strategy {
+	case ShardHash:
+		for i, name := range names {
+			h := fnv.New32a()
+			h.Write([]byte(name))
+			shards[i] = int(h.Sum32() % uint32(total))
+		}
+	case ShardDuration:
+		// Assign the longest tests first, each to the shard with the least
+		// total duration so far. Tests missing from the timing file are
+		// assumed to take the average time of the others, except for
+		// benchmarks, which only run with -test.bench.
+		var sum float64
+		var known int
+		for _, name := range names {
+			if d, ok := timings[name]; ok {
+				sum += d
+				known++
+			}
+		}
+		average := 1.0
+		if known > 0 {
+			average = sum / float64(known)
+		}
+		durations := make([]float64, len(names))
+		order := make([]int, len(names))
+		for i, name := range names {
+			if d, ok := timings[name]; ok {
+				durations[i] = d
+			} else if !strings.HasPrefix(name, "Benchmark") {
+				durations[i] = average
+			}
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return durations[order[i]] > durations[order[j]]
+		})
+		loads := make([]float64, total)
+		for _, i := range order {
+			shard := 0
+			for s := 1; s < total; s++ {
+				if loads[s] < loads[shard] {
+					shard = s
+				}
+			}
+			shards[i] = shard
+			loads[shard] += durations[i]
+		}
+	default:
+		for i := range names {
+			shards[i] = i % total
+		}
+	}
+	return shards
+}
+
+// shardProperties returns the properties recorded in the XML report of a
+// sharded test.
+func shardProperties() []xmlProperty {
+	index, total, ok := shardFromEnv()
+	if !ok {
+		return nil
+	}
+	return []xmlProperty{
+		{Name: "shard_strategy", Value: ShardStrategy},
+		{Name: "shard_index", Value: strconv.Itoa(index)},
+		{Name: "shard_count", Value: strconv.Itoa(total)},
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bzltestutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAssignShards(t *testing.T) {
+	names := []string{"TestA", "TestB", "TestC", "TestD", "ExampleE", "BenchmarkF"}
+
+	if got, want := assignShards(ShardRoundRobin, names, nil, 2), []int{0, 1, 0, 1, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("round robin: got %v, want %v", got, want)
+	}
+
+	// Adding a test doesn't move other tests with the hash strategy.
+	hashed := assignShards(ShardHash, names, nil, 3)
+	more := assignShards(ShardHash, append([]string{"TestNew"}, names...), nil, 3)
+	if !reflect.DeepEqual(hashed, more[1:]) {
+		t.Errorf("hash: got %v before and %v after adding a test", hashed, more[1:])
+	}
+	for _, s := range hashed {
+		if s < 0 || s >= 3 {
+			t.Errorf("hash: got shard %d out of range", s)
+		}
+	}
+
+	// TestD and ExampleE are assumed to take the average of 5 seconds, and
+	// BenchmarkF no time.
+	timings := map[string]float64{"TestA": 9, "TestB": 2, "TestC": 4, "TestRemoved": 100}
+	if got, want := assignShards(ShardDuration, names, timings, 2), []int{0, 1, 0, 1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("duration: got %v, want %v", got, want)
+	}
+	if got, want := assignShards(ShardDuration, names, nil, 2), []int{0, 1, 0, 1, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("duration without timings: got %v, want %v", got, want)
+	}
+}
+
+func TestTestsInShard(t *testing.T) {
+	statusFile := filepath.Join(t.TempDir(), "status")
+	for k, v := range map[string]string{
+		"TEST_TOTAL_SHARDS":      "2",
+		"TEST_SHARD_INDEX":       "1",
+		"TEST_SHARD_STATUS_FILE": statusFile,
+	} {
+		t.Setenv(k, v)
+	}
+	defer func(strategy string) { ShardStrategy = strategy }(ShardStrategy)
+	ShardStrategy = ShardRoundRobin
+
+	got := TestsInShard([]string{"TestA", "TestB", "BenchmarkC", "ExampleD"}, nil)
+	if want := map[string]bool{"TestB": true, "ExampleD": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := os.Stat(statusFile); err != nil {
+		t.Errorf("TEST_SHARD_STATUS_FILE was not created: %v", err)
+	}
+	want := []xmlProperty{
+		{Name: "shard_strategy", Value: "round_robin"},
+		{Name: "shard_index", Value: "1"},
+		{Name: "shard_count", Value: "2"},
+	}
+	if got := shardProperties(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got properties %v, want %v", got, want)
+	}
+
+	t.Setenv("TEST_TOTAL_SHARDS", "1")
+	if got := TestsInShard([]string{"TestA"}, nil); got != nil {
+		t.Errorf("got %v without sharding, want nil", got)
+	}
+	if got := shardProperties(); got != nil {
+		t.Errorf("got properties %v without sharding, want nil", got)
+	}
+}
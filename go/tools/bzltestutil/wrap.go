@@ -25,11 +25,14 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/bazelbuild/rules_go/go/tools/bzltestutil/chdir"
 )
@@ -65,6 +68,18 @@ func shouldAddTestV() bool {
 	return false
 }
 
+// testSourceDir returns the package of the test target relative to the root
+// of its repository, which the file names logged by tests are usually
+// relative to, or "" if it is unknown.
+func testSourceDir() string {
+	_, target, ok := strings.Cut(os.Getenv("TEST_TARGET"), "//")
+	if !ok {
+		return ""
+	}
+	pkg, _, _ := strings.Cut(target, ":")
+	return pkg
+}
+
 // streamMerger intelligently merges an input stdout and stderr stream and dumps
 // the output to the writer `inner`. Additional synchronization is applied to
 // ensure that one line at a time is written to the inner writer.
@@ -112,11 +127,19 @@ func (m *streamMerger) Wait() {
 	m.wg.Wait()
 }
 
-func Wrap(pkg string) error {
-	var jsonBuffer bytes.Buffer
-	jsonConverter := NewConverter(&jsonBuffer, pkg, Timestamp)
-	streamMerger := NewStreamMerger(jsonConverter)
+// retriesFromEnv returns how many times the wrapper reruns failed tests.
+func retriesFromEnv() int {
+	if retriesEnv, ok := os.LookupEnv("GO_TEST_WRAP_RETRIES"); ok {
+		retries, err := strconv.Atoi(retriesEnv)
+		if err != nil || retries < 0 {
+			log.Fatalf("invalid value for GO_TEST_WRAP_RETRIES: %q", retriesEnv)
+		}
+		return retries
+	}
+	return 0
+}
 
+func Wrap(pkg string) error {
 	args := os.Args[1:]
 	if shouldAddTestV() {
 		// The -test.v=test2json flag is like -test.v=true but causes the test to add
@@ -136,8 +159,105 @@ func Wrap(pkg string) error {
 	// will be killed by Bazel after the grace period (15s) expires.
 	signal.Ignore(syscall.SIGTERM)
 
+	retries := retriesFromEnv()
+	start := time.Now()
+	jsonBuffer, err := runTest(pkg, exePath, args, os.Environ())
+	attempts := []*bytes.Buffer{jsonBuffer}
+	retryArgs := args
+	if !shouldAddTestV() {
+		// Passing tests are only reported in verbose mode, which is needed
+		// to tell whether a retried test passed.
+		retryArgs = append([]string{"-test.v=test2json"}, args...)
+	}
+	coverageDat, coverage := os.LookupEnv("COVERAGE_OUTPUT_FILE")
+	var coverageErr error
+	for len(attempts) <= retries && shouldRetry(err, args) {
+		failed, ferr := failedTests(jsonBuffer)
+		if ferr != nil || len(failed) == 0 {
+			break
+		}
+		env := os.Environ()
+		if timeout, ok := os.LookupEnv("TEST_TIMEOUT"); ok {
+			// Reruns share the time Bazel gives the whole test.
+			seconds, terr := strconv.Atoi(timeout)
+			if terr != nil {
+				break
+			}
+			remaining := seconds - int(time.Since(start).Seconds())
+			if remaining <= 0 {
+				break
+			}
+			env = append(env, "TEST_TIMEOUT="+strconv.Itoa(remaining))
+		}
+		attemptCoverage := fmt.Sprintf("%s.%d", coverageDat, len(attempts)+1)
+		if coverage {
+			// The test binary overwrites its coverage profile, so reruns
+			// write their own, which is added to that of the first run.
+			env = append(env, "COVERAGE_OUTPUT_FILE="+attemptCoverage)
+		}
+		fmt.Fprintf(os.Stderr, "=== RETRY attempt %d of %d: %s\n", len(attempts)+1, retries+1, strings.Join(failed, " "))
+		// Benchmarks matching -test.bench run regardless of -test.run, so
+		// only the failed ones are rerun.
+		pattern := runPattern(failed)
+		jsonBuffer, err = runTest(pkg, exePath, append(retryArgs, "-test.run="+pattern, "-test.bench="+pattern), env)
+		attempts = append(attempts, jsonBuffer)
+		if coverage {
+			if coverageErr = mergeAttemptCoverage(coverageDat, attemptCoverage); coverageErr != nil {
+				break
+			}
+		}
+	}
+	if coverageErr != nil {
+		if err != nil {
+			return fmt.Errorf("error while merging coverage of retried tests: %s, (error wrapping test execution: %s)", coverageErr, err)
+		}
+		return fmt.Errorf("error while merging coverage of retried tests: %s", coverageErr)
+	}
+	if path := jsonOutputPath(); path != "" {
+		if werr := writeJSONEvents(attempts, path); werr != nil {
+			if err != nil {
+				return fmt.Errorf("error while writing test2json events: %s, (error wrapping test execution: %s)", werr, err)
+			}
+			return fmt.Errorf("error while writing test2json events: %s", werr)
+		}
+	}
+	if dir := benchmarkOutputDir(); dir != "" {
+		if werr := writeBenchmarks(attempts, pkg, dir); werr != nil {
+			if err != nil {
+				return fmt.Errorf("error while writing benchmark results: %s, (error wrapping test execution: %s)", werr, err)
+			}
+			return fmt.Errorf("error while writing benchmark results: %s", werr)
+		}
+	}
+	if dir := os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR"); dir != "" {
+		if werr := writeGoroutineDump(attempts, dir); werr != nil {
+			if err != nil {
+				return fmt.Errorf("error while writing goroutine dump: %s, (error wrapping test execution: %s)", werr, err)
+			}
+			return fmt.Errorf("error while writing goroutine dump: %s", werr)
+		}
+	}
+	if out, ok := os.LookupEnv("XML_OUTPUT_FILE"); ok {
+		werr := writeReport(attempts, pkg, out)
+		if werr != nil {
+			if err != nil {
+				return fmt.Errorf("error while generating testreport: %s, (error wrapping test execution: %s)", werr, err)
+			}
+			return fmt.Errorf("error while generating testreport: %s", werr)
+		}
+	}
+	return err
+}
+
+// runTest runs the test binary with the given arguments and environment and
+// returns its output converted by test2json.
+func runTest(pkg, exePath string, args, env []string) (*bytes.Buffer, error) {
+	var jsonBuffer bytes.Buffer
+	jsonConverter := NewConverter(&jsonBuffer, pkg, Timestamp)
+	streamMerger := NewStreamMerger(jsonConverter)
+
 	cmd := exec.Command(exePath, args...)
-	cmd.Env = append(os.Environ(), "GO_TEST_WRAP=0")
+	cmd.Env = append(env, "GO_TEST_WRAP=0")
 	// On Windows, any current directory value longer than MAX_PATH(260 chars)
 	// will cause CreateProcess to fail, regardless of LongPathsEnabled=1 or a
 	// longPathAware PE manifest. Inheriting the value from the parent process
@@ -165,20 +285,167 @@ func Wrap(pkg string) error {
 		jsonConverter.Write([]byte("\n"))
 	}
 	jsonConverter.Close()
-	if out, ok := os.LookupEnv("XML_OUTPUT_FILE"); ok {
-		werr := writeReport(jsonBuffer, pkg, out)
-		if werr != nil {
+	return &jsonBuffer, err
+}
+
+// shouldRetry reports whether failed tests may be rerun after the test binary
+// exited with err. Only runs that completed with failing tests are retried:
+// the test binary exits with status 1 in that case, and with other statuses
+// when it panics or times out, which may leave tests unrun. Runs stopped
+// after the first failure by -test.failfast are not retried either.
+func shouldRetry(err error, args []string) bool {
+	xerr, ok := err.(*exec.ExitError)
+	if !ok || xerr.ExitCode() != 1 {
+		return false
+	}
+	if os.Getenv("TESTBRIDGE_TEST_RUNNER_FAIL_FAST") != "" {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "-test.failfast" || arg == "-test.failfast=true" || arg == "--test.failfast" || arg == "--test.failfast=true" {
+			return false
+		}
+	}
+	return true
+}
+
+// failedTests returns the names of the failed top-level tests, examples and
+// fuzz targets in the test2json output of a run.
+func failedTests(jsonBuffer *bytes.Buffer) ([]string, error) {
+	testcases, _, err := parseTestCases(bytes.NewReader(jsonBuffer.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	var failed []string
+	for name, c := range testcases {
+		// Leaks are not rerun: they are reported after all tests ran.
+		if c.state == "fail" && !strings.Contains(name, "/") && name != leakCheckName {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed, nil
+}
+
+// runPattern returns a -test.run pattern that matches exactly the given
+// top-level tests and all of their subtests.
+func runPattern(tests []string) string {
+	quoted := make([]string, len(tests))
+	for i, t := range tests {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// mergeAttemptCoverage adds the coverage profile written by a rerun of the
+// test binary with COVERAGE_OUTPUT_FILE set to attempt to the profile of the
+// first run at coverageDat. With the lcov coverage format, the profile has a
+// .cover suffix and was already converted to lcov in COVERAGE_DIR, where
+// Bazel merges it with that of the other runs.
+func mergeAttemptCoverage(coverageDat, attempt string) error {
+	for _, suffix := range []string{"", ".cover"} {
+		if _, err := os.Stat(attempt + suffix); os.IsNotExist(err) {
+			continue
+		}
+		if err := mergeCoverProfile(coverageDat+suffix, attempt+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeCoverProfile adds the counts of the go coverprofile at src to those of
+// the one at dst and removes src. Counts are summed, except in set mode.
+func mergeCoverProfile(dst, src string) error {
+	srcData, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	dstData, err := ioutil.ReadFile(dst)
+	if os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	} else if err != nil {
+		return err
+	}
+
+	dstLines := strings.Split(strings.TrimSuffix(string(dstData), "\n"), "\n")
+	srcLines := strings.Split(strings.TrimSuffix(string(srcData), "\n"), "\n")
+	if dstLines[0] != srcLines[0] {
+		return fmt.Errorf("cannot merge coverage profiles with modes %q and %q", dstLines[0], srcLines[0])
+	}
+	setMode := dstLines[0] == "mode: set"
+	// A block is identified by its line without the count.
+	var blocks []string
+	counts := make(map[string]int)
+	for _, p := range []struct {
+		path  string
+		lines []string
+	}{{dst, dstLines[1:]}, {src, srcLines[1:]}} {
+		for _, line := range p.lines {
+			i := strings.LastIndexByte(line, ' ')
+			if i < 0 {
+				return fmt.Errorf("%s: invalid coverage profile line: %q", p.path, line)
+			}
+			block := line[:i]
+			count, err := strconv.Atoi(line[i+1:])
 			if err != nil {
-				return fmt.Errorf("error while generating testreport: %s, (error wrapping test execution: %s)", werr, err)
+				return fmt.Errorf("%s: invalid coverage profile line: %q", p.path, line)
+			}
+			prev, ok := counts[block]
+			if !ok {
+				blocks = append(blocks, block)
+			}
+			if setMode {
+				counts[block] = prev | count
+			} else {
+				counts[block] = prev + count
 			}
-			return fmt.Errorf("error while generating testreport: %s", werr)
 		}
 	}
-	return err
+
+	var merged strings.Builder
+	merged.WriteString(dstLines[0] + "\n")
+	for _, block := range blocks {
+		fmt.Fprintf(&merged, "%s %d\n", block, counts[block])
+	}
+	if err := ioutil.WriteFile(dst, []byte(merged.String()), 0664); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// jsonOutputPath returns the path of the file the test2json events of the
+// test are written to, or "" if they should not be written. By default, they
+// are written to the undeclared outputs of the test.
+func jsonOutputPath() string {
+	if path, ok := os.LookupEnv("GO_TEST_WRAP_JSON_OUTPUT_FILE"); ok {
+		return path
+	}
+	if dir := os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR"); dir != "" {
+		return filepath.Join(dir, "test2json.json")
+	}
+	return ""
+}
+
+// writeJSONEvents writes the test2json events of all runs of the test binary
+// to path, like 'go test -json' would print them.
+func writeJSONEvents(attempts []*bytes.Buffer, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	var events bytes.Buffer
+	for _, a := range attempts {
+		events.Write(a.Bytes())
+	}
+	return ioutil.WriteFile(path, events.Bytes(), 0664)
 }
 
-func writeReport(jsonBuffer bytes.Buffer, pkg string, path string) error {
-	xml, cerr := json2xml(&jsonBuffer, pkg)
+func writeReport(attempts []*bytes.Buffer, pkg string, path string) error {
+	readers := make([]io.Reader, len(attempts))
+	for i, a := range attempts {
+		readers[i] = bytes.NewReader(a.Bytes())
+	}
+	xml, cerr := json2xml(readers, pkg, testSourceDir(), shardProperties())
 	if cerr != nil {
 		return fmt.Errorf("error converting test output to xml: %s", cerr)
 	}
@@ -15,8 +15,14 @@
 package bzltestutil
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -61,3 +67,131 @@ func TestShouldWrap(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryFailedTests(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/retry/attempt1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := failedTests(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"TestBroken", "TestFlaky"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("got failed tests %q, want %q", failed, want)
+	}
+
+	pattern := runPattern(failed)
+	if want := "^(TestBroken|TestFlaky)$"; pattern != want {
+		t.Errorf("got pattern %q, want %q", pattern, want)
+	}
+	re := regexp.MustCompile(pattern)
+	for name, want := range map[string]bool{"TestBroken": true, "TestFlaky": true, "TestFlakyOther": false, "XTestBroken": false} {
+		if got := re.MatchString(name); got != want {
+			t.Errorf("pattern %q matches %q: got %t, want %t", pattern, name, got, want)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Skipf("could not run sh: %v", err)
+		}
+		return err
+	}
+	os.Unsetenv("TESTBRIDGE_TEST_RUNNER_FAIL_FAST")
+	for _, tt := range []struct {
+		desc string
+		err  error
+		args []string
+		want bool
+	}{
+		{desc: "pass", err: nil, want: false},
+		{desc: "test failure", err: exitErr(1), want: true},
+		{desc: "panic", err: exitErr(2), want: false},
+		{desc: "failfast", err: exitErr(1), args: []string{"-test.failfast"}, want: false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := shouldRetry(tt.err, tt.args); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEST_UNDECLARED_OUTPUTS_DIR", dir)
+	os.Unsetenv("GO_TEST_WRAP_JSON_OUTPUT_FILE")
+	if got, want := jsonOutputPath(), filepath.Join(dir, "test2json.json"); got != want {
+		t.Errorf("got path %q, want %q", got, want)
+	}
+	path := filepath.Join(dir, "sub", "events.json")
+	t.Setenv("GO_TEST_WRAP_JSON_OUTPUT_FILE", path)
+	if got := jsonOutputPath(); got != path {
+		t.Errorf("got path %q, want %q", got, path)
+	}
+	t.Setenv("GO_TEST_WRAP_JSON_OUTPUT_FILE", "")
+	if got := jsonOutputPath(); got != "" {
+		t.Errorf("got path %q, want none", got)
+	}
+
+	attempts := []*bytes.Buffer{
+		bytes.NewBufferString(`{"Action":"fail","Test":"TestA"}` + "\n"),
+		bytes.NewBufferString(`{"Action":"pass","Test":"TestA"}` + "\n"),
+	}
+	if err := writeJSONEvents(attempts, path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Action":"fail","Test":"TestA"}` + "\n" + `{"Action":"pass","Test":"TestA"}` + "\n"; string(got) != want {
+		t.Errorf("got events:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeAttemptCoverage(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	coverageDat := filepath.Join(dir, "coverage.dat")
+	write("coverage.dat", "mode: count\na.go:1.1,2.2 1 3\na.go:3.1,4.2 1 0\nb.go:1.1,2.2 1 1\n")
+	write("coverage.dat.2", "mode: count\na.go:3.1,4.2 1 2\nc.go:1.1,2.2 1 1\n")
+	// The lcov format writes the profile with a .cover suffix.
+	write("coverage.dat.2.cover", "mode: set\na.go:1.1,2.2 1 1\n")
+
+	if err := mergeAttemptCoverage(coverageDat, coverageDat+".2"); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		coverageDat:            "mode: count\na.go:1.1,2.2 1 3\na.go:3.1,4.2 1 2\nb.go:1.1,2.2 1 1\nc.go:1.1,2.2 1 1\n",
+		coverageDat + ".cover": "mode: set\na.go:1.1,2.2 1 1\n",
+	} {
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %s:\n%s\nwant:\n%s", filepath.Base(path), got, want)
+		}
+	}
+	for _, name := range []string{"coverage.dat.2", "coverage.dat.2.cover"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", name, err)
+		}
+	}
+
+	write("coverage.dat.3", "mode: atomic\na.go:1.1,2.2 1 1\n")
+	if err := mergeAttemptCoverage(coverageDat, coverageDat+".3"); err == nil {
+		t.Error("merged coverage profiles with different modes")
+	}
+}
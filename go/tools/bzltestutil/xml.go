@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -31,15 +33,27 @@ type xmlTestSuites struct {
 }
 
 type xmlTestSuite struct {
-	XMLName   xml.Name      `xml:"testsuite"`
-	TestCases []xmlTestCase `xml:"testcase"`
-	Errors    int           `xml:"errors,attr"`
-	Failures  int           `xml:"failures,attr"`
-	Skipped   int           `xml:"skipped,attr"`
-	Tests     int           `xml:"tests,attr"`
-	Time      string        `xml:"time,attr"`
-	Name      string        `xml:"name,attr"`
-	Timestamp string        `xml:"timestamp,attr,omitempty"`
+	XMLName    xml.Name       `xml:"testsuite"`
+	Properties *xmlProperties `xml:"properties,omitempty"`
+	TestCases  []xmlTestCase  `xml:"testcase"`
+	Errors     int            `xml:"errors,attr"`
+	Failures   int            `xml:"failures,attr"`
+	Skipped    int            `xml:"skipped,attr"`
+	Tests      int            `xml:"tests,attr"`
+	Time       string         `xml:"time,attr"`
+	Name       string         `xml:"name,attr"`
+	Timestamp  string         `xml:"timestamp,attr,omitempty"`
+	// Subtests that have subtests of their own.
+	Suites []xmlTestSuite `xml:"testsuite"`
+}
+
+type xmlProperties struct {
+	Properties []xmlProperty `xml:"property"`
+}
+
+type xmlProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
 }
 
 type xmlTestCase struct {
@@ -47,9 +61,17 @@ type xmlTestCase struct {
 	Classname string      `xml:"classname,attr"`
 	Name      string      `xml:"name,attr"`
 	Time      string      `xml:"time,attr"`
+	File      string      `xml:"file,attr,omitempty"`
+	Line      string      `xml:"line,attr,omitempty"`
 	Failure   *xmlMessage `xml:"failure,omitempty"`
 	Error     *xmlMessage `xml:"error,omitempty"`
 	Skipped   *xmlMessage `xml:"skipped,omitempty"`
+	// Failed runs of a test that passed when it was retried.
+	FlakyFailures []xmlMessage `xml:"flakyFailure,omitempty"`
+	// Failed reruns of a test that failed every time.
+	RerunFailures []xmlMessage `xml:"rerunFailure,omitempty"`
+	// The output of a passed test.
+	SystemOut string `xml:"system-out,omitempty"`
 }
 
 type xmlMessage struct {
@@ -72,8 +94,10 @@ type testCase struct {
 	state    string
 	output   strings.Builder
 	duration *float64
-	start *time.Time
-	end *time.Time
+	start    *time.Time
+	end      *time.Time
+	// Earlier runs of the test when it was retried, oldest first.
+	previous []*testCase
 }
 
 const (
@@ -82,7 +106,51 @@ const (
 
 // json2xml converts test2json's output into an xml output readable by Bazel.
 // http://windyroad.com.au/dl/Open%20Source/JUnit.xsd
-func json2xml(r io.Reader, pkgName string) ([]byte, error) {
+// Each of attempts is the output of a run of the test binary; runs after the
+// first only rerun the tests that failed before. The file names that tests
+// log are relative to srcDir, and the given properties are added to every
+// test suite.
+func json2xml(attempts []io.Reader, pkgName, srcDir string, properties []xmlProperty) ([]byte, error) {
+	var testcases map[string]*testCase
+	for _, r := range attempts {
+		attempt, dump, err := parseTestCases(r)
+		if err != nil {
+			return nil, err
+		}
+		if dump != nil {
+			// The goroutine dump is written to a separate file, so the tests
+			// that timed out only report a summary of it.
+			summary := dump.summary()
+			for _, name := range dump.timedOutTests() {
+				if c := attempt[name]; c != nil {
+					c.output.WriteString(summary)
+				}
+			}
+		}
+		if testcases == nil {
+			testcases = attempt
+			continue
+		}
+		for name, c := range attempt {
+			if prev, ok := testcases[name]; ok {
+				c.previous = append(prev.previous, prev)
+			}
+			testcases[name] = c
+		}
+	}
+
+	suites := toXML(pkgName, srcDir, testcases)
+	if len(properties) > 0 {
+		for i := range suites.Suites {
+			suites.Suites[i].Properties = &xmlProperties{Properties: properties}
+		}
+	}
+	return xml.MarshalIndent(suites, "", "\t")
+}
+
+// parseTestCases returns the test cases in test2json's output, by name, and
+// the output that follows the panic if the test binary timed out.
+func parseTestCases(r io.Reader) (map[string]*testCase, *timeoutDump, error) {
 	testcases := make(map[string]*testCase)
 	testCaseByName := func(name string) *testCase {
 		if name == "" {
@@ -95,13 +163,15 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 	}
 
 	dec := json.NewDecoder(r)
-	var inTimeoutSection, inRunningTestSection bool
+	var dump *timeoutDump
+	var inRunningTestSection bool
+	var inLeakReport bool
 	for {
 		var e jsonEvent
 		if err := dec.Decode(&e); err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, fmt.Errorf("error decoding test2json output: %s", err)
+			return nil, nil, fmt.Errorf("error decoding test2json output: %s", err)
 		}
 		switch s := e.Action; s {
 		case "run":
@@ -111,11 +181,11 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 			}
 		case "output":
 			trimmedOutput := strings.TrimSpace(e.Output)
-			if strings.HasPrefix(trimmedOutput, timeoutPanicPrefix) {
-				inTimeoutSection = true
+			if dump == nil && strings.HasPrefix(trimmedOutput, timeoutPanicPrefix) {
+				dump = &timeoutDump{panic: trimmedOutput}
 				continue
 			}
-			if inTimeoutSection && strings.HasPrefix(trimmedOutput, "running tests:") {
+			if dump != nil && dump.text.Len() == 0 && !inRunningTestSection && strings.HasPrefix(trimmedOutput, "running tests:") {
 				inRunningTestSection = true
 				continue
 			}
@@ -123,19 +193,47 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 				// looking for something like "TestReport/test_3 (2s)"
 				parts := strings.Fields(e.Output)
 				if len(parts) != 2 || !strings.HasPrefix(parts[1], "(") || !strings.HasSuffix(parts[1], ")") {
-					inTimeoutSection = false
 					inRunningTestSection = false
 				} else if duration, err := time.ParseDuration(parts[1][1 : len(parts[1])-1]); err != nil {
-					inTimeoutSection = false
 					inRunningTestSection = false
-				} else if c := testCaseByName(parts[0]); c != nil {
-					c.state = "interrupt"
-					seconds := duration.Seconds()
-					c.duration = &seconds
-					c.output.WriteString(e.Output)
+				} else {
+					if c := testCaseByName(parts[0]); c != nil {
+						c.state = "interrupt"
+						seconds := duration.Seconds()
+						c.duration = &seconds
+						c.output.WriteString(e.Output)
+					}
+					dump.running = append(dump.running, trimmedOutput)
+					dump.tests = append(dump.tests, parts[0])
+					continue
 				}
+			}
+			if dump != nil {
+				// The rest of the output is the goroutine dump.
+				dump.text.WriteString(e.Output)
 				continue
 			}
+			if e.Test == "" && (strings.HasPrefix(e.Output, leakPrefix) || strings.HasPrefix(e.Output, leakWarningPrefix)) {
+				// The goroutines and child processes still running after the
+				// tests are reported as a test case, which fails unless the
+				// leaks are only warnings.
+				c := testCaseByName(leakCheckName)
+				c.state = "fail"
+				if strings.HasPrefix(e.Output, leakWarningPrefix) {
+					c.state = "pass"
+				}
+				c.start = e.Time
+				inLeakReport = true
+			}
+			if inLeakReport {
+				if e.Test == "" && !isFinalResult(trimmedOutput) {
+					c := testcases[leakCheckName]
+					c.output.WriteString(e.Output)
+					c.end = e.Time
+					continue
+				}
+				inLeakReport = false
+			}
 			if c := testCaseByName(e.Test); c != nil {
 				c.output.WriteString(e.Output)
 				c.end = e.Time
@@ -162,91 +260,207 @@ func json2xml(r io.Reader, pkgName string) ([]byte, error) {
 		}
 	}
 
-	return xml.MarshalIndent(toXML(pkgName, testcases), "", "\t")
+	return testcases, dump, nil
+}
+
+// isFinalResult reports whether line is the result printed by the test binary
+// or go test after all tests ran, like "PASS" or "ok  \tpkg\t0.1s".
+func isFinalResult(line string) bool {
+	return line == "PASS" || line == "FAIL" || strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "ok  \t")
+}
+
+// testNode is a test in the tree of tests and their subtests.
+type testNode struct {
+	name string
+	// c is nil if there are no events for the test, for example if only its
+	// subtests were reported as running when the test binary timed out.
+	c        *testCase
+	children []*testNode
 }
 
-func toXML(pkgName string, testcases map[string]*testCase) *xmlTestSuites {
+func toXML(pkgName, srcDir string, testcases map[string]*testCase) *xmlTestSuites {
 	cases := make([]string, 0, len(testcases))
 	for k := range testcases {
 		cases = append(cases, k)
 	}
 	sort.Strings(cases)
 
-	suiteByName := make(map[string]*xmlTestSuite)
-	var suiteNames []string
-
+	// Because test cases are sorted by name, parents are added before their
+	// subtests, and the children of every node are sorted too.
+	nodes := make(map[string]*testNode)
+	var roots []*testNode
+	var nodeByName func(name string) *testNode
+	nodeByName = func(name string) *testNode {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := &testNode{name: name}
+		nodes[name] = n
+		if i := strings.LastIndex(name, "/"); i < 0 {
+			roots = append(roots, n)
+		} else {
+			parent := nodeByName(name[:i])
+			parent.children = append(parent.children, n)
+		}
+		return n
+	}
 	for _, name := range cases {
-		suiteName := strings.SplitN(name, "/", 2)[0]
-		var suite *xmlTestSuite
-		suite, ok := suiteByName[suiteName]
-		if !ok {
-			suite = &xmlTestSuite{
-				Name: pkgName + "." + suiteName,
-			}
-			suiteByName[suiteName] = suite
-			suiteNames = append(suiteNames, suiteName)
-		}
-		c := testcases[name]
-		if name == suiteName {
-			var duration float64
-			if c.duration != nil {
-				duration = *c.duration
-			}
-			if c.start != nil && c.end != nil {
-				// the duration of a test suite may be greater than c.duration
-				// when any test case uses t.Parallel().
-				d := c.end.Sub(*c.start).Seconds()
-				if d > duration {
-					duration = d
-				}
-			}
-			suite.Time = fmt.Sprintf("%.3f", duration)
-			if c.start != nil {
-				suite.Timestamp = c.start.Format("2006-01-02T15:04:05.000Z")
+		nodeByName(name).c = testcases[name]
+	}
+
+	var suites xmlTestSuites
+	for _, n := range roots {
+		suites.Suites = append(suites.Suites, n.toSuite(pkgName, srcDir))
+	}
+	return &suites
+}
+
+// toSuite returns a test suite with the test case of n and its subtests.
+// Subtests that have subtests of their own are nested test suites.
+func (n *testNode) toSuite(pkgName, srcDir string) xmlTestSuite {
+	suite := xmlTestSuite{
+		Name: pkgName + "." + n.name,
+	}
+	if c := n.c; c != nil {
+		var duration float64
+		if c.duration != nil {
+			duration = *c.duration
+		}
+		if c.start != nil && c.end != nil {
+			// the duration of a test suite may be greater than c.duration
+			// when any test case uses t.Parallel().
+			d := c.end.Sub(*c.start).Seconds()
+			if d > duration {
+				duration = d
 			}
 		}
-		suite.Tests++
-		newCase := xmlTestCase{
-			Name:      name,
-			Classname: path.Base(pkgName),
+		suite.Time = fmt.Sprintf("%.3f", duration)
+		if c.start != nil {
+			suite.Timestamp = c.start.Format("2006-01-02T15:04:05.000Z")
 		}
-		if c.duration != nil {
-			newCase.Time = fmt.Sprintf("%.3f", *c.duration)
+		suite.addTestCase(newXMLTestCase(pkgName, srcDir, n.name, c))
+	}
+	for _, child := range n.children {
+		if len(child.children) == 0 {
+			suite.addTestCase(newXMLTestCase(pkgName, srcDir, child.name, child.c))
+			continue
 		}
+		nested := child.toSuite(pkgName, srcDir)
+		suite.Tests += nested.Tests
+		suite.Failures += nested.Failures
+		suite.Errors += nested.Errors
+		suite.Skipped += nested.Skipped
+		suite.Suites = append(suite.Suites, nested)
+	}
+	return suite
+}
+
+func (s *xmlTestSuite) addTestCase(c xmlTestCase) {
+	s.Tests++
+	switch {
+	case c.Skipped != nil:
+		s.Skipped++
+	case c.Failure != nil:
+		s.Failures++
+	case c.Error != nil:
+		s.Errors++
+	}
+	s.TestCases = append(s.TestCases, c)
+}
+
+func newXMLTestCase(pkgName, srcDir, name string, c *testCase) xmlTestCase {
+	classname := path.Base(pkgName)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		// Group subtests by their parent.
+		classname += "." + name[:i]
+	}
+	newCase := xmlTestCase{
+		Name:      name,
+		Classname: classname,
+	}
+	if c.duration != nil {
+		newCase.Time = fmt.Sprintf("%.3f", *c.duration)
+	}
+	newCase.File, newCase.Line = c.location(srcDir)
+	switch c.state {
+	case "skip":
+		newCase.Skipped = &xmlMessage{
+			Message:  "Skipped",
+			Contents: c.output.String(),
+		}
+	case "fail":
+		newCase.Failure = &xmlMessage{
+			Message:  "Failed",
+			Contents: c.output.String(),
+		}
+	case "interrupt":
+		newCase.Error = &xmlMessage{
+			Message:  "Interrupted",
+			Contents: c.output.String(),
+		}
+	case "pass":
+		newCase.SystemOut = c.output.String()
+	default:
+		newCase.Error = &xmlMessage{
+			Message:  "No pass/skip/fail event found for test",
+			Contents: c.output.String(),
+		}
+	}
+	var failedRuns []*testCase
+	for _, p := range c.previous {
+		if p.state == "fail" {
+			failedRuns = append(failedRuns, p)
+		}
+	}
+	if len(failedRuns) > 0 {
 		switch c.state {
-		case "skip":
-			suite.Skipped++
-			newCase.Skipped = &xmlMessage{
-				Message:  "Skipped",
-				Contents: c.output.String(),
+		case "pass", "skip":
+			for _, p := range failedRuns {
+				newCase.FlakyFailures = append(newCase.FlakyFailures, xmlMessage{
+					Message:  "Failed",
+					Contents: p.output.String(),
+				})
 			}
 		case "fail":
-			suite.Failures++
-			newCase.Failure = &xmlMessage{
-				Message:  "Failed",
-				Contents: c.output.String(),
-			}
-		case "interrupt":
-			suite.Errors++
-			newCase.Error = &xmlMessage{
-				Message:  "Interrupted",
-				Contents: c.output.String(),
+			// Like Maven Surefire, report the first run as the failure
+			// and the reruns as rerun failures.
+			for _, p := range append(failedRuns[1:], c) {
+				newCase.RerunFailures = append(newCase.RerunFailures, xmlMessage{
+					Message:  "Failed",
+					Contents: p.output.String(),
+				})
 			}
-		case "pass":
+			newCase.Failure.Contents = failedRuns[0].output.String()
+		}
+	}
+	return newCase
+}
+
+// locationRegexp matches the file and line that the testing package prints
+// before messages logged by tests, like "    x_test.go:12: message". Full
+// paths are printed with -test.fullpath.
+var locationRegexp = regexp.MustCompile(`^\s*(\S+\.go):(\d+): `)
+
+// location returns the file and line of the first message logged by the test,
+// or empty strings if it didn't log any. For a failed test, it returns those
+// of the last message logged before the "--- FAIL" line, which is usually the
+// failure, or of the last message if the test logged none before that line.
+// Relative file names are joined to srcDir.
+func (c *testCase) location(srcDir string) (file, line string) {
+	failed := c.state == "fail"
+	for _, l := range strings.Split(c.output.String(), "\n") {
+		if failed && file != "" && strings.HasPrefix(strings.TrimSpace(l), "--- FAIL:") {
 			break
-		default:
-			suite.Errors++
-			newCase.Error = &xmlMessage{
-				Message:  "No pass/skip/fail event found for test",
-				Contents: c.output.String(),
+		}
+		if m := locationRegexp.FindStringSubmatch(l); m != nil {
+			file, line = m[1], m[2]
+			if !failed {
+				break
 			}
 		}
-		suite.TestCases = append(suite.TestCases, newCase)
 	}
-	var suites xmlTestSuites
-	// because test cases are sorted by name, the suite names are also sorted.
-	for _, name := range suiteNames {
-		suites.Suites = append(suites.Suites, *suiteByName[name])
+	if file != "" && srcDir != "" && !filepath.IsAbs(file) {
+		file = path.Join(srcDir, file)
 	}
-	return &suites
+	return file, line
 }
@@ -16,6 +16,7 @@ package bzltestutil
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -36,7 +37,7 @@ func TestJSON2XML(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			got, err := json2xml(orig, "pkg/testing")
+			got, err := json2xml([]io.Reader{orig}, "pkg/testing", "pkg/testing", nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -53,3 +54,29 @@ func TestJSON2XML(t *testing.T) {
 		})
 	}
 }
+
+func TestJSON2XMLRetries(t *testing.T) {
+	files, err := filepath.Glob("testdata/retry/attempt*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts []io.Reader
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts = append(attempts, bytes.NewReader(data))
+	}
+	got, err := json2xml(attempts, "pkg/testing", "pkg/testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/retry/report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("json2xml does not match, got:\n%s\nwant:\n%s\n", string(got), string(want))
+	}
+}
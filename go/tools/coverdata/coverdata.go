@@ -31,8 +31,20 @@ var (
 	Blocks = make(map[string][]testing.CoverBlock)
 
 	SrcPathMapping = make(map[string]string)
+
+	Funcs = make(map[string][]Func)
 )
 
+// Func is a function declared in a file with coverage instrumentation.
+type Func struct {
+	Name string
+	// Line is the line of the func keyword.
+	Line uint32
+	// The positions of the braces around the body of the function, with
+	// 1-based byte columns like in coverage profiles.
+	StartLine, StartCol, EndLine, EndCol uint32
+}
+
 // RegisterFile causes the coverage data recorded for a file to be included
 // in program-wide coverage reports. This should be called from init functions
 // in packages with coverage instrumentation.
@@ -62,3 +74,24 @@ func RegisterFile(fileName string, counter []uint32, pos []uint32, numStmts []ui
 func RegisterSrcPathMapping(importPathFile string, srcName string) {
 	SrcPathMapping[importPathFile] = srcName
 }
+
+// RegisterFuncs records the functions declared in a file, so that coverage
+// reports can include function coverage. pos holds the Line, StartLine,
+// StartCol, EndLine and EndCol of each function.
+func RegisterFuncs(importPathFile string, names []string, pos []uint32) {
+	if 5*len(names) != len(pos) {
+		panic("coverage: mismatched sizes")
+	}
+	funcs := make([]Func, len(names))
+	for i, name := range names {
+		funcs[i] = Func{
+			Name:      name,
+			Line:      pos[5*i+0],
+			StartLine: pos[5*i+1],
+			StartCol:  pos[5*i+2],
+			EndLine:   pos[5*i+3],
+			EndCol:    pos[5*i+4],
+		}
+	}
+	Funcs[importPathFile] = funcs
+}
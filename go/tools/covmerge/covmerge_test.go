@@ -0,0 +1,196 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMergeLcov(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go_coverage.1.dat": `SF:src/lib.go
+FN:3,Hello
+FN:9,World
+FNDA:1,Hello
+FNDA:0,World
+FNF:2
+FNH:1
+BRDA:4,0,0,1
+BRDA:4,0,1,0
+BRF:2
+BRH:1
+DA:3,1
+DA:4,1
+DA:9,0
+LH:2
+LF:3
+end_of_record
+`,
+		"go_coverage.2.dat": `SF:src/main.go
+DA:5,1
+LH:1
+LF:1
+end_of_record
+SF:src/lib.go
+FN:3,Hello
+FN:9,World
+FNDA:2,Hello
+FNDA:1,World
+FNF:2
+FNH:2
+BRDA:4,0,0,0
+BRDA:4,0,1,2
+BRF:2
+BRH:1
+DA:3,2
+DA:4,0
+DA:9,1
+LH:2
+LF:3
+end_of_record
+`,
+		// Other files in the directory are ignored.
+		"go_coverage.2.out":     "mode: atomic\n",
+		"go_coverage.3.dat.tmp": "SF:src/tmp.go\nDA:1,1\nend_of_record\n",
+	})
+	var out bytes.Buffer
+	if err := run([]string{dir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `SF:src/lib.go
+FN:3,Hello
+FN:9,World
+FNDA:3,Hello
+FNDA:1,World
+FNF:2
+FNH:2
+BRDA:4,0,0,1
+BRDA:4,0,1,2
+BRF:2
+BRH:2
+DA:3,3
+DA:4,1
+DA:9,1
+LH:3
+LF:3
+end_of_record
+SF:src/main.go
+DA:5,1
+LH:1
+LF:1
+end_of_record
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeCover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.out": `mode: atomic
+example.com/lib/lib.go:3.20,5.2 1 1
+example.com/lib/lib.go:9.20,11.2 1 0
+`,
+		"b.out": `mode: atomic
+example.com/main/main.go:5.13,7.2 2 1
+example.com/lib/lib.go:9.20,11.2 1 4
+example.com/lib/lib.go:3.20,5.2 1 2
+`,
+	})
+	output := filepath.Join(t.TempDir(), "merged.out")
+	var out bytes.Buffer
+	if err := run([]string{"-format=cover", "-o", output, filepath.Join(dir, "a.out"), filepath.Join(dir, "b.out")}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output on stdout:\n%s", out.String())
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `mode: atomic
+example.com/lib/lib.go:3.20,5.2 1 3
+example.com/lib/lib.go:9.20,11.2 1 4
+example.com/main/main.go:5.13,7.2 2 1
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeCoverSetMode(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.out": "mode: set\nfoo.go:1.1,2.2 1 1\n",
+		"b.out": "mode: set\nfoo.go:1.1,2.2 1 1\n",
+	})
+	var out bytes.Buffer
+	if err := run([]string{"-format=cover", dir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mode: set\nfoo.go:1.1,2.2 1 1\n"; out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestMergeCoverErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "mixed modes",
+			files: map[string]string{
+				"a.out": "mode: set\nfoo.go:1.1,2.2 1 1\n",
+				"b.out": "mode: atomic\nfoo.go:1.1,2.2 1 1\n",
+			},
+			want: `cannot merge profiles with modes "set" and "atomic"`,
+		},
+		{
+			name:  "invalid line",
+			files: map[string]string{"a.out": "mode: set\nfoo.go 1 1\n"},
+			want:  "invalid go cover line",
+		},
+		{
+			name:  "no profiles",
+			files: map[string]string{"a.dat": "SF:foo.go\nend_of_record\n"},
+			want:  "no .out files",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeFiles(t, tc.files)
+			var out bytes.Buffer
+			if err := run([]string{"-format=cover", dir}, &out); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
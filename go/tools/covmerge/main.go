@@ -0,0 +1,453 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// covmerge merges coverage reports written by coverage-instrumented Go
+// binaries, such as the files written to GO_BINARY_COVERAGE_DIR by
+// go_binary targets built with --collect_code_coverage, into one report.
+//
+// Usage:
+//
+//	bazel run @io_bazel_rules_go//go/tools/covmerge -- [-format=lcov|cover] [-o out] paths...
+//
+// Each path is either a report or a directory, in which case all reports in
+// it are merged: the *.dat files for lcov and the *.out files for go cover
+// profiles. Execution counts of the same line, function, branch or block are
+// summed, except in go cover profiles with mode "set". The merged report is
+// written to stdout unless -o is given.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("covmerge: ")
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("covmerge", flag.ContinueOnError)
+	format := fs.String("format", "lcov", "the `format` of the reports: lcov or cover")
+	output := fs.String("o", "", "write the merged report to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: covmerge [flags] paths...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one report or directory")
+	}
+	var ext string
+	var merger interface {
+		add(path string, r io.Reader) error
+		write(w io.Writer) error
+	}
+	switch *format {
+	case "lcov":
+		ext, merger = ".dat", newLcovMerger()
+	case "cover":
+		ext, merger = ".out", newCoverMerger()
+	default:
+		return fmt.Errorf("unknown format %q, want lcov or cover", *format)
+	}
+
+	files, err := expandPaths(fs.Args(), ext)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no %s files in %s", ext, strings.Join(fs.Args(), " "))
+	}
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := merger.add(path, bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+
+	if *output == "" {
+		return merger.write(stdout)
+	}
+	var out bytes.Buffer
+	if err := merger.write(&out); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(resolve(*output), out.Bytes(), 0666)
+}
+
+// resolve interprets relative paths relative to the directory covmerge was
+// run from with bazel run.
+func resolve(path string) string {
+	if wd := os.Getenv("BUILD_WORKING_DIRECTORY"); wd != "" && !filepath.IsAbs(path) {
+		return filepath.Join(wd, path)
+	}
+	return path
+}
+
+// expandPaths replaces the directories in paths with the files in them that
+// end in ext, in lexical order.
+func expandPaths(paths []string, ext string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		path = resolve(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ext) {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// lcovFile is the merged coverage of a source file in lcov reports.
+type lcovFile struct {
+	funcLines map[string]int   // FN
+	funcCalls map[string]int64 // FNDA
+	lines     map[int]int64    // DA
+	branches  map[lcovBranch]lcovBranchCount
+}
+
+type lcovBranch struct {
+	line, block, branch int
+}
+
+type lcovBranchCount struct {
+	count int64
+	taken bool // false if the count is "-" in all reports
+}
+
+type lcovMerger struct {
+	files map[string]*lcovFile
+}
+
+func newLcovMerger() *lcovMerger {
+	return &lcovMerger{files: make(map[string]*lcovFile)}
+}
+
+// add merges an lcov report. Only the records written by rules_go and
+// Bazel's coverage tools are understood; the summary records are recomputed
+// when writing the merged report.
+func (m *lcovMerger) add(path string, r io.Reader) error {
+	var f *lcovFile
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			if line == "end_of_record" {
+				f = nil
+			}
+			continue
+		}
+		kind, value := line[:i], line[i+1:]
+		if kind == "SF" {
+			f = m.files[value]
+			if f == nil {
+				f = &lcovFile{
+					funcLines: make(map[string]int),
+					funcCalls: make(map[string]int64),
+					lines:     make(map[int]int64),
+					branches:  make(map[lcovBranch]lcovBranchCount),
+				}
+				m.files[value] = f
+			}
+			continue
+		}
+		if f == nil {
+			continue
+		}
+		fields := strings.Split(value, ",")
+		var err error
+		switch kind {
+		case "FN":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s:%d: invalid FN record: %s", path, lineno, line)
+			}
+			var fnLine int
+			fnLine, err = strconv.Atoi(fields[0])
+			f.funcLines[strings.Join(fields[1:], ",")] = fnLine
+		case "FNDA":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s:%d: invalid FNDA record: %s", path, lineno, line)
+			}
+			var count int64
+			count, err = strconv.ParseInt(fields[0], 10, 64)
+			f.funcCalls[strings.Join(fields[1:], ",")] += count
+		case "DA":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s:%d: invalid DA record: %s", path, lineno, line)
+			}
+			var daLine int
+			var count int64
+			if daLine, err = strconv.Atoi(fields[0]); err == nil {
+				count, err = strconv.ParseInt(fields[1], 10, 64)
+			}
+			f.lines[daLine] += count
+		case "BRDA":
+			if len(fields) != 4 {
+				return fmt.Errorf("%s:%d: invalid BRDA record: %s", path, lineno, line)
+			}
+			var b lcovBranch
+			if b.line, err = strconv.Atoi(fields[0]); err == nil {
+				if b.block, err = strconv.Atoi(fields[1]); err == nil {
+					b.branch, err = strconv.Atoi(fields[2])
+				}
+			}
+			c := f.branches[b]
+			if fields[3] != "-" && err == nil {
+				var count int64
+				count, err = strconv.ParseInt(fields[3], 10, 64)
+				c.count += count
+				c.taken = true
+			}
+			f.branches[b] = c
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid %s record: %s", path, lineno, kind, line)
+		}
+	}
+	return s.Err()
+}
+
+// write writes the merged report, with the source files sorted by path.
+func (m *lcovMerger) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f := m.files[path]
+		fmt.Fprintf(bw, "SF:%s\n", path)
+
+		if len(f.funcLines) > 0 {
+			names := make([]string, 0, len(f.funcLines))
+			for name := range f.funcLines {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool {
+				if f.funcLines[names[i]] != f.funcLines[names[j]] {
+					return f.funcLines[names[i]] < f.funcLines[names[j]]
+				}
+				return names[i] < names[j]
+			})
+			for _, name := range names {
+				fmt.Fprintf(bw, "FN:%d,%s\n", f.funcLines[name], name)
+			}
+			hit := 0
+			for _, name := range names {
+				if f.funcCalls[name] > 0 {
+					hit++
+				}
+				fmt.Fprintf(bw, "FNDA:%d,%s\n", f.funcCalls[name], name)
+			}
+			fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(names), hit)
+		}
+
+		if len(f.branches) > 0 {
+			branches := make([]lcovBranch, 0, len(f.branches))
+			for b := range f.branches {
+				branches = append(branches, b)
+			}
+			sort.Slice(branches, func(i, j int) bool {
+				a, b := branches[i], branches[j]
+				if a.line != b.line {
+					return a.line < b.line
+				}
+				if a.block != b.block {
+					return a.block < b.block
+				}
+				return a.branch < b.branch
+			})
+			hit := 0
+			for _, b := range branches {
+				c := f.branches[b]
+				count := "-"
+				if c.taken {
+					count = strconv.FormatInt(c.count, 10)
+				}
+				if c.count > 0 {
+					hit++
+				}
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.line, b.block, b.branch, count)
+			}
+			fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", len(branches), hit)
+		}
+
+		lines := make([]int, 0, len(f.lines))
+		for l := range f.lines {
+			lines = append(lines, l)
+		}
+		sort.Ints(lines)
+		hit := 0
+		for _, l := range lines {
+			if f.lines[l] > 0 {
+				hit++
+			}
+			fmt.Fprintf(bw, "DA:%d,%d\n", l, f.lines[l])
+		}
+		fmt.Fprintf(bw, "LH:%d\nLF:%d\nend_of_record\n", hit, len(lines))
+	}
+	return bw.Flush()
+}
+
+// coverBlock is the position of a block in a go cover profile, like
+// "example.com/foo/foo.go:3.14,5.2 2".
+type coverBlock struct {
+	file                   string
+	startLine, startColumn int
+	endLine, endColumn     int
+	numStmt                int
+}
+
+type coverMerger struct {
+	mode   string
+	counts map[coverBlock]int64
+}
+
+func newCoverMerger() *coverMerger {
+	return &coverMerger{counts: make(map[coverBlock]int64)}
+}
+
+// add merges a go cover profile. All profiles must have the same mode.
+func (m *coverMerger) add(path string, r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if lineno == 1 {
+			if !strings.HasPrefix(line, "mode: ") {
+				return fmt.Errorf("%s: not a go cover profile", path)
+			}
+			mode := strings.TrimPrefix(line, "mode: ")
+			if m.mode != "" && m.mode != mode {
+				return fmt.Errorf("%s: cannot merge profiles with modes %q and %q", path, m.mode, mode)
+			}
+			m.mode = mode
+			continue
+		}
+		b, count, err := parseCoverLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+		if m.mode == "set" {
+			if count > m.counts[b] {
+				m.counts[b] = count
+			}
+		} else {
+			m.counts[b] += count
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if m.mode == "" {
+		return fmt.Errorf("%s: not a go cover profile", path)
+	}
+	return nil
+}
+
+func parseCoverLine(line string) (coverBlock, int64, error) {
+	var b coverBlock
+	invalid := fmt.Errorf("invalid go cover line: %s", line)
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return b, 0, invalid
+	}
+	b.file = line[:i]
+	fields := strings.Fields(line[i+1:])
+	if len(fields) != 3 {
+		return b, 0, invalid
+	}
+	if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &b.startLine, &b.startColumn, &b.endLine, &b.endColumn); err != nil {
+		return b, 0, invalid
+	}
+	var err error
+	if b.numStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return b, 0, invalid
+	}
+	count, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return b, 0, invalid
+	}
+	return b, count, nil
+}
+
+// write writes the merged profile, with the blocks sorted by file and
+// position like the profiles written by go test.
+func (m *coverMerger) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	blocks := make([]coverBlock, 0, len(m.counts))
+	for b := range m.counts {
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.startLine != b.startLine {
+			return a.startLine < b.startLine
+		}
+		if a.startColumn != b.startColumn {
+			return a.startColumn < b.startColumn
+		}
+		if a.endLine != b.endLine {
+			return a.endLine < b.endLine
+		}
+		return a.endColumn < b.endColumn
+	})
+	fmt.Fprintf(bw, "mode: %s\n", m.mode)
+	for _, b := range blocks {
+		fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", b.file, b.startLine, b.startColumn, b.endLine, b.endColumn, b.numStmt, m.counts[b])
+	}
+	return bw.Flush()
+}
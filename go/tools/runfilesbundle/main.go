@@ -0,0 +1,121 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// runfilesbundle copies the runfiles of a binary into a self-contained
+// directory or a deterministic tar or zip archive, for example to deploy a
+// binary built with --nobuild_runfile_links, whose runfiles are only listed in
+// a manifest.
+//
+// Usage:
+//
+//	bazel run @io_bazel_rules_go//go/tools/runfilesbundle -- (-manifest file | -dir dir) [-format dir|tar|zip] -o out
+//
+// All runfiles are copied with their canonical paths, including the repository
+// mapping, along with a MANIFEST file whose targets are relative to the root
+// of the bundle. After extracting it if it is an archive, the bundle can be
+// used with runfiles.New(runfiles.Directory(...)) on another machine. The
+// format defaults to tar or zip if out ends in .tar or .zip, and to a
+// directory otherwise.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("runfilesbundle: ")
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("runfilesbundle", flag.ContinueOnError)
+	manifest := fs.String("manifest", "", "the runfiles manifest `file` to bundle")
+	dir := fs.String("dir", "", "the runfiles `directory` to bundle")
+	format := fs.String("format", "", "the `format` of the bundle: dir, tar or zip")
+	output := fs.String("o", "", "write the bundle to `path`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: runfilesbundle (-manifest file | -dir dir) [-format dir|tar|zip] -o out\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || *output == "" || (*manifest == "") == (*dir == "") {
+		fs.Usage()
+		return fmt.Errorf("expected -o and exactly one of -manifest and -dir")
+	}
+	if *format == "" {
+		switch {
+		case strings.HasSuffix(*output, ".tar"):
+			*format = "tar"
+		case strings.HasSuffix(*output, ".zip"):
+			*format = "zip"
+		default:
+			*format = "dir"
+		}
+	}
+
+	var opt runfiles.Option
+	if *manifest != "" {
+		opt = runfiles.ManifestFile(resolve(*manifest))
+	} else {
+		opt = runfiles.Directory(resolve(*dir))
+	}
+	r, err := runfiles.New(opt, runfiles.SourceRepo(""))
+	if err != nil {
+		return err
+	}
+
+	out := resolve(*output)
+	var write func(io.Writer) error
+	switch *format {
+	case "dir":
+		return r.WriteDirectory(out)
+	case "tar":
+		write = r.WriteTar
+	case "zip":
+		write = r.WriteZip
+	default:
+		return fmt.Errorf("unknown format %q, want dir, tar or zip", *format)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// resolve interprets relative paths relative to the directory runfilesbundle
+// was run from with bazel run.
+func resolve(path string) string {
+	if wd := os.Getenv("BUILD_WORKING_DIRECTORY"); wd != "" && !filepath.IsAbs(path) {
+		return filepath.Join(wd, path)
+	}
+	return path
+}
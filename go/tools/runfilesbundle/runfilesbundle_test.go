@@ -0,0 +1,220 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+// writeManifest writes files outside of a runfiles directory and a manifest
+// listing them, like Bazel does with --nobuild_runfile_links.
+func writeManifest(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"data.txt":     "data",
+		"tool":         "#!/bin/sh\n",
+		"repo_mapping": ",my_dep,my_dep+\n",
+		"tree/a.txt":   "a",
+		"tree/b/c.txt": "c",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, "execroot", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "execroot", "tool"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	execroot := filepath.Join(dir, "execroot")
+	manifest := filepath.Join(dir, "bin.runfiles_manifest")
+	if err := os.WriteFile(manifest, []byte(
+		"_main/pkg/empty.txt \n"+
+			"_main/pkg/tool "+filepath.Join(execroot, "tool")+"\n"+
+			"_repo_mapping "+filepath.Join(execroot, "repo_mapping")+"\n"+
+			"my_dep+/data.txt "+filepath.Join(execroot, "data.txt")+"\n"+
+			"my_dep+/tree "+filepath.Join(execroot, "tree")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+var wantEntries = []string{
+	"_main/",
+	"_main/pkg/",
+	"_main/pkg/empty.txt",
+	"_main/pkg/tool",
+	"_repo_mapping",
+	"my_dep+/",
+	"my_dep+/data.txt",
+	"my_dep+/tree/",
+	"my_dep+/tree/a.txt",
+	"my_dep+/tree/b/",
+	"my_dep+/tree/b/c.txt",
+	"MANIFEST",
+}
+
+const wantManifest = `_main/pkg/empty.txt 
+_main/pkg/tool _main/pkg/tool
+_repo_mapping _repo_mapping
+my_dep+/data.txt my_dep+/data.txt
+my_dep+/tree/a.txt my_dep+/tree/a.txt
+my_dep+/tree/b/c.txt my_dep+/tree/b/c.txt
+`
+
+func TestDirectory(t *testing.T) {
+	manifest := writeManifest(t)
+	out := filepath.Join(t.TempDir(), "bundle")
+	if err := run([]string{"-manifest", manifest, "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bundle is self-contained.
+	if err := os.RemoveAll(filepath.Dir(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	r, err := runfiles.New(runfiles.Directory(out), runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rlocation, want := range map[string]string{
+		"my_dep/data.txt":     "data",
+		"my_dep/tree/b/c.txt": "c",
+		"_main/pkg/empty.txt": "",
+		"my_dep+/tree/a.txt":  "a",
+		"_main/pkg/tool":      "#!/bin/sh\n",
+		"MANIFEST":            wantManifest,
+	} {
+		p, err := r.Rlocation(rlocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", rlocation, got, want)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(out, "_main", "pkg", "tool"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o755 {
+			t.Errorf("got mode %v for an executable, want 0755", info.Mode())
+		}
+	}
+}
+
+func TestTar(t *testing.T) {
+	manifest := writeManifest(t)
+	out := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := run([]string{"-manifest", manifest, "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, hdr.Name)
+		if hdr.Name == "MANIFEST" {
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != wantManifest {
+				t.Errorf("got manifest:\n%s\nwant:\n%s", content, wantManifest)
+			}
+		}
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("got entries %q, want %q", entries, wantEntries)
+	}
+
+	// The archive is deterministic.
+	again := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := run([]string{"-manifest", manifest, "-o", again}); err != nil {
+		t.Fatal(err)
+	}
+	if againData, err := ioutil.ReadFile(again); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(againData, data) {
+		t.Error("archives of the same runfiles differ")
+	}
+}
+
+func TestZipFromDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bundle")
+	if err := run([]string{"-manifest", writeManifest(t), "-o", dir}); err != nil {
+		t.Fatal(err)
+	}
+	// A bundle has the layout of a runfiles directory, so it can be bundled
+	// again, replacing its manifest.
+	out := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := run([]string{"-dir", dir, "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var entries []string
+	for _, f := range zr.File {
+		entries = append(entries, f.Name)
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("got entries %q, want %q", entries, wantEntries)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-o", "out"},
+		{"-manifest", "m", "-dir", "d", "-o", "out"},
+		{"-manifest", "m"},
+		{"-manifest", writeManifest(t), "-format", "rar", "-o", filepath.Join(t.TempDir(), "out")},
+	} {
+		if err := run(args); err == nil {
+			t.Errorf("run(%q): got no error", args)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder_worker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+go_library(
+    name = "hello_lib",
+    srcs = ["hello.go"],
+    importpath = "example.com/hello",
+)
+
+go_binary(
+    name = "hello",
+    srcs = ["main.go"],
+    deps = [":hello_lib"],
+)
+
+-- hello.go --
+package hello
+
+// Message is printed by the hello binary.
+const Message = "hello from a worker"
+
+-- main.go --
+package main
+
+import (
+	"fmt"
+
+	"example.com/hello"
+)
+
+func main() {
+	fmt.Println(hello.Message)
+}
+`,
+	})
+}
+
+const workerFlag = "--@io_bazel_rules_go//go/config:builder_worker"
+
+func TestBuildWithWorkers(t *testing.T) {
+	for _, protocol := range []string{"proto", "json"} {
+		t.Run(protocol, func(t *testing.T) {
+			out, err := bazel_testing.BazelOutput(
+				"run",
+				workerFlag,
+				"--@io_bazel_rules_go//go/config:builder_worker_protocol="+protocol,
+				"--strategy=GoCompilePkg=worker",
+				"--strategy=GoLink=worker",
+				"//:hello",
+			)
+			if err != nil {
+				t.Fatalf("bazel run failed: %v", err)
+			}
+			if got, want := strings.TrimSpace(string(out)), "hello from a worker"; got != want {
+				t.Errorf("got output %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExecutionRequirements(t *testing.T) {
+	cases := []struct {
+		mnemonic string
+		target   string
+	}{
+		{"GoCompilePkg", "//:hello_lib"},
+		{"GoLink", "//:hello"},
+	}
+	for _, c := range cases {
+		t.Run(c.mnemonic, func(t *testing.T) {
+			info := aqueryExecutionInfo(t, c.mnemonic, c.target)
+			if _, ok := info["supports-workers"]; ok {
+				t.Errorf("%s supports workers without %s", c.mnemonic, workerFlag)
+			}
+
+			info = aqueryExecutionInfo(t, c.mnemonic, c.target, workerFlag)
+			for _, key := range []string{"supports-workers", "supports-multiplex-workers"} {
+				if info[key] != "1" {
+					t.Errorf("%s: got %s=%q, want \"1\"", c.mnemonic, key, info[key])
+				}
+			}
+			if got := info["requires-worker-protocol"]; got != "proto" {
+				t.Errorf("%s: got requires-worker-protocol=%q, want \"proto\"", c.mnemonic, got)
+			}
+		})
+	}
+}
+
+// aqueryExecutionInfo returns the execution info of the single action with
+// the given mnemonic in the transitive closure of target.
+func aqueryExecutionInfo(t *testing.T, mnemonic, target string, extraFlags ...string) map[string]string {
+	t.Helper()
+	args := append([]string{"aquery", "--output=jsonproto"}, extraFlags...)
+	args = append(args, fmt.Sprintf(`mnemonic("%s", %s)`, mnemonic, target))
+	out, err := bazel_testing.BazelOutput(args...)
+	if err != nil {
+		t.Fatalf("bazel aquery failed: %v", err)
+	}
+	var parsed struct {
+		Actions []struct {
+			ExecutionInfo []struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			} `json:"executionInfo"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("failed to decode aquery output: %v\n%s", err, out)
+	}
+	if len(parsed.Actions) != 1 {
+		t.Fatalf("expected 1 %s action for %s, got %d", mnemonic, target, len(parsed.Actions))
+	}
+	info := make(map[string]string)
+	for _, kv := range parsed.Actions[0].ExecutionInfo {
+		info[kv.Key] = kv.Value
+	}
+	return info
+}
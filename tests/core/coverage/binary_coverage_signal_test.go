@@ -0,0 +1,225 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binary_coverage_signal_test
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+go_binary(
+    name = "server",
+    srcs = ["server.go"],
+    out = "server",
+)
+
+go_binary(
+    name = "graceful_server",
+    srcs = ["graceful_server.go"],
+    out = "graceful_server",
+)
+-- server.go --
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(Ready())
+	select {}
+}
+
+func Ready() string { return "ready" }
+
+func Unused() int { return 34 }
+-- graceful_server.go --
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGTERM)
+	fmt.Println("ready")
+	<-c
+	// Shut down gracefully, unless SIGTERM is received a second time.
+	select {
+	case <-c:
+		os.Exit(2)
+	case <-time.After(time.Second):
+	}
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--collect_code_coverage", "--instrumentation_filter=.*", "//:server"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "--collect_code_coverage", "--instrumentation_filter=.*", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := filepath.Join(strings.TrimSpace(string(out)), "server")
+	coverageDir := t.TempDir()
+	signalsEnv := []string{
+		"GO_BINARY_COVERAGE_SIGNALS=SIGINT,SIGTERM",
+		"GO_BINARY_COVERAGE_SNAPSHOT_SIGNAL=SIGUSR1",
+	}
+
+	// The first server takes a snapshot on SIGUSR1 before it is terminated.
+	cmd := startServer(t, server, coverageDir, signalsEnv...)
+	if err := cmd.Process.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitForProfile(t, coverageDir)
+	terminate(t, cmd, syscall.SIGTERM)
+
+	// The second server writes its coverage on SIGINT.
+	terminate(t, startServer(t, server, coverageDir, signalsEnv...), syscall.SIGINT)
+
+	files, err := filepath.Glob(filepath.Join(coverageDir, "go_coverage.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("got coverage files %v, want a .dat and a .out file for each server", files)
+	}
+
+	merged := filepath.Join(t.TempDir(), "merged.out")
+	if err := bazel_testing.RunBazel("run", "@io_bazel_rules_go//go/tools/covmerge", "--", "-format=cover", "-o", merged, coverageDir); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := os.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ready, unused string
+	for _, line := range strings.Split(string(profile), "\n") {
+		if strings.Contains(line, "server.go:10.") {
+			ready = line
+		} else if strings.Contains(line, "server.go:12.") {
+			unused = line
+		}
+	}
+	if !strings.HasSuffix(ready, " 2") || !strings.HasSuffix(unused, " 0") {
+		t.Errorf("got merged profile:\n%s\nwant Ready to be covered twice and Unused not at all", profile)
+	}
+
+	if err := bazel_testing.RunBazel("run", "@io_bazel_rules_go//go/tools/covmerge", "--", "-o", merged, coverageDir); err != nil {
+		t.Fatal(err)
+	}
+	lcov, err := os.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FNDA:2,Ready\n", "FNDA:0,Unused\n", "DA:10,2\n"} {
+		if !strings.Contains(string(lcov), want) {
+			t.Errorf("merged lcov report does not contain %q:\n%s", want, lcov)
+		}
+	}
+}
+
+// TestGracefulShutdown checks that a binary that handles SIGTERM itself
+// receives it once and writes its coverage when it exits.
+func TestGracefulShutdown(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--collect_code_coverage", "--instrumentation_filter=.*", "//:graceful_server"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "--collect_code_coverage", "--instrumentation_filter=.*", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverageDir := t.TempDir()
+	cmd := startServer(t, filepath.Join(strings.TrimSpace(string(out)), "graceful_server"), coverageDir)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("got %v from server, want it to shut down gracefully", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(coverageDir, "go_coverage.*.out")); len(files) != 1 {
+		t.Errorf("got coverage files %v, want one go cover profile", files)
+	}
+}
+
+// startServer starts the server with the given additional environment
+// variables and waits until it is ready.
+func startServer(t *testing.T, server, coverageDir string, env ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(server)
+	cmd.Env = append(append(os.Environ(), "GO_BINARY_COVERAGE_DIR="+coverageDir), env...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "ready\n" {
+		cmd.Process.Kill()
+		t.Fatalf("got %q, %v from server, want ready", line, err)
+	}
+	return cmd
+}
+
+// terminate sends sig to the server and checks that it terminates it.
+func terminate(t *testing.T, cmd *exec.Cmd, sig syscall.Signal) {
+	t.Helper()
+	if err := cmd.Process.Signal(sig); err != nil {
+		t.Fatal(err)
+	}
+	err := cmd.Wait()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("got %v from server, want it to be terminated by %v", err, sig)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); !ok || !status.Signaled() || status.Signal() != sig {
+		t.Errorf("got %v from server, want it to be terminated by %v", err, sig)
+	}
+}
+
+// waitForProfile waits until a go cover profile is written to dir, which
+// happens after the lcov report.
+func waitForProfile(t *testing.T, dir string) {
+	t.Helper()
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if files, _ := filepath.Glob(filepath.Join(dir, "go_coverage.*.out")); len(files) > 0 {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for coverage in %s", dir)
+}
@@ -296,8 +296,10 @@ func testCoverageOfChildBinaries(t *testing.T, extraArgs ...string) {
 
 	expectedCoverage := []string{
 		"SF:main.go",
-		"FNF:0",
-		"FNH:0",
+		"FN:5,main",
+		"FNDA:1,main",
+		"FNF:1",
+		"FNH:1",
 		"DA:5,1",
 		"DA:6,1",
 		"DA:7,1",
@@ -48,6 +48,14 @@ go_test(
     deps = [":lib"],
 )
 
+go_test(
+    name = "branch_test",
+    srcs = [
+        "branch.go",
+        "branch_test.go",
+    ],
+)
+
 java_binary(
     name = "Tool",
     srcs = ["Tool.java"],
@@ -102,6 +110,22 @@ func TestLib(t *testing.T) {
 		t.Error("Expected a newline in the output")
 	}
 }
+-- src/branch.go --
+package branch
+
+func sign(n int) int {
+	if n < 0 { return -1 } else { return 1 }
+}
+-- src/branch_test.go --
+package branch
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	if sign(1) != 1 {
+		t.Error("Expected 1")
+	}
+}
 -- src/Tool.java --
 public class Tool {
   public static void main(String[] args) {
@@ -196,6 +220,46 @@ func testLcovCoverage(t *testing.T, extraArgs ...string) {
 	}
 }
 
+func TestLcovBranchCoverage(t *testing.T) {
+	if err := bazel_testing.RunBazel("coverage", "--test_env=GO_LCOV_BRANCH_COVERAGE=1", "//src:lib_test", "//src:branch_test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// No line of lib.go starts more than one block, so it has no branches.
+	coveragePath := filepath.FromSlash("bazel-testlogs/src/lib_test/coverage.dat")
+	coverageData, err := ioutil.ReadFile(coveragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if section := lcovSection(t, coveragePath, coverageData, "src/lib.go"); strings.Contains(section, "BR") {
+		t.Errorf("%s: coverage of src/lib.go has branches:\n%s", coveragePath, section)
+	}
+
+	// Line 4 of branch.go starts three blocks: the if statement, which runs,
+	// its body, which doesn't, and the else body, which does.
+	coveragePath = filepath.FromSlash("bazel-testlogs/src/branch_test/coverage.dat")
+	coverageData, err = ioutil.ReadFile(coveragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := lcovSection(t, coveragePath, coverageData, "src/branch.go")
+	if want := "BRDA:4,0,0,1\nBRDA:4,0,1,0\nBRDA:4,0,2,1\nBRF:3\nBRH:2\n"; !strings.Contains(section, want) {
+		t.Errorf("%s: coverage of src/branch.go does not contain %q:\n%s", coveragePath, want, section)
+	}
+}
+
+// lcovSection returns the records of a source file in an lcov report.
+func lcovSection(t *testing.T, path string, data []byte, src string) string {
+	t.Helper()
+	section := string(data)
+	i := strings.Index(section, "SF:"+src+"\n")
+	if i < 0 {
+		t.Fatalf("%s: does not contain %s:\n%s", path, src, data)
+	}
+	section = section[i:]
+	return section[:strings.Index(section, "end_of_record")]
+}
+
 func TestLcovCoverageWithTool(t *testing.T) {
 	args := []string{
 		"coverage",
@@ -243,8 +307,10 @@ func TestLcovCoverageWithTool(t *testing.T) {
 
 var expectedGoCoverage = []string{
 	`SF:src/other_lib.go
-FNF:0
-FNH:0
+FN:3,HelloOtherLib
+FNDA:1,HelloOtherLib
+FNF:1
+FNH:1
 DA:3,1
 DA:4,1
 DA:5,0
@@ -255,8 +321,10 @@ LF:5
 end_of_record
 `,
 	`SF:src/lib.go
-FNF:0
-FNH:0
+FN:9,HelloFromLib
+FNDA:1,HelloFromLib
+FNF:1
+FNH:1
 DA:9,1
 DA:10,1
 DA:11,1
@@ -108,8 +108,10 @@ func TestLcovCoverageWithTestMain(t *testing.T) {
 }
 
 const expectedIndividualCoverage = `SF:src/lib.go
-FNF:0
-FNH:0
+FN:3,HelloFromLib
+FNDA:1,HelloFromLib
+FNF:1
+FNH:1
 DA:3,1
 DA:4,1
 DA:5,0
@@ -0,0 +1,137 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package per_test_coverage_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- src/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "lib_test",
+    srcs = ["lib_test.go"],
+    deps = [":lib"],
+)
+-- src/lib.go --
+package lib
+
+func Formal() string {
+	return "Good morning"
+}
+
+func Informal() string {
+	return "Hey there"
+}
+-- src/lib_test.go --
+package lib_test
+
+import (
+	"testing"
+
+	"example.com/lib"
+)
+
+func TestFormal(t *testing.T) {
+	lib.Formal()
+}
+
+func TestBoth(t *testing.T) {
+	lib.Formal()
+	lib.Informal()
+}
+
+func TestNothing(t *testing.T) {}
+`,
+	})
+}
+
+func TestPerTestCoverage(t *testing.T) {
+	if err := bazel_testing.RunBazel("coverage", "--nozip_undeclared_test_outputs", "--test_env=GO_TEST_PER_TEST_COVERAGE=1", "//src:lib_test"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testlogs := filepath.Join(strings.TrimSpace(string(out)), "src", "lib_test")
+	data, err := os.ReadFile(filepath.Join(testlogs, "test.outputs", "per_test_coverage.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`TN:TestFormal
+SF:src/lib.go
+FN:3,Formal
+FN:7,Informal
+FNDA:1,Formal
+FNDA:0,Informal
+FNF:2
+FNH:1
+DA:3,1
+DA:4,1
+DA:5,1
+DA:7,0
+DA:8,0
+DA:9,0
+LH:3
+LF:6
+end_of_record
+`,
+		`TN:TestBoth
+SF:src/lib.go
+FN:3,Formal
+FN:7,Informal
+FNDA:1,Formal
+FNDA:1,Informal
+FNF:2
+FNH:2
+`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("per-test coverage does not contain:\n%s\ngot:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestNothing") {
+		t.Errorf("got coverage for TestNothing, which covers nothing:\n%s", got)
+	}
+
+	// The coverage of the whole test is still reported.
+	data, err = os.ReadFile(filepath.Join(testlogs, "coverage.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FNDA:2,Formal\n", "FNDA:1,Informal\n", "DA:4,2\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("coverage.dat does not contain %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark_output_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "bench_test",
+    srcs = ["bench_test.go"],
+    importpath = "example.com/bench",
+)
+
+-- bench_test.go --
+package bench
+
+import "testing"
+
+func TestNothing(t *testing.T) {}
+
+func BenchmarkSum(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := make([]int, 16)
+		for j := range s {
+			s[0] += j
+		}
+	}
+	b.ReportMetric(16, "items/op")
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "--nozip_undeclared_test_outputs", "--test_arg=-test.bench=.", "--test_arg=-test.benchtime=10x", "--test_arg=-test.count=3", "//:bench_test"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := filepath.Join(strings.TrimSpace(string(out)), "bench_test", "test.outputs")
+
+	text, err := os.ReadFile(filepath.Join(outputs, "benchmarks.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "pkg: example.com/bench\n") || strings.Count(string(text), "BenchmarkSum") != 3 {
+		t.Errorf("unexpected benchmarks.txt:\n%s", text)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputs, "benchmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Benchmarks []struct {
+			Name    string
+			Runs    int
+			Metrics []struct {
+				Unit   string
+				Values []float64
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Benchmarks) != 1 || summary.Benchmarks[0].Name != "BenchmarkSum" || summary.Benchmarks[0].Runs != 3 {
+		t.Fatalf("unexpected benchmarks.json:\n%s", data)
+	}
+	var units []string
+	for _, m := range summary.Benchmarks[0].Metrics {
+		units = append(units, m.Unit)
+	}
+	if got, want := strings.Join(units, " "), "ns/op items/op B/op allocs/op"; got != want {
+		t.Errorf("got units %q, want %q", got, want)
+	}
+
+	// Results compared with themselves don't change.
+	path := filepath.Join(outputs, "benchmarks.txt")
+	compared, err := bazel_testing.BazelOutput("run", "@io_bazel_rules_go//go/tools/benchcompare", "--", "-max_regression=0", path, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(compared), "BenchmarkSum") || !strings.Contains(string(compared), "~ (p=1.000 n=3+3)") {
+		t.Errorf("unexpected comparison:\n%s", compared)
+	}
+}
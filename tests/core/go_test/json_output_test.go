@@ -0,0 +1,101 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json_output_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "events_test",
+    srcs = ["events_test.go"],
+    importpath = "example.com/events",
+)
+
+-- events_test.go --
+package events
+
+import "testing"
+
+func TestLog(t *testing.T) {
+	t.Log("hello")
+}
+
+func TestSkip(t *testing.T) {
+	t.Skip("not today")
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "--nozip_undeclared_test_outputs", "--test_env=GO_TEST_WRAP_TESTV=1", "//:events_test"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "events_test", "test.outputs", "test2json.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make(map[string]string)
+	var output strings.Builder
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		var e struct {
+			Action  string
+			Package string
+			Test    string
+			Output  string
+		}
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", s.Text(), err)
+		}
+		if e.Package != "example.com/events" {
+			t.Errorf("got package %q in event %q", e.Package, s.Text())
+		}
+		if e.Action == "output" {
+			output.WriteString(e.Output)
+		} else {
+			actions[e.Test] = e.Action
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if actions["TestLog"] != "pass" || actions["TestSkip"] != "skip" || actions[""] != "pass" {
+		t.Errorf("unexpected final actions %v in events:\n%s", actions, data)
+	}
+	if !strings.Contains(output.String(), "hello") {
+		t.Errorf("events don't contain the output of TestLog:\n%s", data)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leak_check_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "leaky_test",
+    srcs = ["leaky_test.go"],
+)
+
+go_test(
+    name = "leaky_main_test",
+    srcs = ["leaky_main_test.go"],
+)
+
+-- leaky_test.go --
+package leaky
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+var block = make(chan struct{})
+
+func TestLeak(t *testing.T) {
+	go func() { <-block }()
+	if runtime.GOOS == "linux" {
+		if err := exec.Command("sleep", "60").Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+-- leaky_main_test.go --
+package leaky_main
+
+import (
+	"os"
+	"testing"
+)
+
+var block = make(chan struct{})
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+func TestLeak(t *testing.T) {
+	go func() { <-block }()
+}
+`,
+	})
+}
+
+type xmlTestSuites struct {
+	Suites []struct {
+		TestCases []struct {
+			Name      string  `xml:"name,attr"`
+			Failure   *string `xml:"failure"`
+			SystemOut string  `xml:"system-out"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func readTestLog(t *testing.T, target, name string) string {
+	t.Helper()
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), target, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// leakCheckReport returns the output of the LeakCheck test case in the test
+// XML output and whether it failed.
+func leakCheckReport(t *testing.T) (string, bool) {
+	t.Helper()
+	var suites xmlTestSuites
+	if err := xml.Unmarshal([]byte(readTestLog(t, "leaky_test", "test.xml")), &suites); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range suites.Suites {
+		for _, c := range s.TestCases {
+			if c.Name != "LeakCheck" {
+				continue
+			}
+			if c.Failure != nil {
+				return *c.Failure, true
+			}
+			return c.SystemOut, false
+		}
+	}
+	t.Fatalf("no LeakCheck test case in the test XML output: %#v", suites)
+	return "", false
+}
+
+func checkReport(t *testing.T, report, prefix string) {
+	t.Helper()
+	want := []string{prefix, "leaky.TestLeak.func1()"}
+	if runtime.GOOS == "linux" {
+		want = append(want, "sleep 60")
+	}
+	for _, w := range want {
+		if !strings.Contains(report, w) {
+			t.Errorf("leak report does not contain %q:\n%s", w, report)
+		}
+	}
+}
+
+func TestLeakCheckFail(t *testing.T) {
+	err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_LEAK_CHECK=fail", "//:leaky_test")
+	if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+		t.Fatalf("expected bazel test to fail with exit code 3 (TESTS_FAILED), got: %v", err)
+	}
+	checkReport(t, readTestLog(t, "leaky_test", "test.log"), "LEAK: ")
+	report, failed := leakCheckReport(t)
+	if !failed {
+		t.Errorf("LeakCheck test case passed:\n%s", report)
+	}
+	checkReport(t, report, "LEAK: ")
+}
+
+func TestLeakCheckWarn(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_LEAK_CHECK=warn", "//:leaky_test"); err != nil {
+		t.Fatal(err)
+	}
+	report, failed := leakCheckReport(t)
+	if failed {
+		t.Errorf("LeakCheck test case failed:\n%s", report)
+	}
+	checkReport(t, report, "LEAK WARNING: ")
+}
+
+func TestNoLeakCheck(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//:leaky_test"); err != nil {
+		t.Fatal(err)
+	}
+	if log := readTestLog(t, "leaky_test", "test.log"); strings.Contains(log, "LEAK") {
+		t.Errorf("unexpected leak report without GO_TEST_LEAK_CHECK:\n%s", log)
+	}
+}
+
+func TestLeakCheckTestMainExit(t *testing.T) {
+	err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_LEAK_CHECK=fail", "//:leaky_main_test")
+	if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+		t.Fatalf("expected bazel test to fail with exit code 3 (TESTS_FAILED), got: %v", err)
+	}
+	if log := readTestLog(t, "leaky_main_test", "test.log"); !strings.Contains(log, "LEAK: 1 goroutine is still running after the tests") {
+		t.Errorf("test log does not report the leak:\n%s", log)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "flaky_test",
+    srcs = [
+        "flaky.go",
+        "flaky_test.go",
+    ],
+)
+
+go_test(
+    name = "broken_test",
+    srcs = ["broken_test.go"],
+)
+
+-- flaky.go --
+package flaky
+
+func passing() int {
+	return 1
+}
+
+func flaky() int {
+	return 2
+}
+
+-- flaky_test.go --
+package flaky
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPass(t *testing.T) {
+	passing()
+}
+
+func TestFlaky(t *testing.T) {
+	marker := filepath.Join(os.Getenv("TEST_TMPDIR"), "flaky")
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		os.WriteFile(marker, nil, 0o666)
+		t.Fatal("fails on the first run")
+	}
+	flaky()
+}
+
+-- broken_test.go --
+package broken
+
+import "testing"
+
+func TestBroken(t *testing.T) {
+	t.Fatal("always fails")
+}
+`,
+	})
+}
+
+type xmlTestSuites struct {
+	Suites []struct {
+		Failures  int `xml:"failures,attr"`
+		TestCases []struct {
+			Name          string   `xml:"name,attr"`
+			Failure       *string  `xml:"failure"`
+			FlakyFailures []string `xml:"flakyFailure"`
+			RerunFailures []string `xml:"rerunFailure"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func readTestLog(t *testing.T, target, name string) []byte {
+	t.Helper()
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), target, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readReport(t *testing.T, target string) xmlTestSuites {
+	t.Helper()
+	data := readTestLog(t, target, "test.xml")
+	var suites xmlTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	return suites
+}
+
+func TestFlaky(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_WRAP_RETRIES=2", "//:flaky_test"); err != nil {
+		t.Fatal(err)
+	}
+	suites := readReport(t, "flaky_test")
+	if len(suites.Suites) != 1 || suites.Suites[0].Failures != 0 {
+		t.Fatalf("unexpected test suites: %#v", suites)
+	}
+	c := suites.Suites[0].TestCases[0]
+	if c.Name != "TestFlaky" || c.Failure != nil || len(c.FlakyFailures) != 1 || !strings.Contains(c.FlakyFailures[0], "fails on the first run") {
+		t.Errorf("unexpected test case: %#v", c)
+	}
+	// Only the failed test is rerun.
+	if log := readTestLog(t, "flaky_test", "test.log"); !strings.Contains(string(log), "=== RETRY attempt 2 of 3: TestFlaky\n") {
+		t.Errorf("unexpected test log:\n%s", log)
+	}
+}
+
+func TestBroken(t *testing.T) {
+	err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_WRAP_RETRIES=2", "//:broken_test")
+	if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+		t.Fatalf("expected bazel test to fail with exit code 3 (TESTS_FAILED), got: %v", err)
+	}
+	suites := readReport(t, "broken_test")
+	if len(suites.Suites) != 1 || suites.Suites[0].Failures != 1 {
+		t.Fatalf("unexpected test suites: %#v", suites)
+	}
+	c := suites.Suites[0].TestCases[0]
+	if c.Failure == nil || len(c.RerunFailures) != 2 {
+		t.Errorf("unexpected test case: %#v", c)
+	}
+}
+
+func TestFlakyCoverage(t *testing.T) {
+	if err := bazel_testing.RunBazel("coverage", "--test_env=GO_TEST_WRAP_RETRIES=2", "--@io_bazel_rules_go//go/config:cover_format=go_cover", "//:flaky_test"); err != nil {
+		t.Fatal(err)
+	}
+	// passing is only covered by the first run and flaky by the rerun.
+	profile := readTestLog(t, "flaky_test", "coverage.dat")
+	blocks := 0
+	for _, line := range strings.Split(string(profile), "\n") {
+		if !strings.Contains(line, "flaky.go:") {
+			continue
+		}
+		blocks++
+		if strings.HasSuffix(line, " 0") {
+			t.Errorf("block is not covered: %s", line)
+		}
+	}
+	if blocks != 2 {
+		t.Errorf("got %d blocks of flaky.go in coverage profile, want 2:\n%s", blocks, profile)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard_strategy_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "duration_test",
+    srcs = ["shard_test.go"],
+    shard_count = 2,
+    shard_strategy = "duration",
+    shard_timings = "timings.json",
+)
+
+go_test(
+    name = "hash_test",
+    srcs = ["shard_test.go"],
+    shard_count = 2,
+    shard_strategy = "hash",
+)
+
+go_test(
+    name = "missing_timings_test",
+    srcs = ["shard_test.go"],
+    shard_strategy = "duration",
+    tags = ["manual"],
+)
+
+-- timings.json --
+{
+  "TestSlow": 90,
+  "TestMedium": 30,
+  "ExampleFast": 1
+}
+
+-- shard_test.go --
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSlow(t *testing.T) {}
+
+func TestMedium(t *testing.T) {}
+
+func TestOther(t *testing.T) {}
+
+func ExampleFast() {
+	fmt.Println("fast")
+	// Output: fast
+}
+`,
+	})
+}
+
+type xmlProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type xmlTestSuites struct {
+	Suites []struct {
+		Properties []xmlProperty `xml:"properties>property"`
+		TestCases  []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+// shardTests returns the names of the tests run by each shard of target and
+// checks the properties recorded in their XML reports.
+func shardTests(t *testing.T, target, strategy string) [][]string {
+	t.Helper()
+	if err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_WRAP_TESTV=1", "//:"+target); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards [][]string
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(strings.TrimSpace(string(out)), target, fmt.Sprintf("shard_%d_of_2", i+1), "test.xml")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var suites xmlTestSuites
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			t.Fatal(err)
+		}
+		wantProperties := []xmlProperty{
+			{Name: "shard_strategy", Value: strategy},
+			{Name: "shard_index", Value: fmt.Sprint(i)},
+			{Name: "shard_count", Value: "2"},
+		}
+		var names []string
+		for _, s := range suites.Suites {
+			if !reflect.DeepEqual(s.Properties, wantProperties) {
+				t.Errorf("got properties %v in shard %d, want %v", s.Properties, i, wantProperties)
+			}
+			for _, c := range s.TestCases {
+				names = append(names, c.Name)
+			}
+		}
+		sort.Strings(names)
+		shards = append(shards, names)
+	}
+	return shards
+}
+
+func TestDuration(t *testing.T) {
+	got := shardTests(t, "duration_test", "duration")
+	want := [][]string{{"TestSlow"}, {"ExampleFast", "TestMedium", "TestOther"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got shards %q, want %q", got, want)
+	}
+}
+
+func TestHash(t *testing.T) {
+	got := shardTests(t, "hash_test", "hash")
+	var all []string
+	for _, names := range got {
+		all = append(all, names...)
+	}
+	sort.Strings(all)
+	if want := []string{"ExampleFast", "TestMedium", "TestOther", "TestSlow"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("got tests %q in all shards, want %q", all, want)
+	}
+}
+
+func TestMissingTimings(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//:missing_timings_test")
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !strings.Contains(err.Error(), `shard_timings must be set if and only if shard_strategy = "duration" is set`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
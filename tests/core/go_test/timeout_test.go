@@ -42,7 +42,7 @@ func TestTimeout(t *testing.T) {
 	}
 
 	var stderr string
-	if err := bazel_testing.RunBazel("test", "//:timeout_test", "--test_timeout=3", "--test_arg=-test.v"); err == nil {
+	if err := bazel_testing.RunBazel("test", "//:timeout_test", "--test_timeout=3", "--test_arg=-test.v", "--nozip_undeclared_test_outputs"); err == nil {
 		t.Fatal("expected bazel test to fail")
 	} else if exitErr, ok := err.(*bazel_testing.StderrExitError); !ok || exitErr.Err.ExitCode() != 3 {
 		t.Fatalf("expected bazel test to fail with exit code 3, got %v", err)
@@ -82,4 +82,24 @@ func TestTimeout(t *testing.T) {
 	if !strings.Contains(testXML, `<testcase classname="timeout_test" name="TestFoo"`) {
 		t.Errorf("test XML does not contain expected element:\n%s", testXML)
 	}
+	// The XML only contains the stack of the test and a summary of the other
+	// goroutines, which are written to a separate file.
+	if !strings.Contains(testXML, "goroutines.txt") || !strings.Contains(testXML, "timeout_test.neverTerminates(") {
+		t.Errorf("test XML does not contain the summary of the goroutines:\n%s", testXML)
+	}
+	if strings.Contains(testXML, "startAlarm") {
+		t.Errorf("test XML contains the goroutine dump:\n%s", testXML)
+	}
+
+	path = filepath.Join(strings.TrimSpace(string(p)), "timeout_test/test.outputs/goroutines.txt")
+	b, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read goroutine dump: %s", err)
+	}
+	dump := string(b)
+	for _, want := range []string{"panic: test timed out after 3s\n", "\tTestFoo (", "testing.(*M).startAlarm", "timeout_test.neverTerminates("} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("goroutine dump does not contain %q:\n%s", want, dump)
+		}
+	}
 }
@@ -198,3 +198,52 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestTestCaseDetails(t *testing.T) {
+	err := bazel_testing.RunBazel("test", "--test_env=GO_TEST_WRAP_TESTV=1", "//:xml_test")
+	if xerr, ok := err.(*bazel_testing.StderrExitError); !ok || xerr.Err.ExitCode() != 3 {
+		t.Fatalf("expected bazel tests to fail with exit code 3 (TESTS_FAILED), got: %v", err)
+	}
+	p, err := bazel_testing.BazelOutput("info", "bazel-testlogs")
+	if err != nil {
+		t.Fatalf("could not find testlog root: %s", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(strings.TrimSpace(string(p)), "xml_test/test.xml"))
+	if err != nil {
+		t.Fatalf("could not read generated xml file: %s", err)
+	}
+	var suites struct {
+		Suites []struct {
+			TestCases []struct {
+				Classname string `xml:"classname,attr"`
+				Name      string `xml:"name,attr"`
+				File      string `xml:"file,attr"`
+				Line      string `xml:"line,attr"`
+				SystemOut string `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(b, &suites); err != nil {
+		t.Fatalf("could not unmarshall generated xml: %s", err)
+	}
+	found := 0
+	for _, s := range suites.Suites {
+		for _, c := range s.TestCases {
+			switch c.Name {
+			case "TestPassLog":
+				found++
+				if c.File != "xml_test.go" || c.Line == "" || !strings.Contains(c.SystemOut, "pass") {
+					t.Errorf("unexpected test case: %#v", c)
+				}
+			case "TestSubtests/testB":
+				found++
+				if c.Classname != "xml_test.TestSubtests" || !strings.Contains(c.SystemOut, "from subtest testB") {
+					t.Errorf("unexpected test case: %#v", c)
+				}
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("found %d of 2 test cases:\n%s", found, b)
+	}
+}
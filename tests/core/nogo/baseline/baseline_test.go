@@ -0,0 +1,129 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    baseline = "baseline.json",
+    deps = ["@org_golang_x_tools//go/analysis/passes/bools"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+-- baseline.json --
+{"version": 1, "findings": []}
+
+-- lib.go --
+package lib
+
+func F(a bool) bool {
+	return a || a
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	// Without a baseline entry, the finding fails the build.
+	if err := bazel_testing.RunBazel("build", "//:lib"); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	// Create the baseline from the report.
+	out, err := bazel_testing.BazelOutput("cquery", "--output=files", "--output_groups=nogo_report", "//:lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("build", "--norun_validations", "--output_groups=nogo_report", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "nogo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Diagnostics []struct {
+			Analyzer    string
+			Fingerprint string
+			Range       struct{ File string }
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1:\n%s", len(report.Diagnostics), data)
+	}
+	d := report.Diagnostics[0]
+	baseline := `{"version": 1, "findings": [{"analyzer": "` + d.Analyzer + `", "file": "` + d.Range.File + `", "fingerprint": "` + d.Fingerprint + `", "count": 1}]}`
+	if err := os.WriteFile("baseline.json", []byte(baseline), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("build", "//:lib"); err != nil {
+		t.Fatalf("unexpected error with baseline: %v", err)
+	}
+
+	// Moving the finding to another line keeps it suppressed, but a new
+	// finding is reported.
+	src := `package lib
+
+// F is documented now.
+func F(a bool) bool {
+	return a || a
+}
+
+func G(b bool) bool {
+	return b || b
+}
+`
+	if err := os.WriteFile("lib.go", []byte(src), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := bazel_testing.BazelCmd("build", "//:lib")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if !strings.Contains(stderr.String(), "lib.go:9:9: redundant or: b || b (bools)") {
+		t.Errorf("new finding not reported:\n%s", stderr)
+	}
+	if strings.Contains(stderr.String(), "a || a") {
+		t.Errorf("baseline finding reported:\n%s", stderr)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [
+        "@org_golang_x_tools//go/analysis/passes/assign",
+        "@org_golang_x_tools//go/analysis/passes/stringintconv",
+    ],
+    config = "config.json",
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/a",
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    embed = [":a"],
+)
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/b",
+)
+
+-- config.json --
+{
+  "stringintconv": {
+    "fix_policy": "regex",
+    "fix_message_regex": "rune"
+  }
+}
+
+-- a.go --
+package a
+
+func A(x int) int {
+	x = x
+	return x
+}
+
+-- a_test.go --
+package a
+
+import "testing"
+
+func TestA(t *testing.T) {
+	A(1)
+}
+
+-- b.go --
+package b
+
+func B(i int) string {
+	return string(i)
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "--norun_validations", "--output_groups=nogo_fix", "//..."); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("cquery", "--norun_validations", "--output=files", "--output_groups=nogo_fix", "//...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := filepath.Join(t.TempDir(), "reports")
+	if err := os.WriteFile(reports, out, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	nogofix := func(args ...string) (string, string) {
+		t.Helper()
+		args = append([]string{"run", "@go_sdk//:builder", "--", "nogofix", "-reports", reports}, args...)
+		stdout, stderr, err := bazel_testing.BazelOutputWithInput(nil, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(stdout), string(stderr)
+	}
+	readFile := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// stringintconv suggests formatting the number or converting it to a rune,
+	// but only the latter is allowed by the config.
+	var fixes struct {
+		Diagnostics []struct {
+			Analyzer     string
+			Alternatives []struct {
+				Message string
+			}
+			Selected int
+		}
+	}
+	found := false
+	for _, dir := range strings.Fields(string(out)) {
+		data, err := os.ReadFile(filepath.Join(dir, "nogo_fix.json"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &fixes); err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range fixes.Diagnostics {
+			if d.Analyzer != "stringintconv" {
+				continue
+			}
+			found = true
+			if len(d.Alternatives) != 2 || d.Selected != 1 || !strings.Contains(d.Alternatives[1].Message, "rune") {
+				t.Errorf("unexpected fixes of stringintconv:\n%s", data)
+			}
+		}
+	}
+	if !found {
+		t.Error("no fixes of stringintconv were written")
+	}
+
+	patch, _ := nogofix("-dry_run", "-analyzers", "stringintconv")
+	if !strings.Contains(patch, "+\treturn string(rune(i))") {
+		t.Errorf("patch does not contain the fix of stringintconv:\n%s", patch)
+	}
+	if strings.Contains(patch, "a.go") {
+		t.Errorf("patch contains the fix of assign:\n%s", patch)
+	}
+	if !strings.Contains(readFile("b.go"), "return string(i)") {
+		t.Error("b.go was modified in dry-run mode")
+	}
+
+	// The fix in a.go is reported for a and a_test, but only applied once.
+	_, summary := nogofix()
+	if !strings.Contains(summary, "applied 2 fixes to 2 files, skipped 0 fixes") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+	if a := readFile("a.go"); strings.Contains(a, "x = x") || !strings.Contains(a, "return x") {
+		t.Errorf("a.go was not fixed:\n%s", a)
+	}
+	if b := readFile("b.go"); !strings.Contains(b, "return string(rune(i))") {
+		t.Errorf("b.go was not fixed:\n%s", b)
+	}
+}
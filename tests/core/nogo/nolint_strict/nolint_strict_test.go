@@ -0,0 +1,135 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nolint_strict_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = ["@org_golang_x_tools//go/analysis/passes/bools"],
+    strict_nolint = True,
+    require_nolint_justification = True,
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "justified",
+    srcs = ["justified.go"],
+    importpath = "example.com/justified",
+)
+
+go_library(
+    name = "unjustified",
+    srcs = ["unjustified.go"],
+    importpath = "example.com/unjustified",
+)
+
+go_library(
+    name = "unknown",
+    srcs = ["unknown.go"],
+    importpath = "example.com/unknown",
+)
+
+go_library(
+    name = "unused",
+    srcs = ["unused.go"],
+    importpath = "example.com/unused",
+)
+
+-- justified.go --
+package justified
+
+func F(a bool) bool {
+	return a || a //nolint:bools // a is evaluated twice on purpose
+}
+
+-- unjustified.go --
+package unjustified
+
+func F(a bool) bool {
+	return a || a //nolint:bools
+}
+
+-- unknown.go --
+package unknown
+
+func F(a bool) bool {
+	return a || a //nolint:bools,errcheck // errcheck is run by another linter
+}
+
+-- unused.go --
+package unused
+
+func F(a bool) bool {
+	return a //nolint:bools // no longer needed
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	for _, tc := range []struct {
+		target     string
+		wantOutput string
+	}{
+		{
+			target: "//:justified",
+		},
+		{
+			target:     "//:unjustified",
+			wantOutput: `unjustified.go:4:16: nolint directive has no justification, add one after "//" (nolint)`,
+		},
+		{
+			target:     "//:unknown",
+			wantOutput: `unknown.go:4:16: nolint directive names unknown analyzer "errcheck" (nolint)`,
+		},
+		{
+			target:     "//:unused",
+			wantOutput: `unused.go:4:11: nolint directive is unused for analyzer "bools" (nolint)`,
+		},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", tc.target)
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if tc.wantOutput == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v\n%s", err, stderr)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("unexpected success:\n%s", stderr)
+			}
+			if !strings.Contains(stderr.String(), tc.wantOutput) {
+				t.Errorf("output did not contain %q:\n%s", tc.wantOutput, stderr)
+			}
+		})
+	}
+}
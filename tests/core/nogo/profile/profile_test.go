@@ -0,0 +1,140 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [
+        "@org_golang_x_tools//go/analysis/passes/bools",
+        "@org_golang_x_tools//go/analysis/passes/printf",
+    ],
+    profile = True,
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/a",
+    deps = [":b"],
+)
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/b",
+)
+
+-- a.go --
+package a
+
+import (
+	"fmt"
+
+	"example.com/b"
+)
+
+func F() {
+	fmt.Println(b.B)
+}
+
+-- b.go --
+package b
+
+const B = 1
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("cquery", "--output=files", "--output_groups=nogo_profile", "//:a", "//:b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("build", "--output_groups=nogo_profile", "//:a", "//:b"); err != nil {
+		t.Fatal(err)
+	}
+	dirs := strings.Fields(string(out))
+	if len(dirs) != 2 {
+		t.Fatalf("got output directories %v, want 2", dirs)
+	}
+
+	type analyzerProfile struct {
+		Name string
+	}
+	var profile struct {
+		Package   string
+		Total     int64 `json:"total_ns"`
+		Analyzers []analyzerProfile
+	}
+	packages := make(map[string]bool)
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "nogo_profile.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		profile.Analyzers = nil
+		if err := json.Unmarshal(data, &profile); err != nil {
+			t.Fatal(err)
+		}
+		packages[profile.Package] = true
+		if profile.Total <= 0 {
+			t.Errorf("profile has no total time:\n%s", data)
+		}
+		analyzers := make(map[string]bool)
+		for _, a := range profile.Analyzers {
+			analyzers[a.Name] = true
+		}
+		for _, name := range []string{"bools", "printf"} {
+			if !analyzers[name] {
+				t.Errorf("profile has no entry for analyzer %q:\n%s", name, data)
+			}
+		}
+	}
+	if !packages["example.com/a"] || !packages["example.com/b"] {
+		t.Errorf("got profiles for packages %v, want example.com/a and example.com/b", packages)
+	}
+
+	reports := filepath.Join(t.TempDir(), "reports")
+	if err := os.WriteFile(reports, out, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	report, err := bazel_testing.BazelOutput("run", "@go_sdk//:builder", "--", "nogoprofile", "-reports", reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"nogo profile of 2 packages", "example.com/a", "example.com/b", "printf"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    config = "config.json",
+    deps = ["@org_golang_x_tools//go/analysis/passes/bools"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = [
+        "excluded.go",
+        "lib.go",
+    ],
+    importpath = "example.com/lib",
+)
+
+-- config.json --
+{
+  "bools": {
+    "exclude_files": {
+      "excluded\\.go": ""
+    }
+  }
+}
+
+-- lib.go --
+package lib
+
+func F(a bool) bool {
+	_ = a || a
+	return a || a //nolint:bools
+}
+
+-- excluded.go --
+package lib
+
+func G(a bool) bool {
+	return a || a
+}
+`,
+	})
+}
+
+type report struct {
+	Package     string
+	Diagnostics []struct {
+		Analyzer     string
+		Message      string
+		SuppressedBy string `json:"suppressed_by"`
+		Range        struct {
+			File      string
+			StartLine int `json:"start_line"`
+		}
+	}
+}
+
+func Test(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("cquery", "--output=files", "--output_groups=nogo_report", "//:lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("build", "--norun_validations", "--output_groups=nogo_report", "//:lib"); err != nil {
+		t.Fatal(err)
+	}
+	reportDir := strings.TrimSpace(string(out))
+
+	data, err := os.ReadFile(filepath.Join(reportDir, "nogo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Package != "example.com/lib" {
+		t.Errorf("got package %q, want %q", r.Package, "example.com/lib")
+	}
+	var got []string
+	for _, d := range r.Diagnostics {
+		if d.Analyzer != "bools" {
+			t.Errorf("unexpected analyzer %q", d.Analyzer)
+		}
+		got = append(got, filepath.Base(d.Range.File)+":"+d.SuppressedBy)
+	}
+	want := []string{"excluded.go:exclude_files", "lib.go:", "lib.go:nolint"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got diagnostics %v, want %v", got, want)
+	}
+
+	data, err = os.ReadFile(filepath.Join(reportDir, "nogo.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sarif struct {
+		Version string
+		Runs    []struct {
+			ColumnKind string
+			Results    []struct {
+				RuleID       string
+				Suppressions []struct{ Kind string }
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &sarif); err != nil {
+		t.Fatal(err)
+	}
+	if sarif.Version != "2.1.0" || len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 3 {
+		t.Fatalf("unexpected SARIF report:\n%s", data)
+	}
+	if sarif.Runs[0].ColumnKind != "unicodeCodePoints" {
+		t.Errorf("got column kind %q, want unicodeCodePoints", sarif.Runs[0].ColumnKind)
+	}
+	if n := len(sarif.Runs[0].Results[1].Suppressions); n != 0 {
+		t.Errorf("reported diagnostic has %d suppressions, want 0", n)
+	}
+}
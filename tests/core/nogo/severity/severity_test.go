@@ -0,0 +1,132 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package severity_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    config = "config.json",
+    deps = ["@org_golang_x_tools//go/analysis/passes/bools"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "warning",
+    srcs = ["warning.go"],
+    importpath = "example.com/warning",
+)
+
+go_library(
+    name = "info",
+    srcs = ["info.go"],
+    importpath = "example.com/info",
+)
+
+go_library(
+    name = "error",
+    srcs = ["critical/error.go"],
+    importpath = "example.com/error",
+)
+
+-- config.json --
+{
+  "_base": {
+    "severity": "warning"
+  },
+  "bools": {
+    "severity_overrides": {
+      "info\\.go": "info",
+      "critical/": "error"
+    }
+  }
+}
+
+-- warning.go --
+package warning
+
+func F(a bool) bool {
+	return a || a
+}
+
+-- info.go --
+package info
+
+func F(a bool) bool {
+	return a || a
+}
+
+-- critical/error.go --
+package error
+
+func F(a bool) bool {
+	return a || a
+}
+`,
+	})
+}
+
+func Test(t *testing.T) {
+	for _, tc := range []struct {
+		target      string
+		wantSuccess bool
+		wantOutput  string
+	}{
+		{
+			target:      "//:warning",
+			wantSuccess: true,
+			wantOutput:  "warnings found by nogo during build-time code analysis:\nwarning.go:4:9: redundant or: a || a (bools)",
+		},
+		{
+			target:      "//:info",
+			wantSuccess: true,
+		},
+		{
+			target:     "//:error",
+			wantOutput: "errors found by nogo during build-time code analysis:\ncritical/error.go:4:9: redundant or: a || a (bools)",
+		},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			cmd := bazel_testing.BazelCmd("build", tc.target)
+			stderr := &bytes.Buffer{}
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			if err == nil && !tc.wantSuccess {
+				t.Fatalf("unexpected success:\n%s", stderr)
+			} else if err != nil && tc.wantSuccess {
+				t.Fatalf("unexpected error: %v\n%s", err, stderr)
+			}
+			if tc.wantOutput != "" && !strings.Contains(stderr.String(), tc.wantOutput) {
+				t.Errorf("output did not contain %q:\n%s", tc.wantOutput, stderr)
+			}
+			if tc.wantOutput == "" && strings.Contains(stderr.String(), "redundant or") {
+				t.Errorf("unexpected finding in output:\n%s", stderr)
+			}
+		})
+	}
+}
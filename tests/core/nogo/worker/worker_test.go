@@ -0,0 +1,193 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [
+        "@org_golang_x_tools//go/analysis/passes/bools",
+        "@org_golang_x_tools//go/analysis/passes/printf",
+    ],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/a",
+)
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/b",
+    deps = [":a"],
+)
+
+go_library(
+    name = "c",
+    srcs = ["c.go"],
+    importpath = "example.com/c",
+    deps = [":b"],
+)
+
+go_library(
+    name = "d",
+    srcs = ["d.go"],
+    importpath = "example.com/d",
+    deps = [
+        ":a",
+        ":c",
+    ],
+)
+
+-- a.go --
+package a
+
+import "fmt"
+
+type Shown struct{}
+
+func (Shown) Logf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+
+type Hidden struct{}
+
+func (Hidden) Logf(format string, args ...interface{}) { fmt.Printf(format, args...) }
+
+func Wrap(format string, args ...interface{}) { fmt.Printf(format, args...) }
+
+-- b.go --
+package b
+
+import "example.com/a"
+
+func Get() a.Shown { return a.Shown{} }
+
+func Use() {
+	var h a.Hidden
+	h.Logf("%d", "x")
+	a.Wrap("%s", 1)
+}
+
+-- c.go --
+package c
+
+import "example.com/b"
+
+func C(x bool) bool {
+	b.Get().Logf("%d", "s")
+	return x && x
+}
+
+-- d.go --
+package d
+
+import (
+	"example.com/a"
+	"example.com/c"
+)
+
+func D() {
+	c.C(true)
+	a.Wrap("%d", "s")
+}
+`,
+	})
+}
+
+var findingRe = regexp.MustCompile(`(?m)^\S+\.go:\d+:\d+: .*$`)
+
+// build builds all packages and returns the sorted nogo findings as well as
+// the contents of the facts files by path.
+func build(t *testing.T, flags ...string) ([]string, map[string][]byte) {
+	t.Helper()
+	if err := bazel_testing.RunBazel("clean"); err != nil {
+		t.Fatal(err)
+	}
+	args := append([]string{"build", "--keep_going"}, flags...)
+	err := bazel_testing.RunBazel(append(args, "//:a", "//:b", "//:c", "//:d")...)
+	var stderrErr *bazel_testing.StderrExitError
+	if !errors.As(err, &stderrErr) {
+		t.Fatalf("got error %v, want nogo findings", err)
+	}
+	findings := findingRe.FindAllString(string(stderrErr.Err.Stderr), -1)
+	sort.Strings(findings)
+
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := strings.TrimSpace(string(out))
+	facts := make(map[string][]byte)
+	err = filepath.Walk(bin, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !strings.HasSuffix(path, ".facts") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		facts[strings.TrimPrefix(path, bin)] = data
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings, facts
+}
+
+func TestWorkerMatchesOneShot(t *testing.T) {
+	wantFindings, wantFacts := build(t)
+	if len(wantFindings) != 5 {
+		t.Fatalf("got findings %q, want 5", wantFindings)
+	}
+	if len(wantFacts) != 4 {
+		t.Fatalf("got facts files %v, want 4", wantFacts)
+	}
+
+	gotFindings, gotFacts := build(t,
+		"--@io_bazel_rules_go//go/config:builder_worker",
+		"--strategy=GoCompilePkg=worker",
+		"--strategy=RunNogo=worker",
+	)
+	if !reflect.DeepEqual(gotFindings, wantFindings) {
+		t.Errorf("got findings with workers:\n%s\nwant:\n%s", strings.Join(gotFindings, "\n"), strings.Join(wantFindings, "\n"))
+	}
+	for path, want := range wantFacts {
+		if got, ok := gotFacts[path]; !ok {
+			t.Errorf("facts file %s was not written with workers", path)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("facts file %s differs with workers", path)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+func TestExplain(t *testing.T) {
+	r, err := runfiles.New(runfiles.Manifest{
+		"_main/pkg/data.txt":     "/main/data.txt",
+		"_main/pkg/other.txt":    "/main/other.txt",
+		"my_dep+/data.txt":       "/dep/data.txt",
+		"my_dep+/other/data.txt": "/dep/other/data.txt",
+		"unrelated+/data.txt":    "/unrelated/data.txt",
+	}, testRepoMapping, runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := r.Explain("my_dep/pkg/data.txt")
+	if e.Discovery != "Manifest option" {
+		t.Errorf("Discovery: got %q, want %q", e.Discovery, "Manifest option")
+	}
+	if want := `apparent name "my_dep" maps to "my_dep+"`; e.Mapping != want {
+		t.Errorf("Mapping: got %q, want %q", e.Mapping, want)
+	}
+	if want := "my_dep+/pkg/data.txt"; e.MappedPath != want {
+		t.Errorf("MappedPath: got %q, want %q", e.MappedPath, want)
+	}
+	if !errors.Is(e.Err, fs.ErrNotExist) {
+		t.Errorf("Err: got %v, want %v", e.Err, fs.ErrNotExist)
+	}
+	want := []string{
+		"my_module/pkg/data.txt",
+		"my_dep/data.txt",
+		"my_dep/other/data.txt",
+		"unrelated+/data.txt",
+	}
+	if !reflect.DeepEqual(e.Suggestions, want) {
+		t.Errorf("Suggestions: got %q, want %q", e.Suggestions, want)
+	}
+
+	e = r.Explain("my_module/pkg/data.txt")
+	if want := filepath.FromSlash("/main/data.txt"); e.Location != want || e.Err != nil {
+		t.Errorf("Explain of existing runfile: got location %q and error %v, want %q", e.Location, e.Err, want)
+	}
+	if e.Suggestions != nil {
+		t.Errorf("Explain of existing runfile: got suggestions %q, want none", e.Suggestions)
+	}
+}
+
+func TestExplain_env(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "foo.runfiles_manifest")
+	if err := os.WriteFile(manifest, []byte("_main/pkg/data.txt /data.txt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RUNFILES_EXPLAIN", "")
+	r, err := runfiles.New(runfiles.ManifestFile(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rErr runfiles.Error
+	if _, err := r.Rlocation("other/pkg/data.txt"); !errors.As(err, &rErr) || rErr.Explanation != nil {
+		t.Errorf("Rlocation without RUNFILES_EXPLAIN: got error %#v, want error without explanation", err)
+	}
+
+	t.Setenv("RUNFILES_EXPLAIN", "1")
+	r, err = runfiles.New(runfiles.ManifestFile(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Rlocation("other/pkg/data.txt")
+	if !errors.As(err, &rErr) || rErr.Explanation == nil {
+		t.Fatalf("Rlocation with RUNFILES_EXPLAIN: got error %#v, want error with explanation", err)
+	}
+	for _, want := range []string{
+		"manifest file " + manifest + " from ManifestFile option",
+		`looked up: "other/pkg/data.txt"`,
+		`similar runfiles: "_main/pkg/data.txt"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Rlocation with RUNFILES_EXPLAIN: got error %q, want it to contain %q", err, want)
+		}
+	}
+
+	_, err = r.RlocationLabel("@@other//pkg:data.txt")
+	if !errors.As(err, &rErr) || rErr.Explanation == nil {
+		t.Fatalf("RlocationLabel with RUNFILES_EXPLAIN: got error %#v, want error with explanation", err)
+	}
+	if want := `label refers to "other/pkg/data.txt"`; rErr.Explanation.Mapping != want {
+		t.Errorf("RlocationLabel with RUNFILES_EXPLAIN: got mapping %q, want %q", rErr.Explanation.Mapping, want)
+	}
+
+	_, err = r.OpenLabel("@@other//pkg:data.txt")
+	if !errors.As(err, &rErr) || rErr.Explanation == nil {
+		t.Fatalf("OpenLabel with RUNFILES_EXPLAIN: got error %#v, want error with explanation", err)
+	}
+	if want := `label refers to "other/pkg/data.txt"`; rErr.Explanation.Mapping != want {
+		t.Errorf("OpenLabel with RUNFILES_EXPLAIN: got mapping %q, want %q", rErr.Explanation.Mapping, want)
+	}
+}
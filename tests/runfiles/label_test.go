@@ -0,0 +1,212 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+func TestRlocationLabel_FileLookup(t *testing.T) {
+	path, err := runfiles.RlocationLabel("//tests/runfiles:test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(b)), "hi!"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// labelRunfiles returns runfiles backed by a manifest that maps each runfiles
+// path in paths to itself, with the given repository mapping.
+func labelRunfiles(t *testing.T, repoMapping string, paths []string, opts ...runfiles.Option) *runfiles.Runfiles {
+	t.Helper()
+	dir := t.TempDir()
+	var manifest strings.Builder
+	if repoMapping != "" {
+		repoMappingFile := filepath.Join(dir, "repo_mapping")
+		if err := os.WriteFile(repoMappingFile, []byte(repoMapping), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		manifest.WriteString("_repo_mapping " + repoMappingFile + "\n")
+	}
+	for _, p := range paths {
+		manifest.WriteString(p + " /" + p + "\n")
+	}
+	manifestFile := filepath.Join(dir, "MANIFEST")
+	if err := os.WriteFile(manifestFile, []byte(manifest.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := runfiles.New(append([]runfiles.Option{runfiles.ManifestFile(manifestFile)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRlocationLabel_bzlmod(t *testing.T) {
+	r := labelRunfiles(t, `,my_module,_main
+,my_dep,my_dep+
+my_dep+,my_dep,my_dep+
+my_dep+,other,other+
+`, []string{
+		"_main/pkg/file.txt",
+		"_main/pkg/pkg",
+		"_main/_main",
+		"my_dep+/data.txt",
+		"my_dep+/pkg/data.txt",
+		"my_dep+/my_dep",
+		"other+/sub/dir/file",
+	}, runfiles.SourceRepo(""))
+
+	for label, want := range map[string]string{
+		"//pkg:file.txt":           "_main/pkg/file.txt",
+		"//pkg":                    "_main/pkg/pkg",
+		"@@//pkg:file.txt":         "_main/pkg/file.txt",
+		"@//pkg:file.txt":          "_main/pkg/file.txt",
+		"@my_module//pkg:file.txt": "_main/pkg/file.txt",
+		"@my_dep//:data.txt":       "my_dep+/data.txt",
+		"@my_dep//pkg:data.txt":    "my_dep+/pkg/data.txt",
+		"@my_dep":                  "my_dep+/my_dep",
+		"@@my_dep+//pkg:data.txt":  "my_dep+/pkg/data.txt",
+		"@@other+//sub:dir/file":   "other+/sub/dir/file",
+		"@@other+//sub/dir:file":   "other+/sub/dir/file",
+	} {
+		t.Run(label, func(t *testing.T) {
+			got, err := r.RlocationLabel(label)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want = filepath.FromSlash("/" + want); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	// Labels without a repository and apparent repository names are resolved
+	// relative to the source repository.
+	dep := r.WithSourceRepo("my_dep+")
+	for label, want := range map[string]string{
+		"//pkg:data.txt":       "my_dep+/pkg/data.txt",
+		"@other//sub:dir/file": "other+/sub/dir/file",
+	} {
+		got, err := dep.RlocationLabel(label)
+		if err != nil {
+			t.Errorf("RlocationLabel(%q): %v", label, err)
+		} else if want = filepath.FromSlash("/" + want); got != want {
+			t.Errorf("RlocationLabel(%q): got %q, want %q", label, got, want)
+		}
+	}
+	if _, err := dep.RlocationLabel("@my_module//pkg:file.txt"); err == nil || !strings.Contains(err.Error(), "is not visible from repository @@my_dep+, which sees @my_dep, @other") {
+		t.Errorf("got error %v for a repository that is not visible", err)
+	}
+}
+
+func TestRlocationLabel_workspace(t *testing.T) {
+	t.Setenv("TEST_WORKSPACE", "my_workspace")
+	r := labelRunfiles(t, "", []string{
+		"my_workspace/pkg/file.txt",
+		"my_dep/pkg/data.txt",
+	}, runfiles.SourceRepo(""))
+
+	for label, want := range map[string]string{
+		"//pkg:file.txt":        "my_workspace/pkg/file.txt",
+		"@my_dep//pkg:data.txt": "my_dep/pkg/data.txt",
+	} {
+		got, err := r.RlocationLabel(label)
+		if err != nil {
+			t.Errorf("RlocationLabel(%q): %v", label, err)
+		} else if want = filepath.FromSlash("/" + want); got != want {
+			t.Errorf("RlocationLabel(%q): got %q, want %q", label, got, want)
+		}
+	}
+
+	t.Setenv("TEST_WORKSPACE", "")
+	if _, err := r.RlocationLabel("//pkg:file.txt"); err == nil {
+		t.Error("got no error for a label in the main repository of unknown name")
+	}
+}
+
+func TestRlocationLabel_errors(t *testing.T) {
+	r := labelRunfiles(t, ",my_dep,my_dep+\n", []string{"my_dep+/pkg/data.txt"}, runfiles.SourceRepo(""))
+	for _, label := range []string{
+		"",
+		"pkg:file",
+		":file",
+		"@@@repo//pkg:file",
+		"@unknown//pkg:file",
+		"//pkg:",
+		"//pkg/../other:file",
+		"//pkg:./file",
+		"///pkg:file",
+		"//pkg/:file",
+	} {
+		if got, err := r.RlocationLabel(label); err == nil {
+			t.Errorf("RlocationLabel(%q): got %q, want error", label, got)
+		}
+	}
+
+	if _, err := r.RlocationLabel("@my_dep//pkg:missing.txt"); !errors.As(err, new(runfiles.Error)) {
+		t.Errorf("got error %v for a missing runfile, want a runfiles.Error", err)
+	}
+
+	var zero runfiles.Runfiles
+	if _, err := zero.RlocationLabel("//pkg:file"); err == nil {
+		t.Error("zero Runfiles: got no error")
+	}
+}
+
+func TestOpenLabel(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(data, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "MANIFEST")
+	if err := os.WriteFile(manifest, []byte("my_dep/pkg/data.txt "+data+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := runfiles.New(runfiles.ManifestFile(manifest), runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.OpenLabel("@my_dep//pkg:data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "data" {
+		t.Errorf("got %q, want %q", b, "data")
+	}
+
+	if _, err := r.OpenLabel("@my_dep//pkg:missing.txt"); !errors.As(err, new(runfiles.Error)) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v for a missing runfile, want a runfiles.Error wrapping fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+func TestManifestFile_unsorted(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"x", "y"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "manifest")
+	d := filepath.ToSlash(dir)
+	if err := os.WriteFile(manifest, []byte(`_main/b/y `+d+`/y
+_main/a /a
+ _main/b/x\swith\sspace `+d+`/x
+_main/c /c1
+
+_main/b/z 
+_main/c /c2
+_main/b-c /b-c
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := runfiles.New(runfiles.ManifestFile(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rlocation, want := range map[string]string{
+		"_main/a":              filepath.FromSlash("/a"),
+		"_main/a/file":         filepath.FromSlash("/a/file"),
+		"_main/b/x with space": filepath.Join(dir, "x"),
+		"_main/b/y":            filepath.Join(dir, "y"),
+		"_main/b-c":            filepath.FromSlash("/b-c"),
+		"_main/c":              filepath.FromSlash("/c2"),
+	} {
+		got, err := r.Rlocation(rlocation)
+		if err != nil {
+			t.Errorf("Rlocation(%q): got unexpected error %q", rlocation, err)
+		} else if got != want {
+			t.Errorf("Rlocation(%q): got %q, want %q", rlocation, got, want)
+		}
+	}
+	if _, err := r.Rlocation("_main/b/z"); !errors.Is(err, runfiles.ErrEmpty) {
+		t.Errorf("Rlocation(%q): got error %v, want %v", "_main/b/z", err, runfiles.ErrEmpty)
+	}
+	if got, err := r.Rlocation("_main/b"); err == nil {
+		t.Errorf("Rlocation(%q): got %q, want error", "_main/b", got)
+	}
+
+	entries, err := fs.ReadDir(r, "_main/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"x with space", "y", "z"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir: got %q, want %q", names, want)
+	}
+	info, err := entries[2].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() || info.Size() != 0 {
+		t.Errorf("ReadDir: got mode %v and size %d for empty runfile, want empty regular file", info.Mode(), info.Size())
+	}
+}
+
+func TestManifestFile_indexCache(t *testing.T) {
+	t.Setenv("RUNFILES_INDEX_CACHE_DIR", "")
+	manifest := filepath.Join(t.TempDir(), "foo.runfiles_manifest")
+	if err := os.WriteFile(manifest, []byte("_main/b /b\n_main/a /a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rlocation := func(name string) string {
+		t.Helper()
+		r, err := runfiles.New(runfiles.ManifestFile(manifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Rlocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// The index is cached next to the manifest.
+	if got, want := rlocation("_main/a"), filepath.FromSlash("/a"); got != want {
+		t.Errorf("Rlocation: got %q, want %q", got, want)
+	}
+	cache := manifest + ".index"
+	index, err := os.ReadFile(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached index with offsets that aren't the start of a line is rebuilt
+	// when a lookup uses them.
+	corrupt := append([]byte(nil), index...)
+	copy(corrupt[len(corrupt)-8:], []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	if err := os.WriteFile(cache, corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rlocation("_main/b"), filepath.FromSlash("/b"); got != want {
+		t.Errorf("Rlocation with corrupt cache: got %q, want %q", got, want)
+	}
+	if b, err := os.ReadFile(cache); err != nil || !bytes.Equal(b, index) {
+		t.Errorf("got cached index %v, %v after rebuilding it, want %v", b, err, index)
+	}
+
+	// The index of a manifest that changed size is rebuilt.
+	if err := os.WriteFile(manifest, []byte("_main/a /b\n_main/b /a\n_main/c /c\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rlocation("_main/a"), filepath.FromSlash("/b"); got != want {
+		t.Errorf("Rlocation after rewriting the manifest: got %q, want %q", got, want)
+	}
+
+	// RUNFILES_INDEX_CACHE_DIR overrides the location of the cache.
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv("RUNFILES_INDEX_CACHE_DIR", cacheDir)
+	if got, want := rlocation("_main/c"), filepath.FromSlash("/c"); got != want {
+		t.Errorf("Rlocation: got %q, want %q", got, want)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cacheDir, "*.index")); len(files) != 1 {
+		t.Errorf("got index files %v, want one", files)
+	}
+}
+
+// BenchmarkManifestFile measures the time it takes to load a manifest with
+// 500k entries and look up a runfile, with and without a cached index.
+func BenchmarkManifestFile(b *testing.B) {
+	b.Setenv("RUNFILES_INDEX_CACHE_DIR", "")
+	manifest := filepath.Join(b.TempDir(), "foo.runfiles_manifest")
+	var buf bytes.Buffer
+	for i := 0; i < 500000; i++ {
+		fmt.Fprintf(&buf, "_main/pkg%d/file%d.txt /execroot/_main/pkg%d/file%d.txt\n", i/100, i, i/100, i)
+	}
+	if err := os.WriteFile(manifest, buf.Bytes(), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	load := func(b *testing.B) {
+		r, err := runfiles.New(runfiles.ManifestFile(manifest))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.Rlocation("_main/pkg1234/file123456.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			os.Remove(manifest + ".index")
+			b.StartTimer()
+			load(b)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		load(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			load(b)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runfiles_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bazelbuild/rules_go/go/runfiles"
+)
+
+var testRepoMapping = runfiles.RepoMapping{
+	{SourceRepo: "", ApparentName: "my_module", TargetRepo: "_main"},
+	{SourceRepo: "", ApparentName: "my_dep", TargetRepo: "my_dep+"},
+	{SourceRepo: "my_dep+", ApparentName: "my_dep", TargetRepo: "my_dep+"},
+}
+
+func readRunfile(t *testing.T, r *runfiles.Runfiles, rlocation string) string {
+	t.Helper()
+	p, err := r.Rlocation(rlocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFS(t *testing.T) {
+	r, err := runfiles.New(runfiles.FS(fstest.MapFS{
+		"_main/pkg/data.txt":  {Data: []byte("data")},
+		"my_dep+/dir/a.txt":   {Data: []byte("a")},
+		"my_dep+/dir/b/c.txt": {Data: []byte("c")},
+	}), testRepoMapping, runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rlocation, want := range map[string]string{
+		"_main/pkg/data.txt":     "data",
+		"my_module/pkg/data.txt": "data",
+		"my_dep/dir/a.txt":       "a",
+	} {
+		if got := readRunfile(t, r, rlocation); got != want {
+			t.Errorf("%s: got %q, want %q", rlocation, got, want)
+		}
+	}
+
+	// Directories are copied with all files in them.
+	dir, err := r.Rlocation("my_dep/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "b", "c.txt")); err != nil || string(data) != "c" {
+		t.Errorf("got %q, %v for a file in a runfiles directory, want %q", data, err, "c")
+	}
+
+	if got, err := fs.ReadFile(r, "my_dep/dir/b/c.txt"); err != nil || string(got) != "c" {
+		t.Errorf("ReadFile: got %q, %v, want %q", got, err, "c")
+	}
+	if p, err := r.RlocationLabel("@my_dep//dir:a.txt"); err != nil || filepath.Base(p) != "a.txt" {
+		t.Errorf("RlocationLabel: got %q, %v", p, err)
+	}
+	if _, err := r.Rlocation("my_dep/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v for a missing runfile, want one that wraps fs.ErrNotExist", err)
+	}
+	if env := r.Env(); env != nil {
+		t.Errorf("Env: got %v, want nil", env)
+	}
+}
+
+func TestFS_repoMappingFile(t *testing.T) {
+	r, err := runfiles.New(runfiles.FS(fstest.MapFS{
+		"_repo_mapping":     {Data: []byte(",my_dep,my_dep+\n")},
+		"my_dep+/dir/a.txt": {Data: []byte("a")},
+	}), runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRunfile(t, r, "my_dep/dir/a.txt"); got != "a" {
+		t.Errorf("got %q, want %q", got, "a")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(data, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tree"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tree", "a.txt"), []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := runfiles.New(runfiles.Manifest{
+		"_main/pkg/data.txt":  data,
+		"_main/pkg/empty.txt": "",
+		"my_dep+/tree":        filepath.Join(dir, "tree"),
+	}, testRepoMapping, runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readRunfile(t, r, "my_module/pkg/data.txt"); got != "data" {
+		t.Errorf("got %q, want %q", got, "data")
+	}
+	if got := readRunfile(t, r, "my_dep/tree/a.txt"); got != "a" {
+		t.Errorf("got %q, want %q", got, "a")
+	}
+	if _, err := r.Rlocation("_main/pkg/empty.txt"); !errors.Is(err, runfiles.ErrEmpty) {
+		t.Errorf("got error %v for an empty runfile, want one that wraps ErrEmpty", err)
+	}
+	entries, err := fs.ReadDir(r, "my_dep/tree")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Errorf("ReadDir: got %v, %v", entries, err)
+	}
+
+	// Without a repository mapping, apparent names are not mapped.
+	r, err = runfiles.New(runfiles.Manifest{"_main/pkg/data.txt": data}, runfiles.RepoMapping{}, runfiles.SourceRepo(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Rlocation("my_module/pkg/data.txt"); err == nil {
+		t.Error("got no error for an apparent name without a repository mapping")
+	}
+}